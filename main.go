@@ -1,10 +1,17 @@
 package main
 
 import (
+	"ScheduleAPI/pkg/auth"
 	"ScheduleAPI/pkg/config"
 	"ScheduleAPI/pkg/controller"
 	"ScheduleAPI/pkg/middleware"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/notifier"
+	"ScheduleAPI/pkg/stream"
+	"ScheduleAPI/pkg/utils"
+	"ScheduleAPI/pkg/webhook"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,41 +20,142 @@ func main() {
 	//Initialize Gin Router
 	r := gin.Default()
 
+	//Client IP of audit log is taken from X-Forwarded-For only behind trusted proxies
+	if err := r.SetTrustedProxies(config.TrustedProxies()); err != nil {
+		log.Fatal(err)
+	}
+
 	//Initialize DB connection
 	db := config.SetupDatabaseConnection()
 	defer config.CloseDatabaseConnection(db)
 
+	//Initialize notification delivery and stream of notifications to connected users
+	//Controllers only queue notifications, notifiers of channels are used by outbox worker
+	channels := map[string]notifier.Notifier{model.ChannelEmail: config.SetupNotifier()}
+	if smsNotifier := config.SetupSMSNotifier(); smsNotifier != nil {
+		channels[model.ChannelSMS] = smsNotifier
+	}
+	//Webhook channel queues notifications for webhook subscriptions of their recipient
+	channels[model.ChannelWebhook] = utils.NewWebhookNotifier(db)
+	hub := stream.NewMemoryHub()
+
+	//Starting background workers
+	go utils.RunHoldSweeper(db, time.Minute, config.WaitlistOfferTTL())
+	deliveryPolicy := utils.DeliveryPolicy{
+		MaxAttempts: config.NotificationMaxAttempts(),
+		RetryBase:   config.NotificationRetryBase(),
+		RetryMax:    config.NotificationRetryMax(),
+	}
+	go utils.RunNotificationWorker(db, channels, deliveryPolicy, 5*time.Second)
+	go utils.RunNotificationListener(db, hub, 5*time.Second)
+	go utils.RunReminderScheduler(db, time.Minute)
+	go utils.RunDigestScheduler(db, time.Minute, config.DigestTime(), config.AppBaseURL())
+	sender := webhook.NewSender(config.WebhookTimeout(), config.WebhookAllowPrivate())
+	webhookPolicy := utils.DeliveryPolicy{
+		MaxAttempts: config.WebhookMaxAttempts(),
+		RetryBase:   config.WebhookRetryBase(),
+		RetryMax:    config.WebhookRetryMax(),
+	}
+	go utils.RunWebhookWorker(db, sender, webhookPolicy, time.Second)
+
 	//Adding middleware to router
-	r.Use(middleware.AuthMiddleware(db))
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	r.Use(middleware.TimeZoneMiddleware())
 
-	//Declaring API routes
+	//Declaring API routes, each route requires permission of user roles (see pkg/auth/rbac.go)
+	//Profile and Clinic objects routes
+	r.GET("api/profile", middleware.RequirePermission(auth.ResourceProfile, auth.ActionRead), controller.GetProfile(db))
+	r.PUT("api/profile", middleware.RequirePermission(auth.ResourceProfile, auth.ActionUpdate), controller.UpdateProfile(db))
+	r.GET("api/clinics", middleware.RequirePermission(auth.ResourceClinic, auth.ActionRead), controller.GetClinicsList(db))
+	r.GET("api/clinics/:id", middleware.RequirePermission(auth.ResourceClinic, auth.ActionRead), controller.GetClinic(db))
+	r.POST("api/clinics", middleware.RequirePermission(auth.ResourceClinic, auth.ActionCreate), controller.CreateClinic(db))
+	r.PUT("api/clinics/:id", middleware.RequirePermission(auth.ResourceClinic, auth.ActionUpdate), controller.UpdateClinic(db))
+	r.PUT("api/clinics/:id/members/:user_id", middleware.RequirePermission(auth.ResourceClinic, auth.ActionManage), controller.AddClinicMember(db))
+	r.DELETE("api/clinics/:id/members/:user_id", middleware.RequirePermission(auth.ResourceClinic, auth.ActionManage), controller.DeleteClinicMember(db))
 	//Schedule objects rotes
-	r.GET("api/schedules/", controller.GetShedulesList(db))
-	r.GET("api/schedules/:id", controller.GetScheduleById(db))
-	r.POST("api/schedules/", controller.CreateSchedule(db))
-	r.PUT("api/schedules/:id", controller.UpdateSchedule(db))
-	r.DELETE("api/schedules/:id", controller.DeleteSchedule(db))
+	r.GET("api/schedules/", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetShedulesList(db))
+	r.GET("api/schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetScheduleById(db))
+	r.POST("api/schedules/", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionCreate), controller.CreateSchedule(db))
+	r.PUT("api/schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionUpdate), controller.UpdateSchedule(db))
+	r.DELETE("api/schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionDelete), controller.DeleteSchedule(db))
+	//RecurringSchedule objects routes
+	r.GET("api/recurring_schedules/", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetRecurringSchedulesList(db))
+	r.GET("api/recurring_schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetRecurringSchedule(db))
+	r.POST("api/recurring_schedules/", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionCreate), controller.CreateRecurringSchedule(db))
+	r.PUT("api/recurring_schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionUpdate), controller.UpdateRecurringSchedule(db))
+	r.DELETE("api/recurring_schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionDelete), controller.DeleteRecurringSchedule(db))
+	r.GET("api/recurring_schedules/:id/occurrences", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetRecurringScheduleOccurrences(db))
+	r.PUT("api/recurring_schedules/:id/occurrences/:start", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionUpdate), controller.UpdateRecurringScheduleOccurrence(db))
+	r.DELETE("api/recurring_schedules/:id/occurrences/:start", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionDelete), controller.DeleteRecurringScheduleOccurrence(db))
+	//TimeOff objects routes
+	r.GET("api/time_off", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetTimeOffList(db))
+	r.POST("api/time_off", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionCreate), controller.CreateTimeOff(db))
+	r.GET("api/time_off/:id/conflicts", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetTimeOffConflicts(db))
+	r.DELETE("api/time_off/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionDelete), controller.DeleteTimeOff(db))
+	//Doctor slots routes
+	r.GET("api/doctors/:id/slots", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetDoctorSlots(db))
 	//Appointment objects routes
-	r.GET("api/appointments/", controller.GetAppointmentsList(db))
-	r.GET("api/appointments/:id", controller.GetAppointment(db))
-	r.POST("api/appointments", controller.CreateAppointment(db))
-	r.PUT("api/appointments/:id", controller.UpdateAppointment(db))
-	r.DELETE("api/appointments/:id", controller.DeleteAppointment(db))
+	r.GET("api/appointments/", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionRead), controller.GetAppointmentsList(db))
+	r.GET("api/appointments/:id", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionRead), controller.GetAppointment(db))
+	r.POST("api/appointments", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionCreate), controller.CreateAppointment(db))
+	r.PUT("api/appointments/:id", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.UpdateAppointment(db))
+	r.DELETE("api/appointments/:id", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionDelete), controller.DeleteAppointment(db))
+	r.POST("api/appointments/:id/confirm", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.TransitionAppointment(db, "confirm"))
+	r.POST("api/appointments/:id/check-in", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.TransitionAppointment(db, "check-in"))
+	r.POST("api/appointments/:id/complete", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.TransitionAppointment(db, "complete"))
+	r.POST("api/appointments/:id/no-show", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.TransitionAppointment(db, "no-show"))
+	r.POST("api/appointments/:id/cancel", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.TransitionAppointment(db, "cancel"))
+	r.POST("api/appointments/:id/reschedule", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.RescheduleAppointment(db))
+	//AppointmentType objects routes
+	r.GET("api/appointment_types", middleware.RequirePermission(auth.ResourceAppointmentType, auth.ActionRead), controller.GetAppointmentTypesList(db))
+	r.GET("api/appointment_types/:id", middleware.RequirePermission(auth.ResourceAppointmentType, auth.ActionRead), controller.GetAppointmentType(db))
+	r.POST("api/appointment_types", middleware.RequirePermission(auth.ResourceAppointmentType, auth.ActionCreate), controller.CreateAppointmentType(db))
+	r.PUT("api/appointment_types/:id", middleware.RequirePermission(auth.ResourceAppointmentType, auth.ActionUpdate), controller.UpdateAppointmentType(db))
+	r.DELETE("api/appointment_types/:id", middleware.RequirePermission(auth.ResourceAppointmentType, auth.ActionDelete), controller.DeleteAppointmentType(db))
+	//SlotHold objects routes
+	r.GET("api/holds", middleware.RequirePermission(auth.ResourceHold, auth.ActionRead), controller.GetSlotHoldsList(db))
+	r.POST("api/holds", middleware.RequirePermission(auth.ResourceHold, auth.ActionCreate), controller.CreateSlotHold(db))
+	r.POST("api/holds/:id/confirm", middleware.RequirePermission(auth.ResourceHold, auth.ActionUpdate), controller.ConfirmSlotHold(db))
+	r.DELETE("api/holds/:id", middleware.RequirePermission(auth.ResourceHold, auth.ActionDelete), controller.DeleteSlotHold(db))
+	r.GET("api/waitlist", middleware.RequirePermission(auth.ResourceWaitlist, auth.ActionRead), controller.GetWaitlistEntriesList(db))
+	r.POST("api/waitlist", middleware.RequirePermission(auth.ResourceWaitlist, auth.ActionCreate), controller.CreateWaitlistEntry(db))
+	r.POST("api/waitlist/:id/accept", middleware.RequirePermission(auth.ResourceWaitlist, auth.ActionUpdate), controller.AcceptWaitlistOffer(db))
+	r.DELETE("api/waitlist/:id", middleware.RequirePermission(auth.ResourceWaitlist, auth.ActionDelete), controller.DeleteWaitlistEntry(db))
 	//Notification objects routes
-	r.GET("api/notifications", controller.GetNotificationsList(db))
-	r.GET("api/notifications/:id", controller.GetNotification(db))
+	r.GET("api/notifications", middleware.RequirePermission(auth.ResourceNotification, auth.ActionRead), controller.GetNotificationsList(db))
+	r.GET("api/notifications/stream", middleware.RequirePermission(auth.ResourceNotification, auth.ActionRead), controller.StreamNotifications(db, hub, config.StreamHeartbeat()))
+	r.GET("api/notifications/unread_count", middleware.RequirePermission(auth.ResourceNotification, auth.ActionRead), controller.GetUnreadNotificationsCount(db))
+	r.GET("api/notifications/:id", middleware.RequirePermission(auth.ResourceNotification, auth.ActionRead), controller.GetNotification(db))
+	r.POST("api/notifications/read_all", middleware.RequirePermission(auth.ResourceNotification, auth.ActionUpdate), controller.MarkAllNotificationsRead(db))
+	r.POST("api/notifications/:id/read", middleware.RequirePermission(auth.ResourceNotification, auth.ActionUpdate), controller.MarkNotificationRead(db))
+	r.POST("api/notifications/:id/archive", middleware.RequirePermission(auth.ResourceNotification, auth.ActionUpdate), controller.ArchiveNotification(db))
+	r.DELETE("api/notifications/:id", middleware.RequirePermission(auth.ResourceNotification, auth.ActionDelete), controller.DeleteNotification(db))
+	r.GET("api/notification_preferences", middleware.RequirePermission(auth.ResourceNotification, auth.ActionRead), controller.GetNotificationPreferences(db, channels))
+	r.PUT("api/notification_preferences", middleware.RequirePermission(auth.ResourceNotification, auth.ActionUpdate), controller.UpdateNotificationPreferences(db, channels))
+	r.GET("api/agenda_digest/preview", middleware.RequirePermission(auth.ResourceAgendaDigest, auth.ActionRead), controller.PreviewAgendaDigest(db))
+	//WebhookSubscription objects routes
+	r.GET("api/webhooks", middleware.RequirePermission(auth.ResourceWebhook, auth.ActionRead), controller.GetWebhooksList(db))
+	r.POST("api/webhooks", middleware.RequirePermission(auth.ResourceWebhook, auth.ActionCreate), controller.CreateWebhook(db, sender))
+	r.DELETE("api/webhooks/:id", middleware.RequirePermission(auth.ResourceWebhook, auth.ActionDelete), controller.DeleteWebhook(db))
+	r.POST("api/webhooks/:id/test", middleware.RequirePermission(auth.ResourceWebhook, auth.ActionUpdate), controller.TestWebhook(db, sender))
+	r.GET("api/webhooks/:id/deliveries", middleware.RequirePermission(auth.ResourceWebhook, auth.ActionRead), controller.GetWebhookDeliveriesList(db))
+	r.POST("api/webhooks/:id/deliveries/:delivery_id/replay", middleware.RequirePermission(auth.ResourceWebhook, auth.ActionUpdate), controller.ReplayWebhookDelivery(db))
 	//Prescription objects routes
-	r.GET("api/prescriptions", controller.GetPrescriptionList(db))
-	r.GET("api/prescriptions/:id", controller.GetPrescription(db))
-	r.POST("api/prescriptions", controller.CreatePrescription(db))
-	r.PUT("api/prescriptions/:id", controller.UpdatePrescription(db))
-	r.DELETE("api/prescriptions/:id", controller.DeletePrescription(db))
+	r.GET("api/prescriptions", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionRead), controller.GetPrescriptionList(db))
+	r.GET("api/prescriptions/:id", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionRead), controller.GetPrescription(db))
+	r.POST("api/prescriptions", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionCreate), controller.CreatePrescription(db))
+	r.PUT("api/prescriptions/:id", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionUpdate), controller.UpdatePrescription(db))
+	r.DELETE("api/prescriptions/:id", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionDelete), controller.DeletePrescription(db))
 	//MedicalRecord objects routes
-	r.GET("api/medical_records/", controller.GetMedicalRecorsList(db))
-	r.GET("api/medical_records/:id", controller.GetMedicalRecord(db))
-	r.POST("api/medical_records/", controller.CreateMedicalRecord(db))
-	r.PUT("api/medical_records/:id", controller.UpdateMedicalRecord(db))
-	r.DELETE("api/medical_records/:id", controller.DeleteMedicalRecord(db))
+	r.GET("api/medical_records/", middleware.RequirePermission(auth.ResourceMedicalRecord, auth.ActionRead), controller.GetMedicalRecorsList(db))
+	r.GET("api/medical_records/:id", middleware.RequirePermission(auth.ResourceMedicalRecord, auth.ActionRead), controller.GetMedicalRecord(db))
+	r.POST("api/medical_records/", middleware.RequirePermission(auth.ResourceMedicalRecord, auth.ActionCreate), controller.CreateMedicalRecord(db))
+	r.PUT("api/medical_records/:id", middleware.RequirePermission(auth.ResourceMedicalRecord, auth.ActionUpdate), controller.UpdateMedicalRecord(db))
+	r.DELETE("api/medical_records/:id", middleware.RequirePermission(auth.ResourceMedicalRecord, auth.ActionDelete), controller.DeleteMedicalRecord(db))
+	//Audit log routes
+	r.GET("api/audit", middleware.RequirePermission(auth.ResourceAudit, auth.ActionRead), controller.GetAuditLog(db))
+	r.GET("api/audit/verify", middleware.RequirePermission(auth.ResourceAudit, auth.ActionRead), controller.VerifyAuditLog(db))
 	//start router
 	if err := r.Run(":8080"); err != nil {
 		log.Fatal("Failed to run server:", err)
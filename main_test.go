@@ -1,21 +1,56 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"bytes"
 	"encoding/json"
 
+	"ScheduleAPI/pkg/auth"
 	"ScheduleAPI/pkg/config"
 	"ScheduleAPI/pkg/controller"
 	"ScheduleAPI/pkg/middleware"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/notifier"
+	"ScheduleAPI/pkg/sms"
+	"ScheduleAPI/pkg/stream"
+	"ScheduleAPI/pkg/templates"
+	"ScheduleAPI/pkg/utils"
+	"ScheduleAPI/pkg/webhook"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
+func makeToken(t *testing.T, userID uuid.UUID, email string, isDoctor bool) string {
+	// Signing token with the same secret as AuthMiddleware uses
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":   userID.String(),
+		"email":     email,
+		"is_doctor": isDoctor,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenString
+}
+
 func TestCreateScheduleHandler(t *testing.T) {
 	// Create test data
 	payload := map[string]interface{}{
@@ -29,7 +64,7 @@ func TestCreateScheduleHandler(t *testing.T) {
 	db := config.SetupDatabaseConnection()
 	defer config.CloseDatabaseConnection(db)
 	//Adding middleware
-	r.Use(middleware.AuthMiddleware(db))
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
 	//Adding route
 	r.POST("api/schedules/create/", controller.CreateSchedule(db))
 
@@ -49,3 +84,1546 @@ func TestCreateScheduleHandler(t *testing.T) {
 	//responseData, _ := ioutil.ReadAll(w.Body)
 	assert.Equal(t, http.StatusCreated, w.Code)
 }
+
+func TestMalformedTokenRejected(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding route
+	r.POST("api/schedules/create", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionCreate), controller.CreateSchedule(db))
+
+	// Token with numeric user id and string is_doctor is signed correctly but malformed
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":   42,
+		"email":     "doctor@test.com",
+		"is_doctor": "true",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"", "Bearer", "Bearer " + tokenString} {
+		req, _ := http.NewRequest("POST", "/api/schedules/create", strings.NewReader("{}"))
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+		var body map[string]string
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.NotEmpty(t, body["error"])
+	}
+
+	// Valid token of patient is forbidden to create schedule
+	req, _ := http.NewRequest("POST", "/api/schedules/create", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+makeToken(t, uuid.Must(uuid.NewV4()), "patient@test.com", false))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error": "Your role is not allowed to create schedule"}`, w.Body.String())
+}
+
+func makeRoleToken(t *testing.T, userID uuid.UUID, email string, roles []string, clinicID uint) string {
+	// Signing token with roles and clinic of user
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":   userID.String(),
+		"email":     email,
+		"roles":     roles,
+		"clinic_id": clinicID,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenString
+}
+
+func TestAdminManagesDoctorSchedule(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding routes
+	r.POST("api/schedules/", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionCreate), controller.CreateSchedule(db))
+	r.DELETE("api/schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionDelete), controller.DeleteSchedule(db))
+
+	// Doctor belongs to clinic of admin, other doctor to another clinic
+	clinic := model.Clinic{Name: "Admin clinic", TimeZone: "UTC"}
+	otherClinic := model.Clinic{Name: "Other clinic", TimeZone: "UTC"}
+	if err := db.Create(&clinic).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&otherClinic).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&clinic)
+	defer db.Unscoped().Delete(&otherClinic)
+	doctorID := uuid.Must(uuid.NewV4())
+	otherDoctorID := uuid.Must(uuid.NewV4())
+	profiles := []model.UserProfile{
+		{UserID: doctorID, Email: "doctor@test.com", ClinicID: &clinic.ID},
+		{UserID: otherDoctorID, Email: "other@test.com", ClinicID: &otherClinic.ID},
+	}
+	if err := db.Create(&profiles).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&profiles)
+	defer db.Unscoped().Where("doctor_id IN ?", []uuid.UUID{doctorID, otherDoctorID}).Delete(&model.Schedule{})
+
+	adminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "admin@test.com", []string{auth.RoleClinicAdmin}, clinic.ID)
+	timeStart := time.Now().UTC().AddDate(5, 0, 0).Truncate(time.Hour)
+	createSchedule := func(token string, doctorID uuid.UUID, email string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(map[string]interface{}{"time_start": timeStart, "time_end": timeStart.Add(time.Hour),
+			"doctor_id": doctorID, "doctor_email": email})
+		req, _ := http.NewRequest("POST", "/api/schedules/", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Clinic admin creates and deletes schedule of doctor of the clinic
+	w := createSchedule(adminToken, doctorID, "doctor@test.com")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var schedule model.Schedule
+	json.Unmarshal(w.Body.Bytes(), &schedule)
+	assert.Equal(t, doctorID, schedule.DoctorID)
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/schedules/%d", schedule.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Doctors of other clinics are not managed by the admin
+	w = createSchedule(adminToken, otherDoctorID, "other@test.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	// Receptionist cannot manage schedules, doctor cannot create schedule of colleague
+	receptionistToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "desk@test.com", []string{auth.RoleReceptionist}, clinic.ID)
+	assert.Equal(t, http.StatusForbidden, createSchedule(receptionistToken, doctorID, "doctor@test.com").Code)
+	doctorToken := makeToken(t, otherDoctorID, "other@test.com", true)
+	assert.Equal(t, http.StatusForbidden, createSchedule(doctorToken, doctorID, "doctor@test.com").Code)
+}
+
+func TestConcurrentBookingSingleWinner(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding route
+	r.POST("api/appointments", controller.CreateAppointment(db))
+
+	// Create schedule of fresh doctor far in the future
+	doctorID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().AddDate(5, 0, 0).Truncate(time.Hour)
+	schedule := model.Schedule{DoctorID: doctorID, DoctorEmail: "doctor@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(4 * time.Hour)}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&schedule)
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.Appointment{})
+	defer db.Unscoped().Where("user_id = ?", doctorID).Delete(&model.Notification{})
+
+	// Fire parallel bookings of the same slot by different patients
+	const attempts = 10
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			patientID := uuid.Must(uuid.NewV4())
+			payload, _ := json.Marshal(map[string]interface{}{
+				"time_start":    timeStart.Add(time.Hour),
+				"time_end":      timeStart.Add(90 * time.Minute),
+				"doctor_id":     doctorID,
+				"doctor_email":  "doctor@test.com",
+				"patient_id":    patientID,
+				"patient_email": "patient@test.com",
+			})
+			req, _ := http.NewRequest("POST", "/api/appointments", bytes.NewBuffer(payload))
+			req.Header.Set("Authorization", "Bearer "+makeToken(t, patientID, "patient@test.com", false))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	assert.Equal(t, 1, counts[http.StatusCreated])
+	assert.Equal(t, attempts-1, counts[http.StatusConflict])
+	// Only the winner queues notification for doctor
+	var notifications []model.Notification
+	db.Where("user_id = ?", doctorID).Find(&notifications)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, model.NotificationPending, notifications[0].DeliveryStatus)
+}
+
+func TestNotificationDeadLetter(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	userID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	if err := utils.CreateNotification(db, "Prescription", "patient@test.com", userID, templates.Data{Prescription: &model.Prescription{DrugName: "Aspirin"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every attempt fails, retries are due immediately
+	n := notifier.NewMemoryNotifier()
+	n.SetError(errors.New("connection refused"))
+	policy := utils.DeliveryPolicy{MaxAttempts: 3, RetryBase: 0, RetryMax: 0}
+	var notification model.Notification
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if _, err := utils.DeliverNotifications(db, map[string]notifier.Notifier{model.ChannelEmail: n}, policy); err != nil {
+			t.Fatal(err)
+		}
+		db.Where("user_id = ?", userID).First(&notification)
+		assert.Equal(t, attempt, notification.DeliveryAttempts)
+	}
+	assert.Equal(t, model.NotificationDead, notification.DeliveryStatus)
+	assert.Equal(t, "connection refused", notification.DeliveryError)
+	// Failed channel is kept as dead letter, in-app delivery is not affected
+	var deliveries []model.NotificationOutbox
+	db.Where("notification_id = ?", notification.ID).Find(&deliveries)
+	statuses := map[string]string{}
+	for _, delivery := range deliveries {
+		statuses[delivery.Channel] = delivery.Status
+	}
+	assert.Equal(t, map[string]string{model.ChannelEmail: model.NotificationDead, model.ChannelInApp: model.NotificationSent}, statuses)
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	userID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	defer db.Where("user_id = ?", userID).Delete(&model.NotificationPreference{})
+	// Email is disabled for all types, prescriptions are not shown in-app
+	preferences := []model.NotificationPreference{
+		{UserID: userID, Type: model.AnyNotificationType, Channel: model.ChannelEmail, Enabled: false},
+		{UserID: userID, Type: "Prescription", Channel: model.ChannelInApp, Enabled: false},
+	}
+	if err := db.Create(&preferences).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.CreateNotification(db, "Prescription", "patient@test.com", userID, templates.Data{Prescription: &model.Prescription{DrugName: "Aspirin"}}); err != nil {
+		t.Fatal(err)
+	}
+	var notification model.Notification
+	db.Preload("Deliveries").Where("user_id = ?", userID).First(&notification)
+	assert.Equal(t, model.NotificationSkipped, notification.DeliveryStatus)
+	assert.Empty(t, notification.Deliveries)
+	assert.NotNil(t, notification.ArchivedAt)
+}
+
+func TestNotificationDelivered(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	userID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	if err := utils.CreateNotification(db, "Prescription", "patient@test.com", userID, templates.Data{Prescription: &model.Prescription{DrugName: "Aspirin"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	n := notifier.NewMemoryNotifier()
+	policy := utils.DeliveryPolicy{MaxAttempts: 3, RetryBase: time.Second, RetryMax: time.Minute}
+	if _, err := utils.DeliverNotifications(db, map[string]notifier.Notifier{model.ChannelEmail: n}, policy); err != nil {
+		t.Fatal(err)
+	}
+	var notification model.Notification
+	db.Where("user_id = ?", userID).First(&notification)
+	assert.Equal(t, model.NotificationSent, notification.DeliveryStatus)
+	assert.NotNil(t, notification.DeliveredAt)
+	delivered := false
+	for _, message := range n.Messages() {
+		delivered = delivered || message.UserID == userID
+	}
+	assert.True(t, delivered)
+}
+
+func TestSMSNotificationDelivered(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	userID := uuid.Must(uuid.NewV4())
+	profile := model.UserProfile{UserID: userID, Email: "patient@test.com", Phone: "+79991234567"}
+	if err := db.Create(&profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&profile)
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	defer db.Where("user_id = ?", userID).Delete(&model.NotificationPreference{})
+	preference := model.NotificationPreference{UserID: userID, Type: model.AnyNotificationType, Channel: model.ChannelSMS, Enabled: true}
+	if err := db.Create(&preference).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.CreateNotification(db, "Prescription", "patient@test.com", userID, templates.Data{Prescription: &model.Prescription{DrugName: "Aspirin"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := sms.NewMemoryProvider()
+	channels := map[string]notifier.Notifier{model.ChannelEmail: notifier.NewMemoryNotifier(), model.ChannelSMS: notifier.NewSMSNotifier(provider, 2)}
+	policy := utils.DeliveryPolicy{MaxAttempts: 3, RetryBase: time.Second, RetryMax: time.Minute}
+	if _, err := utils.DeliverNotifications(db, channels, policy); err != nil {
+		t.Fatal(err)
+	}
+	var notification model.Notification
+	db.Where("user_id = ?", userID).First(&notification)
+	assert.Equal(t, model.NotificationSent, notification.DeliveryStatus)
+	delivered := false
+	for _, message := range provider.Messages() {
+		if message.To == profile.Phone {
+			delivered = true
+			assert.Contains(t, message.Body, "Aspirin")
+		}
+	}
+	assert.True(t, delivered)
+}
+
+func TestRemindersFollowAppointment(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	patientID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().AddDate(0, 0, 3).Truncate(time.Hour)
+	appointment := model.Appointment{DoctorID: uuid.Must(uuid.NewV4()), DoctorEmail: "doctor@test.com", PatientID: patientID, PatientEmail: "patient@test.com",
+		TimeStart: timeStart, TimeEnd: timeStart.Add(30 * time.Minute), BlockStart: timeStart, BlockEnd: timeStart.Add(30 * time.Minute)}
+	if err := db.Create(&appointment).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&appointment)
+	defer db.Where("appointment_id = ?", appointment.ID).Delete(&model.AppointmentReminder{})
+	defer db.Unscoped().Where("user_id = ?", patientID).Delete(&model.Notification{})
+
+	offsets := []time.Duration{24 * time.Hour, 2 * time.Hour}
+	assert.NoError(t, utils.ScheduleReminders(db, appointment, offsets))
+	// Scheduling again keeps existing reminders
+	assert.NoError(t, utils.ScheduleReminders(db, appointment, offsets))
+	var pending int64
+	db.Model(&model.AppointmentReminder{}).Where("appointment_id = ? AND status = ?", appointment.ID, model.ReminderPending).Count(&pending)
+	assert.Equal(t, int64(2), pending)
+
+	// Rescheduling replaces pending reminders
+	appointment.TimeStart = appointment.TimeStart.Add(24 * time.Hour)
+	assert.NoError(t, utils.ScheduleReminders(db, appointment, offsets))
+	var reminders []model.AppointmentReminder
+	db.Where("appointment_id = ?", appointment.ID).Order("id").Find(&reminders)
+	statuses := map[string]int{}
+	for _, reminder := range reminders {
+		statuses[reminder.Status]++
+	}
+	assert.Equal(t, map[string]int{model.ReminderCancelled: 2, model.ReminderPending: 2}, statuses)
+
+	// Cancelling appointment cancels pending reminders
+	appointment.Status = model.AppointmentCancelled
+	assert.NoError(t, utils.ScheduleReminders(db, appointment, offsets))
+	db.Model(&model.AppointmentReminder{}).Where("appointment_id = ? AND status = ?", appointment.ID, model.ReminderPending).Count(&pending)
+	assert.Zero(t, pending)
+}
+
+func TestDueReminderSentOnce(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	patientID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	appointment := model.Appointment{DoctorID: uuid.Must(uuid.NewV4()), DoctorEmail: "doctor@test.com", PatientID: patientID, PatientEmail: "patient@test.com",
+		TimeStart: timeStart, TimeEnd: timeStart.Add(30 * time.Minute), BlockStart: timeStart, BlockEnd: timeStart.Add(30 * time.Minute)}
+	if err := db.Create(&appointment).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&appointment)
+	defer db.Where("appointment_id = ?", appointment.ID).Delete(&model.AppointmentReminder{})
+	defer db.Unscoped().Where("user_id = ?", patientID).Delete(&model.Notification{})
+	// Reminder 2h before is already due
+	reminder := model.AppointmentReminder{AppointmentID: appointment.ID, Offset: 2 * time.Hour, SendAt: timeStart.Add(-2 * time.Hour)}
+	if err := db.Create(&reminder).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Parallel schedulers send the reminder once
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			utils.SendDueReminders(db)
+		}()
+	}
+	wg.Wait()
+	var notifications []model.Notification
+	db.Where("user_id = ? AND type = ?", patientID, "Reminder").Find(&notifications)
+	assert.Len(t, notifications, 1)
+	db.First(&reminder, reminder.ID)
+	assert.Equal(t, model.ReminderSent, reminder.Status)
+}
+
+func TestAgendaDigestSentOnce(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	doctorID := uuid.Must(uuid.NewV4())
+	profile := model.UserProfile{UserID: doctorID, Email: "doctor@test.com", TimeZone: "Europe/Moscow", DailyDigest: true}
+	if err := db.Create(&profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&profile)
+	defer db.Where("doctor_id = ?", doctorID).Delete(&model.AgendaDigest{})
+	defer db.Unscoped().Where("user_id = ?", doctorID).Delete(&model.Notification{})
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	day := time.Date(2027, 1, 5, 0, 0, 0, 0, moscow)
+	// Only active appointments of the local day are listed
+	for _, appointment := range []model.Appointment{
+		{TimeStart: day.Add(10 * time.Hour), PatientEmail: "first@test.com"},
+		{TimeStart: day.Add(12 * time.Hour), PatientEmail: "cancelled@test.com", Status: model.AppointmentCancelled},
+		{TimeStart: day.Add(25 * time.Hour), PatientEmail: "tomorrow@test.com"},
+	} {
+		appointment.DoctorID = doctorID
+		appointment.DoctorEmail = "doctor@test.com"
+		appointment.PatientID = uuid.Must(uuid.NewV4())
+		appointment.TimeEnd = appointment.TimeStart.Add(30 * time.Minute)
+		appointment.BlockStart = appointment.TimeStart
+		appointment.BlockEnd = appointment.TimeEnd
+		if err := db.Create(&appointment).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(&appointment)
+	}
+
+	// Digest is not sent before DIGEST_TIME of the doctor
+	utils.SendDueDigests(db, 7*time.Hour, "", day.Add(6*time.Hour))
+	var count int64
+	db.Model(&model.AgendaDigest{}).Where("doctor_id = ?", doctorID).Count(&count)
+	assert.Zero(t, count)
+
+	// Parallel schedulers send the digest once
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			utils.SendDueDigests(db, 7*time.Hour, "", day.Add(8*time.Hour))
+		}()
+	}
+	wg.Wait()
+	var notifications []model.Notification
+	db.Where("user_id = ? AND type = ?", doctorID, "Digest").Find(&notifications)
+	if assert.Len(t, notifications, 1) {
+		assert.Contains(t, notifications[0].Text, "first@test.com")
+		assert.NotContains(t, notifications[0].Text, "cancelled@test.com")
+		assert.NotContains(t, notifications[0].Text, "tomorrow@test.com")
+	}
+}
+
+func TestWebhookDeliveryAndReplay(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	// Receiver verifies signatures and fails until it is fixed
+	secret := "0123456789abcdef"
+	var mu sync.Mutex
+	var payloads []utils.WebhookPayload
+	failing := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhook.Verify(secret, r.Header.Get(webhook.TimestampHeader), body, r.Header.Get(webhook.SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var payload utils.WebhookPayload
+		json.Unmarshal(body, &payload)
+		payloads = append(payloads, payload)
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	ownerID := uuid.Must(uuid.NewV4())
+	subscription := model.WebhookSubscription{URL: receiver.URL, Secret: secret, Events: utils.EventPrescriptionCreated, CreatedBy: ownerID}
+	if err := db.Create(&subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&subscription)
+	defer db.Where("subscription_id = ?", subscription.ID).Delete(&model.WebhookDelivery{})
+	// Events outside of filter are not queued
+	if err := utils.EmitEvent(db, utils.EventMedicalRecordCreated, model.MedicalRecord{Text: "Healthy"}, ownerID); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.EmitEvent(db, utils.EventPrescriptionCreated, model.Prescription{DrugName: "Aspirin"}, ownerID, uuid.Must(uuid.NewV4())); err != nil {
+		t.Fatal(err)
+	}
+	var deliveries []model.WebhookDelivery
+	db.Where("subscription_id = ?", subscription.ID).Find(&deliveries)
+	if !assert.Len(t, deliveries, 1) {
+		return
+	}
+	delivery := deliveries[0]
+	defer db.Where("delivery_id IN (?)", db.Model(&model.WebhookDelivery{}).Select("id").Where("subscription_id = ?", subscription.ID)).Delete(&model.WebhookAttempt{})
+
+	// Failed delivery is retried and becomes dead, every attempt is recorded
+	sender := webhook.NewSender(time.Second, true)
+	policy := utils.DeliveryPolicy{MaxAttempts: 2, RetryBase: 0, RetryMax: 0}
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if _, err := utils.DeliverWebhooks(db, sender, policy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Preload("History").First(&delivery, delivery.ID)
+	assert.Equal(t, model.WebhookDead, delivery.Status)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	assert.Len(t, delivery.History, 2)
+
+	// Replay after receiver is fixed keeps event id
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	replay, err := utils.ReplayWebhookDelivery(db, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.DeliverWebhooks(db, sender, policy); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&replay, replay.ID)
+	assert.Equal(t, model.WebhookSent, replay.Status)
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, payloads, 3) {
+		assert.Equal(t, payloads[0].ID, payloads[2].ID)
+		assert.Equal(t, utils.EventPrescriptionCreated, payloads[2].Event)
+		assert.Equal(t, "Aspirin", payloads[2].Data.(map[string]interface{})["DrugName"])
+	}
+}
+
+func TestNotificationReadState(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding routes
+	r.GET("api/notifications", controller.GetNotificationsList(db))
+	r.GET("api/notifications/unread_count", controller.GetUnreadNotificationsCount(db))
+	r.POST("api/notifications/read_all", controller.MarkAllNotificationsRead(db))
+	r.POST("api/notifications/:id/read", controller.MarkNotificationRead(db))
+	r.POST("api/notifications/:id/archive", controller.ArchiveNotification(db))
+
+	userID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	notifications := []model.Notification{
+		{Type: "Create", UserID: userID, UserEmail: "patient@test.com", Text: "first"},
+		{Type: "Cancel", UserID: userID, UserEmail: "patient@test.com", Text: "second"},
+		{Type: "Cancel", UserID: userID, UserEmail: "patient@test.com", Text: "third"},
+	}
+	if err := db.Create(&notifications).Error; err != nil {
+		t.Fatal(err)
+	}
+	token := makeToken(t, userID, "patient@test.com", false)
+	request := func(method, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	unread := func() int {
+		var body struct{ Unread int }
+		json.Unmarshal(request("GET", "/api/notifications/unread_count").Body.Bytes(), &body)
+		return body.Unread
+	}
+
+	assert.Equal(t, 3, unread())
+	assert.Equal(t, http.StatusOK, request("POST", fmt.Sprintf("/api/notifications/%d/read", notifications[0].ID)).Code)
+	assert.Equal(t, 2, unread())
+	// Archived notification is hidden from default list
+	assert.Equal(t, http.StatusOK, request("POST", fmt.Sprintf("/api/notifications/%d/archive", notifications[1].ID)).Code)
+	assert.Equal(t, 1, unread())
+	var listed []model.Notification
+	json.Unmarshal(request("GET", "/api/notifications?type=Cancel").Body.Bytes(), &listed)
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, notifications[2].ID, listed[0].ID)
+	}
+	assert.Equal(t, http.StatusOK, request("POST", "/api/notifications/read_all").Code)
+	assert.Equal(t, 0, unread())
+	// Notifications of other users are not found
+	other := makeToken(t, uuid.Must(uuid.NewV4()), "other@test.com", false)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/notifications/%d/read", notifications[2].ID), nil)
+	req.Header.Set("Authorization", "Bearer "+other)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNotificationStreamResume(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding route
+	hub := stream.NewMemoryHub()
+	r.GET("api/notifications/stream", controller.StreamNotifications(db, hub, 50*time.Millisecond))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	userID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	archivedAt := time.Now()
+	notifications := []model.Notification{
+		{Type: "Create", UserID: userID, UserEmail: "patient@test.com", Text: "seen"},
+		{Type: "Status", UserID: userID, UserEmail: "patient@test.com", Text: "archived", ReadAt: &archivedAt, ArchivedAt: &archivedAt},
+		{Type: "Change", UserID: userID, UserEmail: "patient@test.com", Text: "missed"},
+	}
+	if err := db.Create(&notifications).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Reconnecting after the first notification
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/notifications/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+makeToken(t, userID, "patient@test.com", false))
+	req.Header.Set("Last-Event-ID", fmt.Sprint(notifications[0].ID))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var ids []string
+	heartbeat := false
+	published := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && (len(ids) < 2 || !heartbeat) {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id:"):
+			ids = append(ids, strings.TrimSpace(strings.TrimPrefix(line, "id:")))
+		case strings.HasPrefix(line, ": heartbeat"):
+			heartbeat = true
+		}
+		// Publishing new notification after the missed one is replayed
+		if len(ids) == 1 && !published {
+			published = true
+			created := model.Notification{Type: "Cancel", UserID: userID, UserEmail: "patient@test.com", Text: "live"}
+			db.Create(&created)
+			hub.Publish(created)
+			// Already sent notifications are not repeated
+			hub.Publish(notifications[2])
+		}
+	}
+	// Archived notification is not replayed
+	if assert.Len(t, ids, 2) {
+		assert.Equal(t, fmt.Sprint(notifications[2].ID), ids[0])
+	}
+	assert.True(t, heartbeat)
+}
+
+func TestPatientCannotAccessOtherPatientData(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding routes
+	r.GET("api/appointments/", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionRead), controller.GetAppointmentsList(db))
+	r.GET("api/appointments/:id", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionRead), controller.GetAppointment(db))
+	r.PUT("api/appointments/:id", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.UpdateAppointment(db))
+	r.POST("api/appointments/:id/cancel", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.TransitionAppointment(db, "cancel"))
+	r.POST("api/appointments/:id/reschedule", middleware.RequirePermission(auth.ResourceAppointment, auth.ActionUpdate), controller.RescheduleAppointment(db))
+	r.GET("api/schedules/:id", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetScheduleById(db))
+	r.GET("api/prescriptions", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionRead), controller.GetPrescriptionList(db))
+	r.GET("api/prescriptions/:id", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionRead), controller.GetPrescription(db))
+	r.PUT("api/prescriptions/:id", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionUpdate), controller.UpdatePrescription(db))
+	r.GET("api/medical_records/:id", middleware.RequirePermission(auth.ResourceMedicalRecord, auth.ActionRead), controller.GetMedicalRecord(db))
+
+	// Appointment, prescription and medical record of the patient with schedule of the doctor
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	otherPatientID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().AddDate(5, 0, 0).Truncate(time.Hour)
+	schedule := model.Schedule{DoctorID: doctorID, DoctorEmail: "doctor@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(4 * time.Hour)}
+	appointment := model.Appointment{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: patientID,
+		PatientEmail: "patient@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(time.Hour)}
+	prescription := model.Prescription{DrugName: "Aspirin", DoctorID: doctorID, DoctorEmail: "doctor@test.com",
+		PatientID: patientID, PatientEmail: "patient@test.com"}
+	medicalRecord := model.MedicalRecord{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: patientID,
+		PatientEmail: "patient@test.com", Text: "Diagnosis"}
+	for _, object := range []interface{}{&schedule, &appointment, &prescription, &medicalRecord} {
+		if err := db.Create(object).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(object)
+	}
+
+	request := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	appointmentBody := fmt.Sprintf(`{"doctor_id": "%s", "doctor_email": "doctor@test.com", "patient_id": "%s",
+		"patient_email": "other@test.com", "time_start": "%s", "time_end": "%s"}`,
+		doctorID, otherPatientID, timeStart.Format(time.RFC3339), timeStart.Add(time.Hour).Format(time.RFC3339))
+	prescriptionBody := fmt.Sprintf(`{"drug_name": "Placebo", "patient_id": "%s", "patient_email": "other@test.com"}`, otherPatientID)
+
+	// Another patient sees none of the objects and cannot change them, they look like missing objects
+	otherToken := makeToken(t, otherPatientID, "other@test.com", false)
+	hidden := []struct{ method, path, body string }{
+		{"GET", fmt.Sprintf("/api/appointments/%d", appointment.ID), ""},
+		{"PUT", fmt.Sprintf("/api/appointments/%d", appointment.ID), appointmentBody},
+		{"POST", fmt.Sprintf("/api/appointments/%d/cancel", appointment.ID), ""},
+		{"POST", fmt.Sprintf("/api/appointments/%d/reschedule", appointment.ID), `{"time_start": "` + timeStart.Add(2*time.Hour).Format(time.RFC3339) + `"}`},
+		{"GET", fmt.Sprintf("/api/schedules/%d", schedule.ID), ""},
+		{"GET", fmt.Sprintf("/api/prescriptions/%d", prescription.ID), ""},
+		{"GET", fmt.Sprintf("/api/medical_records/%d", medicalRecord.ID), ""},
+	}
+	for _, test := range hidden {
+		w := request(otherToken, test.method, test.path, test.body)
+		assert.Equal(t, http.StatusNotFound, w.Code, "%s %s", test.method, test.path)
+	}
+	var appointments []model.Appointment
+	json.Unmarshal(request(otherToken, "GET", "/api/appointments/", "").Body.Bytes(), &appointments)
+	assert.Empty(t, appointments)
+	var prescriptions []model.Prescription
+	json.Unmarshal(request(otherToken, "GET", "/api/prescriptions", "").Body.Bytes(), &prescriptions)
+	assert.Empty(t, prescriptions)
+	// Nothing was changed
+	db.First(&appointment, appointment.ID)
+	assert.Equal(t, patientID, appointment.PatientID)
+	assert.NotEqual(t, model.AppointmentCancelled, appointment.Status)
+	assert.True(t, timeStart.Equal(appointment.TimeStart))
+
+	// Patient reads own appointment and prescription but cannot change the prescription
+	patientToken := makeToken(t, patientID, "patient@test.com", false)
+	assert.Equal(t, http.StatusOK, request(patientToken, "GET", fmt.Sprintf("/api/appointments/%d", appointment.ID), "").Code)
+	json.Unmarshal(request(patientToken, "GET", "/api/appointments/", "").Body.Bytes(), &appointments)
+	if assert.Len(t, appointments, 1) {
+		assert.Equal(t, appointment.ID, appointments[0].ID)
+	}
+	assert.Equal(t, http.StatusOK, request(patientToken, "GET", fmt.Sprintf("/api/prescriptions/%d", prescription.ID), "").Code)
+	assert.Equal(t, http.StatusForbidden, request(patientToken, "PUT", fmt.Sprintf("/api/prescriptions/%d", prescription.ID), prescriptionBody).Code)
+	// Doctor of another patient cannot read the medical record either
+	otherDoctorToken := makeToken(t, uuid.Must(uuid.NewV4()), "other.doctor@test.com", true)
+	assert.Equal(t, http.StatusNotFound, request(otherDoctorToken, "GET", fmt.Sprintf("/api/medical_records/%d", medicalRecord.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, request(otherDoctorToken, "PUT", fmt.Sprintf("/api/prescriptions/%d", prescription.ID), prescriptionBody).Code)
+}
+
+func TestAuditLogRecordsMedicalDataAccess(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding routes
+	r.PUT("api/prescriptions/:id", middleware.RequirePermission(auth.ResourcePrescription, auth.ActionUpdate), controller.UpdatePrescription(db))
+	r.GET("api/medical_records/:id", middleware.RequirePermission(auth.ResourceMedicalRecord, auth.ActionRead), controller.GetMedicalRecord(db))
+	r.GET("api/audit", middleware.RequirePermission(auth.ResourceAudit, auth.ActionRead), controller.GetAuditLog(db))
+	r.GET("api/audit/verify", middleware.RequirePermission(auth.ResourceAudit, auth.ActionRead), controller.VerifyAuditLog(db))
+
+	// Prescription and medical record of the patient
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	prescription := model.Prescription{DrugName: "Aspirin", Dosage: "100mg", DoctorID: doctorID, DoctorEmail: "doctor@test.com",
+		PatientID: patientID, PatientEmail: "patient@test.com"}
+	medicalRecord := model.MedicalRecord{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: patientID,
+		PatientEmail: "patient@test.com", Text: "Diagnosis"}
+	for _, object := range []interface{}{&prescription, &medicalRecord} {
+		if err := db.Create(object).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(object)
+	}
+
+	request := func(token, method, path, body, requestID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	fetchAudit := func(token, requestID string) []model.AuditEntry {
+		var entries []model.AuditEntry
+		w := request(token, "GET", "/api/audit?request_id="+requestID, "", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		json.Unmarshal(w.Body.Bytes(), &entries)
+		return entries
+	}
+	doctorToken := makeToken(t, doctorID, "doctor@test.com", true)
+	adminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "admin@test.com", []string{auth.RoleSystemAdmin}, 1)
+
+	// Doctor changes dosage of prescription, change is recorded with request id
+	updateID := "audit-update-" + uuid.Must(uuid.NewV4()).String()
+	prescriptionBody := fmt.Sprintf(`{"drug_name": "Aspirin", "dosage": "200mg", "patient_id": "%s", "patient_email": "patient@test.com"}`, patientID)
+	w := request(doctorToken, "PUT", fmt.Sprintf("/api/prescriptions/%d", prescription.ID), prescriptionBody, updateID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, updateID, w.Header().Get("X-Request-ID"))
+	entries := fetchAudit(adminToken, updateID)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, doctorID, entries[0].ActorID)
+		assert.Equal(t, model.AuditUpdate, entries[0].Action)
+		assert.Equal(t, auth.ResourcePrescription, entries[0].ResourceType)
+		assert.Equal(t, fmt.Sprint(prescription.ID), entries[0].ResourceID)
+		var diff map[string]utils.AuditChange
+		json.Unmarshal([]byte(entries[0].Diff), &diff)
+		assert.Equal(t, utils.AuditChange{Old: "100mg", New: "200mg"}, diff["Dosage"])
+	}
+
+	// Reading medical record is recorded too
+	readID := "audit-read-" + uuid.Must(uuid.NewV4()).String()
+	assert.Equal(t, http.StatusOK, request(doctorToken, "GET", fmt.Sprintf("/api/medical_records/%d", medicalRecord.ID), "", readID).Code)
+	entries = fetchAudit(adminToken, readID)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, model.AuditRead, entries[0].Action)
+		assert.Equal(t, auth.ResourceMedicalRecord, entries[0].ResourceType)
+		assert.Equal(t, fmt.Sprint(medicalRecord.ID), entries[0].ResourceID)
+	}
+
+	// Only admins query audit log
+	assert.Equal(t, http.StatusForbidden, request(doctorToken, "GET", "/api/audit", "", "").Code)
+	// Hash chain is intact
+	var verification utils.AuditVerification
+	w = request(adminToken, "GET", "/api/audit/verify", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &verification)
+	assert.True(t, verification.Valid)
+	assert.NotEmpty(t, verification.LastHash)
+	// Audit entries cannot be changed or removed
+	assert.Error(t, db.Model(&model.AuditEntry{}).Where("request_id = ?", readID).Update("actor_email", "someone@test.com").Error)
+	assert.Error(t, db.Where("request_id = ?", readID).Delete(&model.AuditEntry{}).Error)
+}
+
+func TestClinicWideTimeOffBlocksOnlyItsClinic(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding routes
+	r.GET("api/time_off", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetTimeOffList(db))
+	r.POST("api/time_off", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionCreate), controller.CreateTimeOff(db))
+	r.GET("api/time_off/:id/conflicts", middleware.RequirePermission(auth.ResourceSchedule, auth.ActionRead), controller.GetTimeOffConflicts(db))
+
+	// Doctors of two clinics with appointments in holiday range
+	clinic := model.Clinic{Name: "Holiday clinic", TimeZone: "UTC"}
+	otherClinic := model.Clinic{Name: "Working clinic", TimeZone: "UTC"}
+	for _, object := range []*model.Clinic{&clinic, &otherClinic} {
+		if err := db.Create(object).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(object)
+	}
+	doctorID := uuid.Must(uuid.NewV4())
+	otherDoctorID := uuid.Must(uuid.NewV4())
+	profiles := []model.UserProfile{
+		{UserID: doctorID, Email: "doctor@test.com", ClinicID: &clinic.ID},
+		{UserID: otherDoctorID, Email: "other@test.com", ClinicID: &otherClinic.ID},
+	}
+	if err := db.Create(&profiles).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&profiles)
+	timeStart := time.Now().UTC().AddDate(5, 1, 0).Truncate(time.Hour)
+	appointments := []model.Appointment{
+		{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: uuid.Must(uuid.NewV4()), PatientEmail: "patient@test.com",
+			TimeStart: timeStart, TimeEnd: timeStart.Add(time.Hour)},
+		{DoctorID: otherDoctorID, DoctorEmail: "other@test.com", PatientID: uuid.Must(uuid.NewV4()), PatientEmail: "other.patient@test.com",
+			TimeStart: timeStart, TimeEnd: timeStart.Add(time.Hour)},
+	}
+	if err := db.Create(&appointments).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&appointments)
+
+	createTimeOff := func(token string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(map[string]interface{}{"time_start": timeStart.Add(-time.Hour), "time_end": timeStart.Add(24 * time.Hour),
+			"clinic_wide": true, "clinic_id": clinic.ID, "reason": "Holiday"})
+		req, _ := http.NewRequest("POST", "/api/time_off", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Doctors and admins of another clinic cannot create holiday of the clinic
+	assert.Equal(t, http.StatusForbidden, createTimeOff(makeToken(t, doctorID, "doctor@test.com", true)).Code)
+	otherAdminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "other.admin@test.com", []string{auth.RoleClinicAdmin}, otherClinic.ID)
+	assert.Equal(t, http.StatusForbidden, createTimeOff(otherAdminToken).Code)
+
+	// Clinic admin creates holiday, response lists only conflicts of the clinic
+	adminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "admin@test.com", []string{auth.RoleClinicAdmin}, clinic.ID)
+	w := createTimeOff(adminToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response controller.TimeOffResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	defer db.Unscoped().Delete(&model.TimeOff{}, response.TimeOff.ID)
+	if assert.NotNil(t, response.TimeOff.ClinicID) {
+		assert.Equal(t, clinic.ID, *response.TimeOff.ClinicID)
+	}
+	if assert.Len(t, response.ConflictingAppointments, 1) {
+		assert.Equal(t, appointments[0].ID, response.ConflictingAppointments[0].ID)
+	}
+
+	// Holiday blocks doctor of the clinic only
+	timeOffs, err := utils.FindTimeOff(db, doctorID, timeStart, timeStart.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, timeOffs, 1)
+	timeOffs, err = utils.FindTimeOff(db, otherDoctorID, timeStart, timeStart.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, timeOffs)
+
+	get := func(token string, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	listURL := "/api/time_off?doctor_id=" + doctorID.String()
+	conflictsURL := fmt.Sprintf("/api/time_off/%d/conflicts", response.TimeOff.ID)
+
+	// Time-off of the doctor is hidden from patients and staff of another clinic
+	patientToken := makeToken(t, appointments[0].PatientID, "patient@test.com", false)
+	assert.Equal(t, http.StatusNotFound, get(patientToken, listURL).Code)
+	assert.Equal(t, http.StatusNotFound, get(otherAdminToken, listURL).Code)
+	assert.Equal(t, http.StatusOK, get(makeToken(t, doctorID, "doctor@test.com", true), listURL).Code)
+	assert.Equal(t, http.StatusOK, get(adminToken, listURL).Code)
+
+	// Conflicts are fetched only by admins managing the clinic of the holiday
+	assert.Equal(t, http.StatusForbidden, get(otherAdminToken, conflictsURL).Code)
+	assert.Equal(t, http.StatusForbidden, get(makeToken(t, doctorID, "doctor@test.com", true), conflictsURL).Code)
+	assert.Equal(t, http.StatusOK, get(adminToken, conflictsURL).Code)
+}
+
+func TestClinicWebhookReceivesEventsOfClinic(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	// Doctors of two clinics, integration subscribed to events of the first one
+	clinic := model.Clinic{Name: "EHR clinic", TimeZone: "UTC"}
+	otherClinic := model.Clinic{Name: "Other EHR clinic", TimeZone: "UTC"}
+	for _, object := range []*model.Clinic{&clinic, &otherClinic} {
+		if err := db.Create(object).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(object)
+	}
+	doctorID := uuid.Must(uuid.NewV4())
+	otherDoctorID := uuid.Must(uuid.NewV4())
+	profiles := []model.UserProfile{
+		{UserID: doctorID, Email: "doctor@test.com", ClinicID: &clinic.ID},
+		{UserID: otherDoctorID, Email: "other@test.com", ClinicID: &otherClinic.ID},
+	}
+	if err := db.Create(&profiles).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&profiles)
+	subscription := model.WebhookSubscription{URL: "https://ehr.example.com/hooks", Secret: "0123456789abcdef",
+		Scope: model.WebhookScopeClinic, ClinicID: &clinic.ID, CreatedBy: uuid.Must(uuid.NewV4())}
+	if err := db.Create(&subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&subscription)
+	defer db.Where("subscription_id = ?", subscription.ID).Delete(&model.WebhookDelivery{})
+
+	// Integration is not participant, it receives events of doctors of the clinic only
+	patientID := uuid.Must(uuid.NewV4())
+	if err := utils.EmitEvent(db, utils.EventMedicalRecordCreated, model.MedicalRecord{Text: "Healthy"}, doctorID, patientID); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.EmitEvent(db, utils.EventMedicalRecordCreated, model.MedicalRecord{Text: "Other"}, otherDoctorID, patientID); err != nil {
+		t.Fatal(err)
+	}
+	var deliveries []model.WebhookDelivery
+	db.Where("subscription_id = ?", subscription.ID).Find(&deliveries)
+	if assert.Len(t, deliveries, 1) {
+		assert.Contains(t, deliveries[0].Payload, "Healthy")
+	}
+
+	// Integration subscribes only to events of clinic of its token, patients do not subscribe at all
+	r := gin.Default()
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	r.POST("api/webhooks", middleware.RequirePermission(auth.ResourceWebhook, auth.ActionCreate),
+		controller.CreateWebhook(db, webhook.NewSender(time.Second, true)))
+	createWebhook := func(token, scope string, clinicID uint) int {
+		payload, _ := json.Marshal(map[string]interface{}{"url": "https://ehr.example.com/hooks", "scope": scope, "clinic_id": clinicID})
+		req, _ := http.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	integrationID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("created_by = ?", integrationID).Delete(&model.WebhookSubscription{})
+	integrationToken := makeRoleToken(t, integrationID, "ehr@test.com", []string{auth.RoleIntegration}, clinic.ID)
+	assert.Equal(t, http.StatusCreated, createWebhook(integrationToken, model.WebhookScopeClinic, clinic.ID))
+	assert.Equal(t, http.StatusForbidden, createWebhook(integrationToken, model.WebhookScopeClinic, otherClinic.ID))
+	assert.Equal(t, http.StatusForbidden, createWebhook(integrationToken, model.WebhookScopeSystem, 0))
+	assert.Equal(t, http.StatusForbidden, createWebhook(makeToken(t, patientID, "patient@test.com", false), model.WebhookScopeUser, 0))
+}
+
+func TestWaitlistOffersKeepOtherHolds(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	// Doctor works four hours, patient holds the last half an hour at checkout
+	// and waits for the first and the second hour in two waitlist entries
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().AddDate(5, 2, 0).Truncate(time.Hour)
+	schedule := model.Schedule{DoctorID: doctorID, DoctorEmail: "doctor@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(4 * time.Hour)}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&schedule)
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.SlotHold{})
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.Appointment{})
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.WaitlistEntry{})
+	checkout := model.SlotHold{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: patientID, PatientEmail: "patient@test.com",
+		TimeStart: timeStart.Add(210 * time.Minute), TimeEnd: timeStart.Add(4 * time.Hour), ExpiresAt: time.Now().Add(10 * time.Minute)}
+	if err := utils.PlaceHold(db, &checkout); err != nil {
+		t.Fatal(err)
+	}
+	entries := []model.WaitlistEntry{
+		{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: patientID, PatientEmail: "patient@test.com",
+			TimeFrom: timeStart, TimeTo: timeStart.Add(time.Hour), Duration: 30 * time.Minute, Status: model.WaitlistWaiting},
+		{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: patientID, PatientEmail: "patient@test.com",
+			TimeFrom: timeStart.Add(time.Hour), TimeTo: timeStart.Add(2 * time.Hour), Duration: 30 * time.Minute, Status: model.WaitlistWaiting},
+	}
+	if err := db.Create(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Both entries get offers, no offer releases checkout hold or the other offer
+	if err := utils.ProcessWaitlist(db, doctorID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		db.First(&entries[i], entries[i].ID)
+		assert.Equal(t, model.WaitlistOffered, entries[i].Status)
+		if assert.NotNil(t, entries[i].HoldID) {
+			var hold model.SlotHold
+			assert.NoError(t, db.First(&hold, *entries[i].HoldID).Error)
+			assert.True(t, hold.WaitlistOffer)
+			assert.True(t, timeStart.Add(time.Duration(i)*time.Hour).Equal(hold.TimeStart))
+		}
+	}
+	assert.NoError(t, db.First(&model.SlotHold{}, checkout.ID).Error)
+	// New checkout hold of the patient keeps offers too
+	secondCheckout := checkout
+	secondCheckout.ID = 0
+	secondCheckout.TimeStart = timeStart.Add(3 * time.Hour)
+	secondCheckout.TimeEnd = timeStart.Add(210 * time.Minute)
+	assert.NoError(t, utils.PlaceHold(db, &secondCheckout))
+	assert.Error(t, db.First(&model.SlotHold{}, checkout.ID).Error)
+	assert.NoError(t, db.First(&model.SlotHold{}, *entries[0].HoldID).Error)
+	assert.NoError(t, db.First(&model.SlotHold{}, *entries[1].HoldID).Error)
+
+	// Accepted offer books held slot
+	appointment, err := utils.AcceptWaitlistOffer(db, &entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, timeStart.Equal(appointment.TimeStart))
+	assert.True(t, timeStart.Add(30*time.Minute).Equal(appointment.TimeEnd))
+	db.First(&entries[0], entries[0].ID)
+	assert.Equal(t, model.WaitlistBooked, entries[0].Status)
+	_, err = utils.AcceptWaitlistOffer(db, &entries[0])
+	assert.ErrorIs(t, err, utils.ErrOfferNotActive)
+
+	// Outdated offer expires and releases its hold
+	db.Model(&entries[1]).Update("offer_expires_at", time.Now().Add(-time.Minute))
+	doctors, err := utils.ExpireWaitlist(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, doctors, doctorID)
+	db.First(&entries[1], entries[1].ID)
+	assert.Equal(t, model.WaitlistExpired, entries[1].Status)
+	assert.Error(t, db.First(&model.SlotHold{}, *entries[1].HoldID).Error)
+	_, err = utils.AcceptWaitlistOffer(db, &entries[1])
+	assert.ErrorIs(t, err, utils.ErrOfferNotActive)
+}
+
+func TestConcurrentWaitlistProcessingOffersOnce(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	// Doctor works two hours, patient waits for any half an hour of them
+	doctorID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().AddDate(5, 2, 7).Truncate(time.Hour)
+	schedule := model.Schedule{DoctorID: doctorID, DoctorEmail: "doctor@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(2 * time.Hour)}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&schedule)
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.SlotHold{})
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.WaitlistEntry{})
+	entry := model.WaitlistEntry{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: uuid.Must(uuid.NewV4()), PatientEmail: "patient@test.com",
+		TimeFrom: timeStart, TimeTo: timeStart.Add(2 * time.Hour), Duration: 30 * time.Minute, Status: model.WaitlistWaiting}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("user_id = ?", entry.PatientID).Delete(&model.Notification{})
+
+	// Time of the doctor is freed by several requests at once, the entry gets one offer
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, utils.ProcessWaitlist(db, doctorID, time.Hour))
+		}()
+	}
+	wg.Wait()
+	var holds []model.SlotHold
+	db.Where("doctor_id = ?", doctorID).Find(&holds)
+	assert.Len(t, holds, 1)
+	var notifications int64
+	db.Model(&model.Notification{}).Where("user_id = ?", entry.PatientID).Count(&notifications)
+	assert.Equal(t, int64(1), notifications)
+	db.First(&entry, entry.ID)
+	assert.Equal(t, model.WaitlistOffered, entry.Status)
+	if assert.NotNil(t, entry.HoldID) && assert.Len(t, holds, 1) {
+		assert.Equal(t, holds[0].ID, *entry.HoldID)
+	}
+}
+
+func TestSlotHoldExclusionConfirmationAndExpiry(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	// Doctor works two hours, patient holds the first half an hour
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	otherPatientID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().AddDate(5, 3, 0).Truncate(time.Hour)
+	schedule := model.Schedule{DoctorID: doctorID, DoctorEmail: "doctor@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(2 * time.Hour)}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&schedule)
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.SlotHold{})
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.Appointment{})
+	newHold := func(patientID uuid.UUID, start time.Time, expiresAt time.Time) model.SlotHold {
+		return model.SlotHold{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: patientID, PatientEmail: "patient@test.com",
+			TimeStart: start, TimeEnd: start.Add(30 * time.Minute), ExpiresAt: expiresAt}
+	}
+	hold := newHold(patientID, timeStart, time.Now().Add(10*time.Minute))
+	if err := utils.PlaceHold(db, &hold); err != nil {
+		t.Fatal(err)
+	}
+
+	// Held time is excluded from slots, holds and bookings of other patients
+	slots, err := utils.GetFreeSlots(db, doctorID, timeStart, timeStart.Add(time.Hour), 30*time.Minute, 30*time.Minute, 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, slots, 1) {
+		assert.True(t, timeStart.Add(30*time.Minute).Equal(slots[0].TimeStart))
+	}
+	otherHold := newHold(otherPatientID, timeStart.Add(15*time.Minute), time.Now().Add(10*time.Minute))
+	assert.ErrorIs(t, utils.PlaceHold(db, &otherHold), utils.ErrSlotTaken)
+	otherAppointment := model.Appointment{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: otherPatientID,
+		PatientEmail: "other@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(30 * time.Minute)}
+	assert.ErrorIs(t, utils.BookAppointment(db, &otherAppointment), utils.ErrSlotTaken)
+
+	// Confirmed hold becomes appointment and stops holding time
+	appointment, err := utils.ConfirmHold(db, &hold, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, timeStart.Equal(appointment.TimeStart))
+	assert.Equal(t, patientID, appointment.PatientID)
+	assert.Equal(t, &appointment.ID, hold.AppointmentID)
+	var active int64
+	utils.ActiveHolds(db).Model(&model.SlotHold{}).Where("doctor_id = ?", doctorID).Count(&active)
+	assert.Zero(t, active)
+
+	// Expired hold cannot be confirmed, sweeper releases it for other patients
+	expired := newHold(patientID, timeStart.Add(time.Hour), time.Now().Add(-time.Minute))
+	if err := db.Create(&expired).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, err = utils.ConfirmHold(db, &expired, nil)
+	assert.ErrorIs(t, err, utils.ErrHoldExpired)
+	released, err := utils.SweepExpiredHolds(db)
+	assert.NoError(t, err)
+	ids := []uint{}
+	for _, h := range released {
+		ids = append(ids, h.ID)
+	}
+	assert.Contains(t, ids, expired.ID)
+	assert.Error(t, db.First(&model.SlotHold{}, expired.ID).Error)
+	otherHold = newHold(otherPatientID, timeStart.Add(time.Hour), time.Now().Add(10*time.Minute))
+	assert.NoError(t, utils.PlaceHold(db, &otherHold))
+}
+
+func TestClinicMembershipManagedByAdmins(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding routes
+	r.PUT("api/profile", middleware.RequirePermission(auth.ResourceProfile, auth.ActionUpdate), controller.UpdateProfile(db))
+	r.PUT("api/clinics/:id/members/:user_id", middleware.RequirePermission(auth.ResourceClinic, auth.ActionManage), controller.AddClinicMember(db))
+	r.DELETE("api/clinics/:id/members/:user_id", middleware.RequirePermission(auth.ResourceClinic, auth.ActionManage), controller.DeleteClinicMember(db))
+
+	// Doctor belongs to first clinic
+	clinic := model.Clinic{Name: "First clinic", TimeZone: "UTC"}
+	otherClinic := model.Clinic{Name: "Second clinic", TimeZone: "UTC"}
+	for _, object := range []*model.Clinic{&clinic, &otherClinic} {
+		if err := db.Create(object).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(object)
+	}
+	doctorID := uuid.Must(uuid.NewV4())
+	profile := model.UserProfile{UserID: doctorID, Email: "doctor@test.com", ClinicID: &clinic.ID}
+	if err := db.Create(&profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("user_id = ?", doctorID).Delete(&model.UserProfile{})
+	doctorToken := makeToken(t, doctorID, "doctor@test.com", true)
+	send := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	clinicOf := func() *uint {
+		var stored model.UserProfile
+		db.Where("user_id = ?", doctorID).First(&stored)
+		return stored.ClinicID
+	}
+	membersPath := func(clinicID uint) string {
+		return fmt.Sprintf("/api/clinics/%d/members/%s", clinicID, doctorID)
+	}
+
+	// Doctor cannot move himself to another clinic
+	w := send("PUT", "/api/profile", doctorToken, map[string]interface{}{"clinic_id": otherClinic.ID, "time_zone": "UTC"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &clinic.ID, clinicOf())
+	assert.Equal(t, http.StatusForbidden, send("PUT", membersPath(otherClinic.ID), doctorToken, nil).Code)
+
+	// Admin of another clinic cannot take doctor over
+	otherAdminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "other.admin@test.com", []string{auth.RoleClinicAdmin}, otherClinic.ID)
+	assert.Equal(t, http.StatusForbidden, send("PUT", membersPath(otherClinic.ID), otherAdminToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, send("DELETE", membersPath(clinic.ID), otherAdminToken, nil).Code)
+	assert.Equal(t, &clinic.ID, clinicOf())
+
+	// Admin of the clinic releases doctor, clinic admins cannot take over the doctor without clinic
+	adminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "admin@test.com", []string{auth.RoleClinicAdmin}, clinic.ID)
+	assert.Equal(t, http.StatusOK, send("DELETE", membersPath(clinic.ID), adminToken, nil).Code)
+	assert.Nil(t, clinicOf())
+	assert.Equal(t, http.StatusNotFound, send("DELETE", membersPath(clinic.ID), adminToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, send("PUT", membersPath(otherClinic.ID), otherAdminToken, nil).Code)
+	assert.Equal(t, http.StatusForbidden, send("PUT", membersPath(clinic.ID), adminToken, nil).Code)
+	assert.Nil(t, clinicOf())
+
+	// System admin assigns the doctor to another clinic
+	systemAdminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "system.admin@test.com", []string{auth.RoleSystemAdmin}, 0)
+	assert.Equal(t, http.StatusOK, send("PUT", membersPath(otherClinic.ID), systemAdminToken, nil).Code)
+	assert.Equal(t, &otherClinic.ID, clinicOf())
+}
+
+func TestAppointmentTypeManagedByClinicAdmin(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding routes
+	r.PUT("api/appointment_types/:id", middleware.RequirePermission(auth.ResourceAppointmentType, auth.ActionUpdate), controller.UpdateAppointmentType(db))
+	r.DELETE("api/appointment_types/:id", middleware.RequirePermission(auth.ResourceAppointmentType, auth.ActionDelete), controller.DeleteAppointmentType(db))
+
+	// Doctor of the clinic created appointment type
+	clinic := model.Clinic{Name: "Type clinic", TimeZone: "UTC"}
+	otherClinic := model.Clinic{Name: "Other type clinic", TimeZone: "UTC"}
+	for _, object := range []*model.Clinic{&clinic, &otherClinic} {
+		if err := db.Create(object).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(object)
+	}
+	doctorID := uuid.Must(uuid.NewV4())
+	profile := model.UserProfile{UserID: doctorID, Email: "doctor@test.com", ClinicID: &clinic.ID}
+	if err := db.Create(&profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("user_id = ?", doctorID).Delete(&model.UserProfile{})
+	appointmentType := model.AppointmentType{Name: "Consult", Duration: 30 * time.Minute, CreatedBy: doctorID}
+	if err := db.Create(&appointmentType).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&appointmentType)
+	send := func(method, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, fmt.Sprintf("/api/appointment_types/%d", appointmentType.ID),
+			strings.NewReader(`{"name": "Long consult", "duration_minutes": 45}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Another doctor and admin of another clinic cannot change the type
+	assert.Equal(t, http.StatusForbidden, send("PUT", makeToken(t, uuid.Must(uuid.NewV4()), "other@test.com", true)).Code)
+	otherAdminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "other.admin@test.com", []string{auth.RoleClinicAdmin}, otherClinic.ID)
+	assert.Equal(t, http.StatusForbidden, send("PUT", otherAdminToken).Code)
+	assert.Equal(t, http.StatusForbidden, send("DELETE", otherAdminToken).Code)
+
+	// Admin of the clinic fixes and retires the type
+	adminToken := makeRoleToken(t, uuid.Must(uuid.NewV4()), "admin@test.com", []string{auth.RoleClinicAdmin}, clinic.ID)
+	assert.Equal(t, http.StatusOK, send("PUT", adminToken).Code)
+	var stored model.AppointmentType
+	db.First(&stored, appointmentType.ID)
+	assert.Equal(t, "Long consult", stored.Name)
+	assert.Equal(t, doctorID, stored.CreatedBy)
+	assert.Equal(t, http.StatusNoContent, send("DELETE", adminToken).Code)
+	assert.Error(t, db.First(&model.AppointmentType{}, appointmentType.ID).Error)
+}
+
+func TestNotificationsStreamedOnCommit(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	userID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	hub := stream.NewMemoryHub()
+	subscription := hub.Subscribe(userID)
+	defer subscription.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go utils.ListenNotifications(ctx, db, hub)
+	// Giving listener time to subscribe to channel
+	time.Sleep(500 * time.Millisecond)
+	create := func(tx *gorm.DB) {
+		if err := utils.CreateNotification(tx, "Prescription", "patient@test.com", userID, templates.Data{Prescription: &model.Prescription{DrugName: "Aspirin"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rolled back notification is never streamed
+	tx := db.Begin()
+	create(tx)
+	tx.Rollback()
+	// Committed notification is streamed after commit, without waiting for outbox worker
+	tx = db.Begin()
+	create(tx)
+	select {
+	case <-subscription.C:
+		t.Fatal("Notification streamed before commit")
+	case <-time.After(300 * time.Millisecond):
+	}
+	assert.NoError(t, tx.Commit().Error)
+	var stored model.Notification
+	db.Where("user_id = ?", userID).First(&stored)
+	select {
+	case notification := <-subscription.C:
+		assert.Equal(t, stored.ID, notification.ID)
+		assert.Equal(t, "Prescription", notification.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notification was not streamed")
+	}
+	select {
+	case notification := <-subscription.C:
+		t.Fatalf("Unexpected notification %d", notification.ID)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestSlotHoldsKeepBuffersFree(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	// Doctor works three hours and has appointment in the last hour
+	doctorID := uuid.Must(uuid.NewV4())
+	timeStart := time.Now().UTC().AddDate(5, 4, 0).Truncate(time.Hour)
+	schedule := model.Schedule{DoctorID: doctorID, DoctorEmail: "doctor@test.com", TimeStart: timeStart, TimeEnd: timeStart.Add(3 * time.Hour)}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&schedule)
+	defer db.Unscoped().Where("doctor_id = ?", doctorID).Delete(&model.SlotHold{})
+	appointment := model.Appointment{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: uuid.Must(uuid.NewV4()),
+		PatientEmail: "patient@test.com", TimeStart: timeStart.Add(2 * time.Hour), TimeEnd: timeStart.Add(3 * time.Hour)}
+	if err := utils.BookAppointment(db, &appointment); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&appointment)
+	newHold := func(start time.Time, bufferBefore, bufferAfter time.Duration) model.SlotHold {
+		return model.SlotHold{DoctorID: doctorID, DoctorEmail: "doctor@test.com", PatientID: uuid.Must(uuid.NewV4()), PatientEmail: "patient@test.com",
+			TimeStart: start, TimeEnd: start.Add(30 * time.Minute), ExpiresAt: time.Now().Add(10 * time.Minute),
+			BufferBefore: bufferBefore, BufferAfter: bufferAfter}
+	}
+
+	// Hold with 15 minutes cleanup after visit
+	hold := newHold(timeStart, 0, 15*time.Minute)
+	if err := utils.PlaceHold(db, &hold); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, timeStart.Add(45*time.Minute).Equal(hold.BlockEnd))
+	// Buffer of hold is taken, as well as time next to it for visit with preparation
+	adjacent := newHold(timeStart.Add(30*time.Minute), 0, 0)
+	assert.ErrorIs(t, utils.PlaceHold(db, &adjacent), utils.ErrSlotTaken)
+	prepared := newHold(timeStart.Add(time.Hour), 30*time.Minute, 0)
+	assert.ErrorIs(t, utils.PlaceHold(db, &prepared), utils.ErrSlotTaken)
+	afterBuffer := newHold(timeStart.Add(45*time.Minute), 0, 0)
+	assert.NoError(t, utils.PlaceHold(db, &afterBuffer))
+	// Buffer of hold cannot overlap appointment
+	beforeAppointment := newHold(timeStart.Add(90*time.Minute), 0, 10*time.Minute)
+	assert.ErrorIs(t, utils.PlaceHold(db, &beforeAppointment), utils.ErrSlotTaken)
+
+	// Free slots skip held buffers
+	slots, err := utils.GetFreeSlots(db, doctorID, timeStart, timeStart.Add(2*time.Hour), 30*time.Minute, 15*time.Minute, 0, 0)
+	assert.NoError(t, err)
+	for _, slot := range slots {
+		assert.False(t, slot.TimeStart.Before(timeStart.Add(75*time.Minute)), "slot at %v", slot.TimeStart)
+	}
+}
+
+func TestNotificationStreamOutOfOrderCommits(t *testing.T) {
+	// Declaring router
+	r := gin.Default()
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+	//Adding middleware
+	r.Use(middleware.AuthMiddleware(config.SetupTokenVerifier()))
+	//Adding route
+	hub := stream.NewMemoryHub()
+	r.GET("api/notifications/stream", controller.StreamNotifications(db, hub, time.Minute))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	userID := uuid.Must(uuid.NewV4())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/notifications/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+makeToken(t, userID, "patient@test.com", false))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Transaction of notification 5 commits before transaction of notification 4
+	hub.Publish(model.Notification{Model: gorm.Model{ID: 5}, Type: "Create", UserID: userID})
+	hub.Publish(model.Notification{Model: gorm.Model{ID: 4}, Type: "Change", UserID: userID})
+	hub.Publish(model.Notification{Model: gorm.Model{ID: 5}, Type: "Create", UserID: userID})
+	hub.Publish(model.Notification{Model: gorm.Model{ID: 6}, Type: "Cancel", UserID: userID})
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for len(ids) < 3 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "id:") {
+			ids = append(ids, strings.TrimSpace(strings.TrimPrefix(line, "id:")))
+		}
+	}
+	// Both are sent, repeated one only once
+	assert.Equal(t, []string{"5", "4", "6"}, ids)
+}
+
+func TestWebhookChannelQueuesUserSubscriptions(t *testing.T) {
+	// Declaring DB
+	db := config.SetupDatabaseConnection()
+	defer config.CloseDatabaseConnection(db)
+
+	// User receives notifications only through his webhook
+	userID := uuid.Must(uuid.NewV4())
+	defer db.Unscoped().Where("user_id = ?", userID).Delete(&model.Notification{})
+	defer db.Where("user_id = ?", userID).Delete(&model.NotificationPreference{})
+	preferences := []model.NotificationPreference{
+		{UserID: userID, Type: model.AnyNotificationType, Channel: model.ChannelEmail, Enabled: false},
+		{UserID: userID, Type: model.AnyNotificationType, Channel: model.ChannelWebhook, Enabled: true},
+	}
+	if err := db.Create(&preferences).Error; err != nil {
+		t.Fatal(err)
+	}
+	subscription := model.WebhookSubscription{URL: "https://ehr.example.com/hooks", Secret: "0123456789abcdef",
+		Scope: model.WebhookScopeUser, CreatedBy: userID}
+	if err := db.Create(&subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&subscription)
+	defer db.Where("subscription_id = ?", subscription.ID).Delete(&model.WebhookDelivery{})
+	if err := utils.CreateNotification(db, "Prescription", "patient@test.com", userID, templates.Data{Prescription: &model.Prescription{DrugName: "Aspirin"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := utils.DeliveryPolicy{MaxAttempts: 1}
+	channels := map[string]notifier.Notifier{model.ChannelWebhook: utils.NewWebhookNotifier(db)}
+	if _, err := utils.DeliverNotifications(db, channels, policy); err != nil {
+		t.Fatal(err)
+	}
+	var notification model.Notification
+	db.Preload("Deliveries").Where("user_id = ?", userID).First(&notification)
+	assert.Equal(t, model.NotificationSent, notification.DeliveryStatus)
+	var deliveries []model.WebhookDelivery
+	db.Where("subscription_id = ?", subscription.ID).Find(&deliveries)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, utils.EventNotificationCreated, deliveries[0].Event)
+		assert.Equal(t, model.WebhookPending, deliveries[0].Status)
+		assert.Contains(t, deliveries[0].Payload, notification.Subject)
+	}
+}
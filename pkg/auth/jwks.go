@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("Unknown signing key")
+
+// Shortest interval between reloads of keys, protects key source from floods of tokens with unknown kid
+var minRefreshInterval = 10 * time.Second
+
+// Largest accepted JWKS document
+const maxJWKSSize = 1 << 20
+
+// Key of JSON Web Key Set (RFC 7517), only public key fields are read
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Public key with algorithm it may be used with, empty algorithm means any algorithm of its type
+type publicKey struct {
+	key       interface{}
+	algorithm string
+}
+
+// JWKS loads RSA and ECDSA public keys from local file or URL
+// Keys are cached for ttl and reloaded earlier when token is signed with unknown kid, so keys can rotate
+// Expired keys are served while they are reloaded, only one load runs at a time
+type JWKS struct {
+	source string
+	ttl    time.Duration
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]publicKey
+	loadedAt    time.Time
+	refreshedAt time.Time
+	loading     *jwksLoad
+}
+
+// Load of keys in progress, done is closed when it finishes
+type jwksLoad struct {
+	done chan struct{}
+	err  error
+}
+
+func NewJWKS(source string, ttl time.Duration) *JWKS {
+	//Source is http(s) URL or path of local file
+	return &JWKS{source: source, ttl: ttl, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *JWKS) Key(kid string) (interface{}, string, error) {
+	//Returning key with kid and its algorithm, token without kid may use the only key of set
+	//Requests wait for load only when there are no keys yet or kid is unknown
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.keys == nil || now.Sub(s.loadedAt) >= s.ttl {
+		load := s.refresh(now)
+		if s.keys == nil && load != nil {
+			if err := s.wait(load); err != nil && s.keys == nil {
+				return nil, "", err
+			}
+		}
+	}
+	key, ok := s.lookup(kid)
+	if !ok {
+		//Key may be added by rotation after last load
+		if load := s.refresh(now); load != nil {
+			if err := s.wait(load); err != nil {
+				return nil, "", err
+			}
+			key, ok = s.lookup(kid)
+		}
+	}
+	if !ok {
+		return nil, "", ErrUnknownKey
+	}
+	return key.key, key.algorithm, nil
+}
+
+func (s *JWKS) refresh(now time.Time) *jwksLoad {
+	//Starting load of keys unless one is running, s.mu must be held
+	//Returns nil when keys were reloaded less than minRefreshInterval ago
+	if s.loading != nil {
+		return s.loading
+	}
+	if now.Sub(s.refreshedAt) < minRefreshInterval {
+		return nil
+	}
+	s.refreshedAt = now
+	load := &jwksLoad{done: make(chan struct{})}
+	s.loading = load
+	go s.load(load, now)
+	return load
+}
+
+func (s *JWKS) wait(load *jwksLoad) error {
+	//Releasing s.mu until load finishes, so cached keys are served meanwhile
+	s.mu.Unlock()
+	<-load.done
+	s.mu.Lock()
+	return load.err
+}
+
+func (s *JWKS) lookup(kid string) (publicKey, bool) {
+	if kid == "" {
+		if len(s.keys) != 1 {
+			return publicKey{}, false
+		}
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *JWKS) load(load *jwksLoad, now time.Time) {
+	//Reading keys without holding s.mu, previous keys are kept when source is unavailable
+	keys, err := s.read()
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+		s.loadedAt = now
+	}
+	load.err = err
+	s.loading = nil
+	s.mu.Unlock()
+	close(load.done)
+}
+
+func (s *JWKS) read() (map[string]publicKey, error) {
+	data, err := s.readSource()
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func (s *JWKS) readSource() ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(s.source)
+	}
+	response, err := s.client.Get(s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS source responded with status %d", response.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxJWKSSize))
+}
+
+func parseJWKS(data []byte) (map[string]publicKey, error) {
+	//Parsing signature keys of JWKS document by kid, encryption keys and unsupported key types are skipped
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("Invalid JWKS: %v", err)
+	}
+	keys := map[string]publicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key interface{}
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecdsaKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid JWKS key %q: %v", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = publicKey{key: key, algorithm: jwk.Alg}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no signature keys")
+	}
+	return keys, nil
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func (jwk jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeInt(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeInt(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent is too large")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk jsonWebKey) ecdsaKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, err := decodeInt(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeInt(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"ScheduleAPI/pkg/model"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Roles seeing objects of doctors of their clinic, system admin sees objects of any doctor
+var clinicStaffRoles = []string{RoleNurse, RoleReceptionist, RoleClinicAdmin, RoleSystemAdmin}
+
+// Resources with patient_id column, patient of object may read it
+var patientResources = map[string]bool{
+	ResourceAppointment:   true,
+	ResourcePrescription:  true,
+	ResourceMedicalRecord: true,
+}
+
+// Policy decides which objects of doctors and patients the principal may read and change
+// Objects are described by resource, doctor and patient (uuid.Nil for objects without patient)
+type Policy struct {
+	db        *gorm.DB
+	principal Principal
+	clinics   map[uuid.UUID]*uint
+}
+
+func NewPolicy(db *gorm.DB, principal Principal) *Policy {
+	return &Policy{db: db, principal: principal, clinics: map[uuid.UUID]*uint{}}
+}
+
+func (p *Policy) CanRead(resource string, doctorID, patientID uuid.UUID) bool {
+	//Doctor and patient of object read it, staff reads objects of doctors of their clinic
+	if !p.principal.Can(resource, ActionRead) {
+		return false
+	}
+	if p.principal.ID == doctorID || (patientResources[resource] && p.principal.ID == patientID) {
+		return true
+	}
+	for _, role := range clinicStaffRoles {
+		if p.principal.HasRole(role) && Allowed([]string{role}, resource, ActionRead) && p.covers(role, doctorID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) CanModify(resource string, doctorID, patientID uuid.UUID) bool {
+	//Doctor of object changes it, patient changes only own appointments
+	//Users with manage permission change objects of doctors of their clinic
+	if p.principal.ID == doctorID || (resource == ResourceAppointment && p.principal.ID == patientID) {
+		return true
+	}
+	return p.Manages(resource, doctorID)
+}
+
+func (p *Policy) Manages(resource string, doctorID uuid.UUID) bool {
+	//Checking user may manage resource of another doctor
+	for _, role := range p.principal.Roles {
+		if Allowed([]string{role}, resource, ActionManage) && p.covers(role, doctorID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) ManagesClinic(resource string, clinicID uint) bool {
+	//Checking user may manage resource of the whole clinic, e.g. clinic-wide holidays
+	for _, role := range p.principal.Roles {
+		if Allowed([]string{role}, resource, ActionManage) &&
+			(role == RoleSystemAdmin || (p.principal.ClinicID != nil && *p.principal.ClinicID == clinicID)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) Scope(resource string) func(db *gorm.DB) *gorm.DB {
+	//Query scope limiting list of resource to objects the principal may read
+	return func(db *gorm.DB) *gorm.DB {
+		if !p.principal.Can(resource, ActionRead) {
+			return db.Where("1 = 0")
+		}
+		condition := p.db.Where("doctor_id = ?", p.principal.ID)
+		if patientResources[resource] {
+			condition = condition.Or("patient_id = ?", p.principal.ID)
+		}
+		for _, role := range clinicStaffRoles {
+			if !p.principal.HasRole(role) || !Allowed([]string{role}, resource, ActionRead) {
+				continue
+			}
+			if role == RoleSystemAdmin {
+				return db
+			}
+			if p.principal.ClinicID != nil {
+				condition = condition.Or("doctor_id IN (?)", p.db.Model(&model.UserProfile{}).
+					Select("user_id").Where("clinic_id = ?", *p.principal.ClinicID))
+			}
+		}
+		return db.Where(condition)
+	}
+}
+
+func (p *Policy) covers(role string, doctorID uuid.UUID) bool {
+	//System admin covers any doctor, other roles only doctors of clinic of their token
+	if role == RoleSystemAdmin {
+		return true
+	}
+	if p.principal.ClinicID == nil || doctorID == uuid.Nil {
+		return false
+	}
+	clinicID, ok := p.clinics[doctorID]
+	if !ok {
+		var profile model.UserProfile
+		if err := p.db.Where("user_id = ?", doctorID).First(&profile).Error; err == nil {
+			clinicID = profile.ClinicID
+		}
+		p.clinics[doctorID] = clinicID
+	}
+	return clinicID != nil && *clinicID == *p.principal.ClinicID
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	principal := func(id uuid.UUID, roles ...string) *Policy {
+		// Principals without clinic never need profiles of doctors
+		return NewPolicy(nil, Principal{ID: id, Roles: roles})
+	}
+	doctor := principal(doctorID, RoleDoctor)
+	patient := principal(patientID, RolePatient)
+	otherPatient := principal(uuid.Must(uuid.NewV4()), RolePatient)
+	otherDoctor := principal(uuid.Must(uuid.NewV4()), RoleDoctor)
+	nurse := principal(uuid.Must(uuid.NewV4()), RoleNurse)
+	admin := principal(uuid.Must(uuid.NewV4()), RoleSystemAdmin)
+
+	tests := []struct {
+		name     string
+		policy   *Policy
+		resource string
+		read     bool
+		modify   bool
+	}{
+		{"doctor appointment", doctor, ResourceAppointment, true, true},
+		{"patient appointment", patient, ResourceAppointment, true, true},
+		{"other patient appointment", otherPatient, ResourceAppointment, false, false},
+		{"other doctor appointment", otherDoctor, ResourceAppointment, false, false},
+		{"system admin appointment", admin, ResourceAppointment, true, true},
+		{"nurse without clinic appointment", nurse, ResourceAppointment, false, false},
+		{"doctor prescription", doctor, ResourcePrescription, true, true},
+		{"patient prescription", patient, ResourcePrescription, true, false},
+		{"other patient prescription", otherPatient, ResourcePrescription, false, false},
+		{"patient medical record", patient, ResourceMedicalRecord, true, false},
+		{"system admin medical record", admin, ResourceMedicalRecord, false, false},
+		{"doctor schedule", doctor, ResourceSchedule, true, true},
+		{"patient schedule", patient, ResourceSchedule, false, false},
+		{"system admin schedule", admin, ResourceSchedule, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.read, test.policy.CanRead(test.resource, doctorID, patientID))
+			assert.Equal(t, test.modify, test.policy.CanModify(test.resource, doctorID, patientID))
+		})
+	}
+}
+
+func TestPolicyManages(t *testing.T) {
+	doctorID := uuid.Must(uuid.NewV4())
+	// System admin manages any doctor, doctors manage nobody, clinic roles without clinic manage nobody
+	admin := NewPolicy(nil, Principal{ID: uuid.Must(uuid.NewV4()), Roles: []string{RoleSystemAdmin}})
+	assert.True(t, admin.Manages(ResourceSchedule, doctorID))
+	assert.True(t, admin.Manages(ResourceAppointment, doctorID))
+	doctor := NewPolicy(nil, Principal{ID: uuid.Must(uuid.NewV4()), Roles: []string{RoleDoctor}})
+	assert.False(t, doctor.Manages(ResourceSchedule, doctorID))
+	receptionist := NewPolicy(nil, Principal{ID: uuid.Must(uuid.NewV4()), Roles: []string{RoleReceptionist}})
+	assert.False(t, receptionist.Manages(ResourceAppointment, doctorID))
+}
+
+func TestPolicyManagesClinic(t *testing.T) {
+	clinicID := uint(1)
+	// Clinic admin manages own clinic, system admin any clinic, doctors and receptionists none
+	clinicAdmin := NewPolicy(nil, Principal{Roles: []string{RoleClinicAdmin}, ClinicID: &clinicID})
+	assert.True(t, clinicAdmin.ManagesClinic(ResourceSchedule, 1))
+	assert.False(t, clinicAdmin.ManagesClinic(ResourceSchedule, 2))
+	admin := NewPolicy(nil, Principal{Roles: []string{RoleSystemAdmin}})
+	assert.True(t, admin.ManagesClinic(ResourceSchedule, 2))
+	doctor := NewPolicy(nil, Principal{Roles: []string{RoleDoctor}, ClinicID: &clinicID})
+	assert.False(t, doctor.ManagesClinic(ResourceSchedule, 1))
+	receptionist := NewPolicy(nil, Principal{Roles: []string{RoleReceptionist}, ClinicID: &clinicID})
+	assert.False(t, receptionist.ManagesClinic(ResourceSchedule, 1))
+	// Clinic members are assigned only by admins
+	assert.True(t, clinicAdmin.ManagesClinic(ResourceClinic, 1))
+	assert.False(t, doctor.ManagesClinic(ResourceClinic, 1))
+}
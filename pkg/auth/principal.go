@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"ScheduleAPI/pkg/utils"
+	"fmt"
+	"math"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Key of Principal in gin context
+const principalKey = "principal"
+
+// Authenticated user of request
+type Principal struct {
+	ID       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Roles    []string  `json:"roles"`
+	ClinicID *uint     `json:"clinic_id"`
+}
+
+func (p Principal) HasRole(role string) bool {
+	for _, value := range p.Roles {
+		if value == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Principal) IsDoctor() bool {
+	return p.HasRole(RoleDoctor)
+}
+
+func PrincipalFromClaims(claims jwt.MapClaims) (Principal, error) {
+	//Reading user from verified claims, malformed claims are errors instead of panics
+	//User id is "user_id" claim or standard "sub", roles are "roles" (array or string)
+	//Tokens with only legacy "is_doctor" claim get doctor or patient role
+	var principal Principal
+	subject, ok := claims["user_id"].(string)
+	if !ok {
+		subject, ok = claims["sub"].(string)
+	}
+	if !ok {
+		return principal, fmt.Errorf("%w: user_id claim is required", ErrInvalidToken)
+	}
+	id, err := uuid.FromString(subject)
+	if err != nil || id == uuid.Nil {
+		return principal, fmt.Errorf("%w: user_id claim must be UUID", ErrInvalidToken)
+	}
+	principal.ID = id
+	email, _ := claims["email"].(string)
+	if !utils.IsValidEmail(email) {
+		return principal, fmt.Errorf("%w: invalid email claim", ErrInvalidToken)
+	}
+	principal.Email = email
+	switch roles := claims["roles"].(type) {
+	case nil:
+	case string:
+		principal.Roles = []string{roles}
+	case []interface{}:
+		for _, value := range roles {
+			role, ok := value.(string)
+			if !ok || role == "" {
+				return principal, fmt.Errorf("%w: roles claim must contain strings", ErrInvalidToken)
+			}
+			principal.Roles = append(principal.Roles, role)
+		}
+	default:
+		return principal, fmt.Errorf("%w: roles claim must be array of strings", ErrInvalidToken)
+	}
+	if len(principal.Roles) == 0 {
+		switch isDoctor := claims["is_doctor"].(type) {
+		case nil:
+			principal.Roles = []string{RolePatient}
+		case bool:
+			principal.Roles = []string{RolePatient}
+			if isDoctor {
+				principal.Roles = []string{RoleDoctor}
+			}
+		default:
+			return principal, fmt.Errorf("%w: is_doctor claim must be boolean", ErrInvalidToken)
+		}
+	}
+	if value, ok := claims["clinic_id"]; ok && value != nil {
+		number, ok := value.(float64)
+		if !ok || number < 1 || number > math.MaxUint32 || number != math.Trunc(number) {
+			return principal, fmt.Errorf("%w: clinic_id claim must be positive integer", ErrInvalidToken)
+		}
+		clinicID := uint(number)
+		principal.ClinicID = &clinicID
+	}
+	return principal, nil
+}
+
+func SetPrincipal(c *gin.Context, principal Principal) {
+	c.Set(principalKey, principal)
+}
+
+func CurrentPrincipal(c *gin.Context) Principal {
+	//Principal stored by AuthMiddleware, empty principal for unauthenticated request
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(Principal); ok {
+			return principal
+		}
+	}
+	return Principal{}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalFromClaims(t *testing.T) {
+	id := uuid.Must(uuid.NewV4())
+	principal, err := PrincipalFromClaims(jwt.MapClaims{"user_id": id.String(), "email": "doc@example.com",
+		"roles": []interface{}{"doctor", "clinic_admin"}, "clinic_id": float64(3)})
+	assert.NoError(t, err)
+	assert.Equal(t, id, principal.ID)
+	assert.Equal(t, "doc@example.com", principal.Email)
+	assert.True(t, principal.IsDoctor())
+	assert.True(t, principal.HasRole("clinic_admin"))
+	if assert.NotNil(t, principal.ClinicID) {
+		assert.Equal(t, uint(3), *principal.ClinicID)
+	}
+
+	// Legacy tokens carry only is_doctor claim, standard sub claim is accepted as user id
+	principal, err = PrincipalFromClaims(jwt.MapClaims{"sub": id.String(), "email": "doc@example.com", "is_doctor": true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{RoleDoctor}, principal.Roles)
+	principal, err = PrincipalFromClaims(jwt.MapClaims{"user_id": id.String(), "email": "patient@example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{RolePatient}, principal.Roles)
+	assert.Nil(t, principal.ClinicID)
+}
+
+func TestPrincipalFromMalformedClaims(t *testing.T) {
+	id := uuid.Must(uuid.NewV4()).String()
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"no user id", jwt.MapClaims{"email": "a@example.com"}},
+		{"numeric user id", jwt.MapClaims{"user_id": 42, "email": "a@example.com"}},
+		{"invalid uuid", jwt.MapClaims{"user_id": "not-uuid", "email": "a@example.com"}},
+		{"nil uuid", jwt.MapClaims{"user_id": uuid.Nil.String(), "email": "a@example.com"}},
+		{"no email", jwt.MapClaims{"user_id": id}},
+		{"invalid email", jwt.MapClaims{"user_id": id, "email": "not an email"}},
+		{"string is_doctor", jwt.MapClaims{"user_id": id, "email": "a@example.com", "is_doctor": "true"}},
+		{"numeric roles", jwt.MapClaims{"user_id": id, "email": "a@example.com", "roles": []interface{}{1}}},
+		{"object roles", jwt.MapClaims{"user_id": id, "email": "a@example.com", "roles": map[string]interface{}{}}},
+		{"negative clinic", jwt.MapClaims{"user_id": id, "email": "a@example.com", "clinic_id": float64(-1)}},
+		{"fractional clinic", jwt.MapClaims{"user_id": id, "email": "a@example.com", "clinic_id": 1.5}},
+		{"string clinic", jwt.MapClaims{"user_id": id, "email": "a@example.com", "clinic_id": "1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := PrincipalFromClaims(test.claims)
+			assert.True(t, errors.Is(err, ErrInvalidToken), "got %v", err)
+		})
+	}
+}
+
+func TestCurrentPrincipal(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	// Request without principal gets empty one instead of panic
+	assert.Equal(t, uuid.Nil, CurrentPrincipal(c).ID)
+	principal := Principal{ID: uuid.Must(uuid.NewV4()), Email: "a@example.com", Roles: []string{RolePatient}}
+	SetPrincipal(c, principal)
+	assert.Equal(t, principal, CurrentPrincipal(c))
+}
@@ -0,0 +1,97 @@
+package auth
+
+// Roles of users
+const (
+	RolePatient      = "patient"
+	RoleDoctor       = "doctor"
+	RoleNurse        = "nurse"
+	RoleReceptionist = "receptionist"
+	RoleClinicAdmin  = "clinic_admin"
+	RoleSystemAdmin  = "system_admin"
+	//Service account of external system (EHR, billing) receiving webhooks
+	RoleIntegration = "integration"
+)
+
+var Roles = []string{RolePatient, RoleDoctor, RoleNurse, RoleReceptionist, RoleClinicAdmin, RoleSystemAdmin, RoleIntegration}
+
+// Resources protected by permissions
+const (
+	ResourceProfile         = "profile"
+	ResourceClinic          = "clinic"
+	ResourceSchedule        = "schedule"
+	ResourceAppointmentType = "appointment_type"
+	ResourceAppointment     = "appointment"
+	ResourceHold            = "hold"
+	ResourceWaitlist        = "waitlist"
+	ResourceNotification    = "notification"
+	ResourceWebhook         = "webhook"
+	ResourcePrescription    = "prescription"
+	ResourceMedicalRecord   = "medical_record"
+	ResourceAgendaDigest    = "agenda_digest"
+	ResourceAudit           = "audit"
+)
+
+// Actions on resources, manage allows acting on objects of other users
+const (
+	ActionRead   = "read"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionManage = "manage"
+)
+
+var (
+	bookingRoles  = []string{RolePatient, RoleDoctor, RoleReceptionist, RoleClinicAdmin, RoleSystemAdmin}
+	clinicalRoles = []string{RolePatient, RoleDoctor, RoleNurse}
+	staffRoles    = []string{RoleDoctor, RoleClinicAdmin, RoleSystemAdmin}
+	adminRoles    = []string{RoleClinicAdmin, RoleSystemAdmin}
+	doctorRoles   = []string{RoleDoctor}
+	//Webhooks make the server send requests to given URLs, only admins and integrations register them
+	integrationRoles = []string{RoleIntegration, RoleClinicAdmin, RoleSystemAdmin}
+)
+
+// Roles allowed to perform action on resource
+// Handlers still check that object belongs to the user unless the user may manage the resource
+var permissions = map[string]map[string][]string{
+	ResourceProfile: {ActionRead: Roles, ActionUpdate: Roles},
+	//Admins assign doctors to their clinic ("manage" permission)
+	ResourceClinic: {ActionRead: Roles, ActionCreate: staffRoles, ActionUpdate: staffRoles, ActionManage: adminRoles},
+	//Schedules, recurring schedules and time-off of doctors, admins manage schedules of any doctor
+	ResourceSchedule: {ActionRead: Roles, ActionCreate: staffRoles, ActionUpdate: staffRoles,
+		ActionDelete: staffRoles, ActionManage: adminRoles},
+	//Doctors create appointment types, admins fix and retire types of doctors of their clinic
+	ResourceAppointmentType: {ActionRead: Roles, ActionCreate: doctorRoles, ActionUpdate: staffRoles,
+		ActionDelete: staffRoles, ActionManage: adminRoles},
+	//Receptionists and admins book appointments for patients
+	ResourceAppointment: {ActionRead: Roles, ActionCreate: bookingRoles, ActionUpdate: bookingRoles,
+		ActionDelete: bookingRoles, ActionManage: []string{RoleReceptionist, RoleClinicAdmin, RoleSystemAdmin}},
+	ResourceHold:         {ActionRead: bookingRoles, ActionCreate: bookingRoles, ActionUpdate: bookingRoles, ActionDelete: bookingRoles},
+	ResourceWaitlist:     {ActionRead: bookingRoles, ActionCreate: bookingRoles, ActionUpdate: bookingRoles, ActionDelete: bookingRoles},
+	ResourceNotification: {ActionRead: Roles, ActionUpdate: Roles, ActionDelete: Roles},
+	//Integrations and admins subscribe to events of the whole clinic ("manage" permission)
+	ResourceWebhook: {ActionRead: integrationRoles, ActionCreate: integrationRoles, ActionUpdate: integrationRoles,
+		ActionDelete: integrationRoles, ActionManage: integrationRoles},
+	ResourcePrescription: {ActionRead: clinicalRoles, ActionCreate: doctorRoles, ActionUpdate: doctorRoles,
+		ActionDelete: doctorRoles},
+	ResourceMedicalRecord: {ActionRead: clinicalRoles, ActionCreate: doctorRoles, ActionUpdate: doctorRoles,
+		ActionDelete: doctorRoles},
+	ResourceAgendaDigest: {ActionRead: doctorRoles},
+	//Audit log covers every clinic, only system admins query it
+	ResourceAudit: {ActionRead: []string{RoleSystemAdmin}},
+}
+
+func Allowed(roles []string, resource, action string) bool {
+	//Checking any of roles may perform action on resource, unknown resources and actions are denied
+	for _, allowed := range permissions[resource][action] {
+		for _, role := range roles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p Principal) Can(resource, action string) bool {
+	return Allowed(p.Roles, resource, action)
+}
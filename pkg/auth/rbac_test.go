@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionMatrix(t *testing.T) {
+	// Roles allowed for every resource and action, the rest of roles are denied
+	const (
+		all      = "patient doctor nurse receptionist clinic_admin system_admin integration"
+		booking  = "patient doctor receptionist clinic_admin system_admin"
+		clinical = "patient doctor nurse"
+		staff    = "doctor clinic_admin system_admin"
+		admins   = "clinic_admin system_admin"
+		doctor   = "doctor"
+		webhooks = "integration clinic_admin system_admin"
+		none     = ""
+	)
+	matrix := []struct {
+		resource string
+		action   string
+		roles    string
+	}{
+		{ResourceProfile, ActionRead, all},
+		{ResourceProfile, ActionCreate, none},
+		{ResourceProfile, ActionUpdate, all},
+		{ResourceProfile, ActionDelete, none},
+		{ResourceProfile, ActionManage, none},
+		{ResourceClinic, ActionRead, all},
+		{ResourceClinic, ActionCreate, staff},
+		{ResourceClinic, ActionUpdate, staff},
+		{ResourceClinic, ActionDelete, none},
+		{ResourceClinic, ActionManage, admins},
+		{ResourceSchedule, ActionRead, all},
+		{ResourceSchedule, ActionCreate, staff},
+		{ResourceSchedule, ActionUpdate, staff},
+		{ResourceSchedule, ActionDelete, staff},
+		{ResourceSchedule, ActionManage, admins},
+		{ResourceAppointmentType, ActionRead, all},
+		{ResourceAppointmentType, ActionCreate, doctor},
+		{ResourceAppointmentType, ActionUpdate, staff},
+		{ResourceAppointmentType, ActionDelete, staff},
+		{ResourceAppointmentType, ActionManage, admins},
+		{ResourceAppointment, ActionRead, all},
+		{ResourceAppointment, ActionCreate, booking},
+		{ResourceAppointment, ActionUpdate, booking},
+		{ResourceAppointment, ActionDelete, booking},
+		{ResourceAppointment, ActionManage, "receptionist clinic_admin system_admin"},
+		{ResourceHold, ActionRead, booking},
+		{ResourceHold, ActionCreate, booking},
+		{ResourceHold, ActionUpdate, booking},
+		{ResourceHold, ActionDelete, booking},
+		{ResourceHold, ActionManage, none},
+		{ResourceWaitlist, ActionRead, booking},
+		{ResourceWaitlist, ActionCreate, booking},
+		{ResourceWaitlist, ActionUpdate, booking},
+		{ResourceWaitlist, ActionDelete, booking},
+		{ResourceWaitlist, ActionManage, none},
+		{ResourceNotification, ActionRead, all},
+		{ResourceNotification, ActionCreate, none},
+		{ResourceNotification, ActionUpdate, all},
+		{ResourceNotification, ActionDelete, all},
+		{ResourceNotification, ActionManage, none},
+		{ResourceWebhook, ActionRead, webhooks},
+		{ResourceWebhook, ActionCreate, webhooks},
+		{ResourceWebhook, ActionUpdate, webhooks},
+		{ResourceWebhook, ActionDelete, webhooks},
+		{ResourceWebhook, ActionManage, webhooks},
+		{ResourcePrescription, ActionRead, clinical},
+		{ResourcePrescription, ActionCreate, doctor},
+		{ResourcePrescription, ActionUpdate, doctor},
+		{ResourcePrescription, ActionDelete, doctor},
+		{ResourcePrescription, ActionManage, none},
+		{ResourceMedicalRecord, ActionRead, clinical},
+		{ResourceMedicalRecord, ActionCreate, doctor},
+		{ResourceMedicalRecord, ActionUpdate, doctor},
+		{ResourceMedicalRecord, ActionDelete, doctor},
+		{ResourceMedicalRecord, ActionManage, none},
+		{ResourceAgendaDigest, ActionRead, doctor},
+		{ResourceAgendaDigest, ActionCreate, none},
+		{ResourceAgendaDigest, ActionUpdate, none},
+		{ResourceAgendaDigest, ActionDelete, none},
+		{ResourceAgendaDigest, ActionManage, none},
+		{ResourceAudit, ActionRead, "system_admin"},
+		{ResourceAudit, ActionCreate, none},
+		{ResourceAudit, ActionUpdate, none},
+		{ResourceAudit, ActionDelete, none},
+		{ResourceAudit, ActionManage, none},
+	}
+	covered := map[string]bool{}
+	for _, entry := range matrix {
+		covered[entry.resource] = true
+		allowed := strings.Fields(entry.roles)
+		for _, role := range Roles {
+			want := false
+			for _, value := range allowed {
+				want = want || value == role
+			}
+			assert.Equal(t, want, Allowed([]string{role}, entry.resource, entry.action),
+				"%s %s %s", role, entry.action, entry.resource)
+		}
+	}
+	// Every resource of permission matrix is covered by the table
+	for resource := range permissions {
+		assert.True(t, covered[resource], "resource %s is not covered", resource)
+	}
+}
+
+func TestAllowedWithSeveralRoles(t *testing.T) {
+	// Any role of user grants permission, unknown roles grant nothing
+	assert.True(t, Allowed([]string{RolePatient, RoleReceptionist}, ResourceAppointment, ActionManage))
+	assert.False(t, Allowed([]string{"superuser"}, ResourceSchedule, ActionRead))
+	assert.False(t, Allowed(nil, ResourceProfile, ActionRead))
+	assert.False(t, Allowed([]string{RoleSystemAdmin}, "unknown", ActionRead))
+	principal := Principal{Roles: []string{RoleDoctor}}
+	assert.True(t, principal.Can(ResourceMedicalRecord, ActionCreate))
+	assert.False(t, principal.Can(ResourceSchedule, ActionManage))
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrInvalidToken = errors.New("Invalid token")
+	ErrTokenExpired = errors.New("Token is expired")
+)
+
+// Verifier checks signature and registered claims of bearer tokens
+type Verifier struct {
+	//Accepted signing algorithms, e.g. RS256, ES256, HS256
+	Algorithms []string
+	//Shared secret of HS* tokens
+	Secret []byte
+	//Public keys of RS* and ES* tokens
+	Keys *JWKS
+	//Expected "iss" and "aud" claims, empty value is not checked
+	Issuer   string
+	Audience string
+	//Allowed clock difference with token issuer
+	Leeway time.Duration
+	//Current time, replaced in tests
+	Now func() time.Time
+}
+
+func (v *Verifier) Verify(tokenString string) (jwt.MapClaims, error) {
+	//Parsing token signed with one of accepted algorithms and validating exp, nbf, iss and aud claims
+	//Token must expire, nbf is optional
+	parser := jwt.Parser{ValidMethods: v.Algorithms, SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, v.key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, unwrapValidationError(err))
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, v.validateClaims(claims)
+}
+
+func unwrapValidationError(err error) error {
+	var validationError *jwt.ValidationError
+	if errors.As(err, &validationError) && validationError.Inner != nil {
+		return validationError.Inner
+	}
+	return err
+}
+
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	//Choosing verification key by algorithm family and "kid" header
+	algorithm := token.Method.Alg()
+	switch {
+	case strings.HasPrefix(algorithm, "HS"):
+		if len(v.Secret) == 0 {
+			return nil, errors.New("HMAC tokens are not accepted")
+		}
+		return v.Secret, nil
+	case strings.HasPrefix(algorithm, "RS"), strings.HasPrefix(algorithm, "ES"):
+		if v.Keys == nil {
+			return nil, errors.New("No public keys configured")
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, keyAlgorithm, err := v.Keys.Key(kid)
+		if err != nil {
+			return nil, err
+		}
+		//Key published for one algorithm cannot verify another one
+		if keyAlgorithm != "" && keyAlgorithm != algorithm {
+			return nil, fmt.Errorf("Key %q is not used with %s", kid, algorithm)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("Unsupported signing algorithm %s", algorithm)
+}
+
+func (v *Verifier) validateClaims(claims jwt.MapClaims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	expiresAt, ok, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: exp claim is required", ErrInvalidToken)
+	}
+	if !now.Before(expiresAt.Add(v.Leeway)) {
+		return ErrTokenExpired
+	}
+	notBefore, ok, err := numericDate(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(v.Leeway).Before(notBefore) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if v.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != v.Issuer {
+			return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+		}
+	}
+	if v.Audience != "" && !hasAudience(claims["aud"], v.Audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+func numericDate(claims jwt.MapClaims, name string) (time.Time, bool, error) {
+	//Reading NumericDate claim (seconds since epoch), JSON numbers are decoded as float64
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	seconds, ok := value.(float64)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %s claim must be a number", ErrInvalidToken, name)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true, nil
+}
+
+func hasAudience(value interface{}, audience string) bool {
+	//"aud" claim is a string or an array of strings
+	switch aud := value.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, item := range aud {
+			if item == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
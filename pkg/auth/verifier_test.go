@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+		"n": encodeInt(key.N), "e": encodeInt(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": encodeInt(key.X), "y": encodeInt(key.Y)}
+}
+
+// Test JWKS endpoint with replaceable key set
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     []map[string]string
+	requests int32
+}
+
+func newJWKSServer(keys ...map[string]string) *jwksServer {
+	server := &jwksServer{keys: keys}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&server.requests, 1)
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": server.keys})
+	}))
+	return server
+}
+
+func (s *jwksServer) setKeys(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	tokenString, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenString
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"user_id": "0ec638e3-c9aa-4fd3-9f6d-a738a42a9b5b",
+		"iss":     "https://id.example.com",
+		"aud":     []string{"schedule-api", "billing"},
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"nbf":     time.Now().Add(-time.Minute).Unix(),
+	}
+}
+
+func TestVerifyJWKSTokens(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := newJWKSServer(rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+	defer server.Close()
+	verifier := &Verifier{
+		Algorithms: []string{"RS256", "ES256"},
+		Keys:       NewJWKS(server.URL, time.Hour),
+		Issuer:     "https://id.example.com",
+		Audience:   "schedule-api",
+	}
+
+	claims, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, "0ec638e3-c9aa-4fd3-9f6d-a738a42a9b5b", claims["user_id"])
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims()))
+	assert.NoError(t, err)
+	// Keys are cached
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.requests))
+
+	// Token signed by other key with known kid
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", otherKey, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	// Key of RS256 cannot verify RS512 token
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS512, "rsa-1", rsaKey, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	// kid selects EC key for RS256 token
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, "ec-1", rsaKey, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newJWKSServer(rsaJWK("rsa-1", rsaKey))
+	defer server.Close()
+	verifier := &Verifier{Algorithms: []string{"RS256"}, Keys: NewJWKS(server.URL, time.Hour)}
+
+	// HS256 token using public key as secret
+	publicKey, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	_, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, "rsa-1", publicKey, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	// Unsigned token
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	// Malformed token
+	_, err = verifier.Verify("not.a.token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	previousInterval := minRefreshInterval
+	minRefreshInterval = 0
+	defer func() { minRefreshInterval = previousInterval }()
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newJWKSServer(rsaJWK("2026-01", oldKey))
+	defer server.Close()
+	verifier := &Verifier{Algorithms: []string{"RS256"}, Keys: NewJWKS(server.URL, time.Hour)}
+
+	_, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, "2026-01", oldKey, validClaims()))
+	assert.NoError(t, err)
+	// Identity provider publishes new key, unknown kid reloads keys before cache expires
+	server.setKeys(rsaJWK("2026-01", oldKey), rsaJWK("2026-02", newKey))
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, "2026-02", newKey, validClaims()))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, "2026-03", newKey, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyClaims(t *testing.T) {
+	secret := []byte("secret")
+	verifier := &Verifier{Algorithms: []string{"HS256"}, Secret: secret, Issuer: "https://id.example.com", Audience: "schedule-api", Leeway: time.Minute}
+	tests := []struct {
+		name   string
+		change func(claims jwt.MapClaims)
+		err    error
+	}{
+		{"valid", func(claims jwt.MapClaims) {}, nil},
+		{"audience string", func(claims jwt.MapClaims) { claims["aud"] = "schedule-api" }, nil},
+		{"expired within leeway", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-30 * time.Second).Unix() }, nil},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-2 * time.Minute).Unix() }, ErrTokenExpired},
+		{"without exp", func(claims jwt.MapClaims) { delete(claims, "exp") }, ErrInvalidToken},
+		{"exp is not a number", func(claims jwt.MapClaims) { claims["exp"] = "tomorrow" }, ErrInvalidToken},
+		{"not valid yet", func(claims jwt.MapClaims) { claims["nbf"] = time.Now().Add(time.Hour).Unix() }, ErrInvalidToken},
+		{"other issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }, ErrInvalidToken},
+		{"other audience", func(claims jwt.MapClaims) { claims["aud"] = []string{"billing"} }, ErrInvalidToken},
+		{"without audience", func(claims jwt.MapClaims) { delete(claims, "aud") }, ErrInvalidToken},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := validClaims()
+			test.change(claims)
+			_, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, "", secret, claims))
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	data, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		ecJWK("ec-1", ecKey),
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier := &Verifier{Algorithms: []string{"ES256"}, Keys: NewJWKS(path, time.Hour)}
+	// Token without kid uses the only signature key
+	_, err := verifier.Verify(sign(t, jwt.SigningMethodES256, "", ecKey, validClaims()))
+	assert.NoError(t, err)
+}
+
+func TestJWKSServesCachedKeysDuringRefresh(t *testing.T) {
+	previousInterval := minRefreshInterval
+	minRefreshInterval = 0
+	defer func() { minRefreshInterval = previousInterval }()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	// Key source hangs on every load after the first one until released
+	release := make(chan struct{})
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK("rsa-1", rsaKey)}})
+	}))
+	defer server.Close()
+	defer unblock()
+	keys := NewJWKS(server.URL, time.Millisecond)
+	_, _, err := keys.Key("rsa-1")
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	// Expired keys are served while they are reloaded
+	done := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, _, err := keys.Key("rsa-1")
+			done <- err
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("Cached key waited for reload")
+		}
+	}
+
+	// Unknown kid waits for the running reload instead of starting another one
+	go func() {
+		_, _, err := keys.Key("rsa-2")
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("Unknown kid did not wait for reload")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unblock()
+	assert.ErrorIs(t, <-done, ErrUnknownKey)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Signing algorithms supported by token verifier
+var supportedAlgorithms = map[string]bool{
+	"HS256": true, "HS384": true, "HS512": true,
+	"RS256": true, "RS384": true, "RS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+}
+
+func SetupTokenVerifier() *auth.Verifier {
+	//JWKS_URL or JWKS_FILE enables RS256 and ES256 tokens of identity provider, JWT_SECRET enables HS256 tokens
+	//JWT_ALGORITHMS (comma separated) overrides accepted algorithms
+	verifier := &auth.Verifier{
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+		Leeway:   durationSetting("JWT_LEEWAY", 30*time.Second),
+	}
+	var algorithms []string
+	source := os.Getenv("JWKS_URL")
+	if source == "" {
+		source = os.Getenv("JWKS_FILE")
+	}
+	if source != "" {
+		verifier.Keys = auth.NewJWKS(source, durationSetting("JWKS_CACHE_TTL", 10*time.Minute))
+		algorithms = append(algorithms, "RS256", "ES256")
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		verifier.Secret = []byte(secret)
+		algorithms = append(algorithms, "HS256")
+	}
+	if value := os.Getenv("JWT_ALGORITHMS"); value != "" {
+		algorithms = nil
+		for _, algorithm := range strings.Split(value, ",") {
+			algorithm = strings.TrimSpace(algorithm)
+			if !supportedAlgorithms[algorithm] {
+				log.Fatalf("Unsupported JWT_ALGORITHMS value %q", algorithm)
+			}
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	if len(algorithms) == 0 {
+		log.Fatal("Token verification is not configured, set JWT_SECRET or JWKS_URL (JWKS_FILE)")
+	}
+	verifier.Algorithms = algorithms
+	return verifier
+}
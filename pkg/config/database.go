@@ -34,11 +34,68 @@ func SetupDatabaseConnection() *gorm.DB {
 	}
 
 	// AutoMigrate for other models as needed
-	db.AutoMigrate(&model.Appointment{}, &model.Schedule{}, &model.MedicalRecord{}, model.Notification{}, model.Prescription{}, model.Schedule{})
+	db.AutoMigrate(&model.Appointment{}, &model.Schedule{}, &model.MedicalRecord{}, model.Notification{}, model.Prescription{}, model.Schedule{}, &model.RecurringSchedule{}, &model.SlotHold{}, &model.AppointmentType{}, &model.AppointmentTypeDoctor{}, &model.TimeOff{}, &model.Clinic{}, &model.UserProfile{}, &model.AppointmentReschedule{}, &model.WaitlistEntry{}, &model.NotificationOutbox{}, &model.AppointmentReminder{}, &model.NotificationPreference{}, &model.AgendaDigest{}, &model.WebhookSubscription{}, &model.WebhookDelivery{}, &model.WebhookAttempt{}, &model.AuditEntry{})
+	if err := setupConstraints(db); err != nil {
+		panic("Failed to setup database constraints: " + err.Error())
+	}
 
 	return db
 }
 
+// Key of advisory lock serializing schema setup of API instances started together
+const setupLockKey = 7_233_000
+
+// Database level guarantee that appointments of one doctor never overlap
+// Requires btree_gist extension for uuid equality inside gist index
+// Constraint is created only when missing, changed definition needs a new name
+const (
+	overlapConstraint           = "appointments_no_overlap"
+	overlapConstraintDefinition = `EXCLUDE USING gist (doctor_id WITH =, tstzrange(block_start, block_end) WITH &&)
+		WHERE (deleted_at IS NULL AND status NOT IN ('cancelled', 'no_show'))`
+)
+
+func setupConstraints(db *gorm.DB) error {
+	//Setting up data and constraints AutoMigrate does not handle in one transaction,
+	//any failure leaves database unchanged and stops startup
+	statements := []string{
+		//Appointments created before buffers were introduced occupy exactly their time
+		"UPDATE appointments SET block_start = time_start, block_end = time_end WHERE block_start IS NULL OR block_end IS NULL",
+		//Holds placed before buffers were applied to them hold exactly their time
+		"UPDATE slot_holds SET block_start = time_start, block_end = time_end WHERE block_start IS NULL OR block_end IS NULL",
+		//Notifications created before outbox were sent synchronously
+		`UPDATE notifications SET delivery_status = 'sent' WHERE delivery_status = 'pending'
+			AND NOT EXISTS (SELECT 1 FROM notification_outboxes WHERE notification_id = notifications.id)`,
+		//Audit log is append-only, entries cannot be changed or deleted through the database either
+		`CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+			BEGIN RAISE EXCEPTION 'audit log is append-only'; END; $$ LANGUAGE plpgsql`,
+		"DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries",
+		`CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_entries
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_entries_append_only()`,
+		"CREATE EXTENSION IF NOT EXISTS btree_gist",
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", setupLockKey).Error; err != nil {
+			return err
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		var exists bool
+		err := tx.Raw("SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = ? AND conrelid = 'appointments'::regclass)",
+			overlapConstraint).Scan(&exists).Error
+		if err != nil || exists {
+			return err
+		}
+		//Fails when stored appointments already overlap, they have to be resolved before startup
+		if err := tx.Exec("ALTER TABLE appointments ADD CONSTRAINT " + overlapConstraint + " " + overlapConstraintDefinition).Error; err != nil {
+			return fmt.Errorf("adding %s constraint: %w", overlapConstraint, err)
+		}
+		return nil
+	})
+}
+
 func CloseDatabaseConnection(db *gorm.DB) {
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"ScheduleAPI/pkg/notifier"
+	"ScheduleAPI/pkg/sms"
+	"log"
+	"os"
+	"strconv"
+)
+
+func SetupNotifier() notifier.Notifier {
+	//Choosing notification delivery by NOTIFIER environment variable: smtp, log or none
+	//By default SMTP is used when EMAIL_HOST is set, otherwise notifications are only logged
+	kind := os.Getenv("NOTIFIER")
+	if kind == "" {
+		kind = "log"
+		if os.Getenv("EMAIL_HOST") != "" {
+			kind = "smtp"
+		}
+	}
+	switch kind {
+	case "smtp":
+		emailPort, err := strconv.Atoi(os.Getenv("EMAIL_PORT"))
+		if err != nil {
+			log.Fatal("Invalid EMAIL_PORT value: ", err)
+		}
+		return notifier.NewSMTPNotifier(os.Getenv("EMAIL_HOST"), emailPort, os.Getenv("EMAIL_USER"), os.Getenv("EMAIL_PASSWORD"))
+	case "log":
+		return notifier.LogNotifier{}
+	case "none":
+		return notifier.NopNotifier{}
+	}
+	log.Fatalf("Unknown NOTIFIER value %q, use smtp, log or none", kind)
+	return nil
+}
+
+func SetupSMSNotifier() notifier.Notifier {
+	//Choosing SMS provider by SMS_PROVIDER environment variable: twilio, file or none
+	//By default Twilio is used when TWILIO_ACCOUNT_SID is set, otherwise SMS channel is disabled
+	kind := os.Getenv("SMS_PROVIDER")
+	if kind == "" {
+		kind = "none"
+		if os.Getenv("TWILIO_ACCOUNT_SID") != "" {
+			kind = "twilio"
+		}
+	}
+	maxSegments := intSetting("SMS_MAX_SEGMENTS", 3)
+	switch kind {
+	case "twilio":
+		provider := sms.NewTwilioProvider(os.Getenv("TWILIO_BASE_URL"), os.Getenv("TWILIO_ACCOUNT_SID"), os.Getenv("TWILIO_AUTH_TOKEN"), os.Getenv("TWILIO_FROM"))
+		return notifier.NewSMSNotifier(provider, maxSegments)
+	case "file":
+		path := os.Getenv("SMS_FILE")
+		if path == "" {
+			path = "sms.log"
+		}
+		return notifier.NewSMSNotifier(sms.NewFileProvider(path), maxSegments)
+	case "none":
+		return nil
+	}
+	log.Fatalf("Unknown SMS_PROVIDER value %q, use twilio, file or none", kind)
+	return nil
+}
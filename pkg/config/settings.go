@@ -0,0 +1,143 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func durationSetting(name string, defaultValue time.Duration) time.Duration {
+	//Reading duration like "24h" from environment variable
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Printf("Invalid %s value %q, using %s", name, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+func RescheduleMinNotice() time.Duration {
+	//Appointment cannot be moved later than RESCHEDULE_MIN_NOTICE before its start
+	return durationSetting("RESCHEDULE_MIN_NOTICE", 24*time.Hour)
+}
+
+func WaitlistOfferTTL() time.Duration {
+	//Time for waitlisted patient to accept offered slot
+	return durationSetting("WAITLIST_OFFER_TTL", 2*time.Hour)
+}
+
+func intSetting(name string, defaultValue int) int {
+	//Reading positive integer from environment variable
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		log.Printf("Invalid %s value %q, using %d", name, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+func NotificationMaxAttempts() int {
+	//Notification is moved to dead state after NOTIFICATION_MAX_ATTEMPTS failed deliveries
+	return intSetting("NOTIFICATION_MAX_ATTEMPTS", 5)
+}
+
+func NotificationRetryBase() time.Duration {
+	//Delay before the first retry, doubled after every failed attempt
+	return durationSetting("NOTIFICATION_RETRY_BASE", 30*time.Second)
+}
+
+func NotificationRetryMax() time.Duration {
+	//Upper bound of delay between attempts
+	return durationSetting("NOTIFICATION_RETRY_MAX", time.Hour)
+}
+
+func ReminderOffsets() []time.Duration {
+	//Reminders are sent to patient REMINDER_OFFSETS (comma separated durations) before appointment
+	value := os.Getenv("REMINDER_OFFSETS")
+	if value == "" {
+		return []time.Duration{24 * time.Hour, 2 * time.Hour}
+	}
+	var offsets []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		offset, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || offset <= 0 {
+			log.Printf("Invalid REMINDER_OFFSETS value %q, skipping", part)
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+func StreamHeartbeat() time.Duration {
+	//Interval of heartbeat comments keeping notification stream open through proxies
+	return durationSetting("STREAM_HEARTBEAT", 15*time.Second)
+}
+
+func DigestTime() time.Duration {
+	//Local time of doctor ("HH:MM") after which daily agenda digest is sent
+	value := os.Getenv("DIGEST_TIME")
+	if value == "" {
+		return 7 * time.Hour
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		log.Printf("Invalid DIGEST_TIME value %q, using 07:00", value)
+		return 7 * time.Hour
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+func AppBaseURL() string {
+	//Public address of API used in links of notifications, links are relative without it
+	return strings.TrimSuffix(os.Getenv("APP_BASE_URL"), "/")
+}
+
+func WebhookMaxAttempts() int {
+	//Webhook delivery is moved to dead state after WEBHOOK_MAX_ATTEMPTS failed requests
+	return intSetting("WEBHOOK_MAX_ATTEMPTS", 8)
+}
+
+func WebhookRetryBase() time.Duration {
+	//Delay before the first webhook retry, doubled after every failed attempt
+	return durationSetting("WEBHOOK_RETRY_BASE", time.Minute)
+}
+
+func WebhookRetryMax() time.Duration {
+	//Upper bound of delay between webhook attempts
+	return durationSetting("WEBHOOK_RETRY_MAX", 6*time.Hour)
+}
+
+func WebhookAllowPrivate() bool {
+	//Webhooks to loopback, link-local and private addresses are rejected unless WEBHOOK_ALLOW_PRIVATE is true,
+	//enable it only when receivers run in private network of the API
+	allow, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE"))
+	return allow
+}
+
+func WebhookTimeout() time.Duration {
+	//Time for receiver to respond to webhook request
+	return durationSetting("WEBHOOK_TIMEOUT", 10*time.Second)
+}
+
+func TrustedProxies() []string {
+	//Addresses or CIDR ranges (comma separated TRUSTED_PROXIES) whose X-Forwarded-For header is used as client IP,
+	//by default no proxy is trusted and client IP is the address of connection
+	var proxies []string
+	for _, part := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			proxies = append(proxies, part)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+func policy(c *gin.Context, db *gorm.DB) *auth.Policy {
+	//Authorization of objects for user of request
+	return auth.NewPolicy(db, auth.CurrentPrincipal(c))
+}
+
+func authorizeObject(c *gin.Context, db *gorm.DB, action, resource string, doctorID, patientID uuid.UUID) bool {
+	//Checking user may perform action on object of doctor and patient
+	//Objects the user cannot read respond 404 as if they do not exist,
+	//readable objects the user cannot change respond 403
+	access := policy(c, db)
+	name := strings.ReplaceAll(resource, "_", " ")
+	if !access.CanRead(resource, doctorID, patientID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch " + name})
+		return false
+	}
+	if action != auth.ActionRead && !access.CanModify(resource, doctorID, patientID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to " + action + " this " + name})
+		return false
+	}
+	return true
+}
+
+func targetDoctor(c *gin.Context, db *gorm.DB, resource string, doctorID *uuid.UUID) (uuid.UUID, bool) {
+	//Resolving doctor whose object is created: the user or doctor_id of request body when user manages it
+	principal := auth.CurrentPrincipal(c)
+	if doctorID == nil || *doctorID == principal.ID {
+		if !principal.IsDoctor() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "doctor_id is required"})
+			return uuid.Nil, false
+		}
+		return principal.ID, true
+	}
+	if !policy(c, db).Manages(resource, *doctorID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to manage " + resource + " of this doctor"})
+		return uuid.Nil, false
+	}
+	return *doctorID, true
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/config"
+	"ScheduleAPI/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func PreviewAgendaDigest(db *gorm.DB) func(c *gin.Context) {
+	//Rendering daily agenda digest of the doctor without sending it
+	//Optional query parameter: date ("2006-01-02", local day of the doctor), today by default
+	return func(c *gin.Context) {
+		profile, err := fetchProfile(db, c)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		loc := utils.UserLocation(db, profile.UserID)
+		date := time.Now().In(loc)
+		if value := c.Query("date"); value != "" {
+			if date, err = time.ParseInLocation(utils.DigestDateLayout, value, loc); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date parameter, use YYYY-MM-DD format"})
+				return
+			}
+		}
+		data, err := utils.AgendaDigestData(db, profile, date, config.AppBaseURL())
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		content, err := utils.RenderNotification(db, "Digest", profile.UserID, data)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		localize(c, &data.Agenda)
+		c.JSON(http.StatusOK, gin.H{
+			"date":    date.Format(utils.DigestDateLayout),
+			"agenda":  data.Agenda,
+			"subject": content.Subject,
+			"text":    content.Text,
+			"html":    content.HTML,
+		})
+	}
+}
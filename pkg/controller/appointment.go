@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/config"
 	"ScheduleAPI/pkg/model"
 	"ScheduleAPI/pkg/utils"
+	"errors"
 	"net/http"
 	"time"
 
@@ -18,29 +21,40 @@ type AddAppointmentRequestBody struct {
 	PatientEmail string    `json:"patient_email"`
 	TimeStart    time.Time `json:"time_start"`
 	TimeEnd      time.Time `json:"time_end"`
+	//Optional visit type, TimeEnd is derived from its duration
+	AppointmentTypeID *uint `json:"appointment_type_id"`
 }
 
 func GetAppointmentsList(db *gorm.DB) func(c *gin.Context) {
-	//Fetching all Appointment objects belonging to user
+	//Fetching all Appointment objects the user may read
 	return func(c *gin.Context) {
-		//Retrieving user ID from context
-		userID := c.MustGet("uuid").(uuid.UUID)
-		//Fetching all objects belongs to user
+		//Fetching appointments of user, staff also gets appointments of doctors of their clinic
+		//Optional query parameter "status" filters appointments by status
 		var appointments []model.Appointment
-		db.Where("doctor_id = ? OR patient_id = ?", userID).Find(&appointments)
+		query := db.Scopes(policy(c, db).Scope(auth.ResourceAppointment))
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		query.Find(&appointments)
+		localize(c, &appointments)
 		c.JSON(http.StatusOK, appointments)
 	}
 }
 
 func GetAppointment(db *gorm.DB) func(c *gin.Context) {
-	//Fetching Appointment object belonging to user
+	//Fetching Appointment object the user may read
 	return func(c *gin.Context) {
-		//Retrieving user ID from context
-		userID := c.MustGet("uuid").(uuid.UUID)
 		//Retirieving object ID from context
 		id := c.Param("id")
 		var appointment model.Appointment
-		db.Where("doctor_id = ? OR patient_id = ?", userID).First(&appointment, id)
+		if err := db.Preload("Reschedules").Preload("Reminders").First(&appointment, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch appointment"})
+			return
+		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourceAppointment, appointment.DoctorID, appointment.PatientID) {
+			return
+		}
+		localize(c, &appointment)
 		c.JSON(http.StatusOK, appointment)
 	}
 }
@@ -53,7 +67,10 @@ func CreateAppointment(db *gorm.DB) func(c *gin.Context) {
 	//"doctor_id": "0ec638e3-c9aa-4fd3-9f6d-a738a42a9b5b",
 	//"doctor_email":"doctor@test.com",
 	//"patient_id": "0ec638e3-c9aa-4fd3-9f6d-a738a42a9b5b"
-	//"patient_email": "patient@test.com"}
+	//"patient_email": "patient@test.com",
+	//"appointment_type_id": 1}
+	//appointment_type_id is optional, time_end is derived from type duration when it is set
+	//Patients book for themselves, receptionists and admins book for patients of their clinic
 	//USE POST METHOD
 
 	return func(c *gin.Context) {
@@ -63,6 +80,10 @@ func CreateAppointment(db *gorm.DB) func(c *gin.Context) {
 			c.AbortWithError(http.StatusBadRequest, err)
 			return
 		}
+		appointmentType, ok := fetchRequestedAppointmentType(c, db, &body)
+		if !ok {
+			return
+		}
 		//Checking for invalid values in request
 		if body.TimeEnd.Before(body.TimeStart) || body.TimeEnd.Equal(body.TimeStart) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeEnd must be after TimeStart"})
@@ -74,23 +95,12 @@ func CreateAppointment(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
 			return
 		}
-		//Checking if Shedule exists
-		var schedule model.Schedule
-		db.Where("time_start <= ? AND time_end >= ? AND doctor_id = ?", body.TimeStart, body.TimeEnd, body.DoctorID).First(&schedule)
-		if schedule.ID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No free schedules for your request"})
+		if !policy(c, db).CanModify(auth.ResourceAppointment, body.DoctorID, body.PatientID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to book appointment for this patient"})
 			return
 		}
-		//Checking if time is not appointed
-		var appointments []model.Appointment
-		db.Where("time_start <= ? AND time_end >= ?", body.TimeEnd, body.TimeStart).Where("doctor_id = ?", body.DoctorID).Find(&appointments)
-		for _, a := range appointments {
-			if a.TimeStart.Equal(body.TimeStart) || a.TimeEnd.Equal(body.TimeEnd) || (a.TimeStart.Before(body.TimeStart) && a.TimeEnd.After(body.TimeStart)) || (a.TimeStart.Before(body.TimeEnd) && a.TimeEnd.After(body.TimeEnd)) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "This time is already appointed"})
-				return
-			}
-		}
 		//Creating Appointment object
+		//Schedule and overlap checks are done atomically with booking
 		var appointment model.Appointment
 		appointment.DoctorID = body.DoctorID
 		appointment.DoctorEmail = body.DoctorEmail
@@ -98,19 +108,25 @@ func CreateAppointment(db *gorm.DB) func(c *gin.Context) {
 		appointment.PatientEmail = body.PatientEmail
 		appointment.TimeStart = body.TimeStart
 		appointment.TimeEnd = body.TimeEnd
-		if result := db.Create(&appointment); result.Error != nil {
-			c.AbortWithError(http.StatusNotFound, result.Error)
+		utils.ApplyAppointmentType(&appointment, appointmentType)
+		//Creating notifications for doctor and patient together with appointment
+		notificationType := "Create"
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := utils.BookAppointment(tx, &appointment); err != nil {
+				return err
+			}
+			if err := scheduleReminders(tx, appointment); err != nil {
+				return err
+			}
+			if err := utils.NotifyParticipants(tx, notificationType, utils.AppointmentNotificationData(tx, appointment)); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditCreate, auth.ResourceAppointment, appointment.ID, nil, appointment)
+		})
+		if err != nil {
+			bookingErrorResponse(c, err)
 			return
 		}
-		//Creating notifications for doctor and patient
-		notificationType := "Create"
-		notificationText := "Appointment data created"
-		doctorEmail := body.DoctorEmail
-		doctorID := body.DoctorID
-		patientEmail := body.PatientEmail
-		patientID := body.PatientID
-		utils.CreateNotification(db, notificationText, notificationType, doctorEmail, doctorID)
-		utils.CreateNotification(db, notificationText, notificationType, patientEmail, patientID)
 		c.JSON(http.StatusCreated, appointment)
 	}
 }
@@ -130,12 +146,25 @@ func UpdateAppointment(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
 			return
 		}
+		if !authorizeObject(c, db, auth.ActionUpdate, auth.ResourceAppointment, appointment.DoctorID, appointment.PatientID) {
+			return
+		}
+		before := appointment
+		//Completed, cancelled and no-show appointments cannot be changed
+		if utils.IsAppointmentFinal(appointment) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Appointment with status " + appointment.Status + " cannot be changed"})
+			return
+		}
 		//Retrieving request body
 		body := AddAppointmentRequestBody{}
 		if err := c.BindJSON(&body); err != nil {
 			c.AbortWithError(http.StatusBadRequest, err)
 			return
 		}
+		appointmentType, ok := fetchRequestedAppointmentType(c, db, &body)
+		if !ok {
+			return
+		}
 		//Checking for invalid values in request
 		if body.TimeEnd.Before(body.TimeStart) || body.TimeEnd.Equal(body.TimeStart) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeEnd must be after TimeStart"})
@@ -147,79 +176,221 @@ func UpdateAppointment(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
 			return
 		}
-		//Checking if Shedule exists
-		var schedule model.Schedule
-		db.Where("time_start <= ? AND time_end >= ? AND doctor_id = ?", body.TimeStart, body.TimeEnd, body.DoctorID).First(&schedule)
-		if schedule.ID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No free schedules for your request"})
+		if !policy(c, db).CanModify(auth.ResourceAppointment, body.DoctorID, body.PatientID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to book appointment for this patient"})
 			return
 		}
-		//Checking if time is not appointed
-		var appointments []model.Appointment
-		db.Where("time_start <= ? AND time_end >= ?", body.TimeEnd, body.TimeStart).Where("doctor_id = ?", body.DoctorID).Find(&appointments)
-		for _, a := range appointments {
-			if a.ID == appointment.ID {
-				continue //Do nothing with object instance
-			}
-			if a.TimeStart.Equal(body.TimeStart) || a.TimeEnd.Equal(body.TimeEnd) || (a.TimeStart.Before(body.TimeStart) && a.TimeEnd.After(body.TimeStart)) || (a.TimeStart.Before(body.TimeEnd) && a.TimeEnd.After(body.TimeEnd)) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "This time is already appointed"})
-				return
-			}
-		}
-		//Creating appointment
+		//Updating appointment
+		//Schedule and overlap checks are done atomically with booking
 		appointment.DoctorID = body.DoctorID
 		appointment.DoctorEmail = body.DoctorEmail
 		appointment.PatientID = body.PatientID
 		appointment.PatientEmail = body.PatientEmail
 		appointment.TimeStart = body.TimeStart
 		appointment.TimeEnd = body.TimeEnd
-		if result := db.Save(&appointment); result.Error != nil {
-			c.AbortWithError(http.StatusBadRequest, result.Error)
+		utils.ApplyAppointmentType(&appointment, appointmentType)
+		//Creating notifications for doctor and patient together with change
+		notificationType := "Change"
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := utils.BookAppointment(tx, &appointment); err != nil {
+				return err
+			}
+			if err := scheduleReminders(tx, appointment); err != nil {
+				return err
+			}
+			if err := utils.NotifyParticipants(tx, notificationType, utils.AppointmentNotificationData(tx, appointment)); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditUpdate, auth.ResourceAppointment, appointment.ID, before, appointment)
+		})
+		if err != nil {
+			bookingErrorResponse(c, err)
 			return
 		}
-		//Creating notifications for doctor and patient
-		notificationType := "Change"
-		notificationText := "Appointment data has changed"
-		doctorEmail := appointment.DoctorEmail
-		doctorID := appointment.DoctorID
-		patientEmail := appointment.PatientEmail
-		patientID := appointment.PatientID
-		utils.CreateNotification(db, notificationText, notificationType, doctorEmail, doctorID)
-		utils.CreateNotification(db, notificationText, notificationType, patientEmail, patientID)
-		c.JSON(http.StatusCreated, appointment)
-
 		c.JSON(http.StatusOK, appointment)
 	}
 }
 
 func DeleteAppointment(db *gorm.DB) func(c *gin.Context) {
-	//Request for deleting Appointment data
-	//Only a user with doctor role can do this
+	//Request for cancelling Appointment
+	//Appointment is kept with cancelled status, the same as POST "api/appointments/:id/cancel"
+	return TransitionAppointment(db, "cancel")
+}
+
+func TransitionAppointment(db *gorm.DB, action string) func(c *gin.Context) {
+	//Request for changing Appointment status
+	//Actions: confirm, check-in, complete, no-show (doctor of appointment or staff managing the doctor)
+	//and cancel (doctor, staff managing the doctor or patient of appointment)
+	//USE POST METHOD
 	return func(c *gin.Context) {
-		//Fetch appointment
+		userID := auth.CurrentPrincipal(c).ID
 		var appointment model.Appointment
-		id := c.Param("id")
-		result := db.First(&appointment, id)
-		if result.Error != nil {
+		if err := db.First(&appointment, c.Param("id")).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Cannot fetch appointment"})
+			return
+		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourceAppointment, appointment.DoctorID, appointment.PatientID) {
+			return
+		}
+		before := appointment
+		managesDoctor := policy(c, db).Manages(auth.ResourceAppointment, appointment.DoctorID)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			appointment, err = utils.TransitionAppointment(tx, c.Param("id"), action, userID, managesDoctor)
+			if err != nil {
+				return err
+			}
+			//Creating notifications for both doctor and patient together with transition
+			notificationType := "Status"
+			if appointment.Status == model.AppointmentCancelled {
+				notificationType = "Cancel"
+			}
+			if err := scheduleReminders(tx, appointment); err != nil {
+				return err
+			}
+			if err := utils.NotifyParticipants(tx, notificationType, utils.AppointmentNotificationData(tx, appointment)); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditUpdate, auth.ResourceAppointment, appointment.ID, before, appointment)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Cannot fetch appointment"})
+			case errors.Is(err, utils.ErrActionNotAllowed):
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			case errors.Is(err, utils.ErrTransitionNotAllowed), errors.Is(err, utils.ErrAppointmentNotStarted):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.AbortWithError(http.StatusInternalServerError, err)
+			}
+			return
+		}
+		if appointment.Status == model.AppointmentCancelled || appointment.Status == model.AppointmentNoShow {
+			offerFreedTime(db, appointment.DoctorID)
+		}
+		c.JSON(http.StatusOK, appointment)
+	}
+}
+
+type RescheduleAppointmentRequestBody struct {
+	TimeStart *time.Time `json:"time_start"`
+	TimeEnd   *time.Time `json:"time_end"`
+	SlotID    string     `json:"slot_id"`
+}
+
+func RescheduleAppointment(db *gorm.DB) func(c *gin.Context) {
+	//Request for moving Appointment to another time
+	//Only doctor or patient of appointment or user managing the doctor can reschedule it, previous times are kept in history
+	//Appointment cannot be moved later than RESCHEDULE_MIN_NOTICE (default 24h) before its start
+	//IMPORTANT: Structure of request, either time range or slot id from "api/doctors/:id/slots"
+	// {"time_start": "2023-12-02T12:00:00Z",
+	//"time_end": "2023-12-02T13:00:00Z"}
+	// {"slot_id": "1701518400-1701522000"}
+	//time_end is optional, by default duration of appointment type or current duration is kept
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving Appointment object
+		var appointment model.Appointment
+		if err := db.Preload("Reschedules").First(&appointment, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+			return
+		}
+		if !authorizeObject(c, db, auth.ActionUpdate, auth.ResourceAppointment, appointment.DoctorID, appointment.PatientID) {
+			return
 		}
-		//Checking user is doctor
-		isDoctor, _ := c.Get("isDoctor")
-		if isDoctor != true {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Only a doctor can delete appointment"})
-		}
-		//Creating notifications for both doctor and patient
-		notificationType := "Cancel"
-		notificationText := "Appointment data has cancelled"
-		doctorEmail := appointment.DoctorEmail
-		doctorID := appointment.DoctorID
-		patientEmail := appointment.PatientEmail
-		patientID := appointment.PatientID
-		utils.CreateNotification(db, notificationText, notificationType, doctorEmail, doctorID)
-		utils.CreateNotification(db, notificationText, notificationType, patientEmail, patientID)
-		//Deleting appointment
-		db.Delete(&appointment)
+		userID := auth.CurrentPrincipal(c).ID
+		if utils.IsAppointmentFinal(appointment) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Appointment with status " + appointment.Status + " cannot be changed"})
+			return
+		}
+		//Retrieving request body
+		body := RescheduleAppointmentRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		var timeStart, timeEnd time.Time
+		switch {
+		case body.SlotID != "":
+			slot, err := utils.ParseSlotID(body.SlotID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			timeStart, timeEnd = slot.TimeStart, slot.TimeEnd
+		case body.TimeStart != nil:
+			timeStart = *body.TimeStart
+			timeEnd = timeStart.Add(appointment.TimeEnd.Sub(appointment.TimeStart))
+			if body.TimeEnd != nil {
+				timeEnd = *body.TimeEnd
+			} else if appointment.AppointmentTypeID != nil {
+				var appointmentType model.AppointmentType
+				if err := db.First(&appointmentType, *appointment.AppointmentTypeID).Error; err == nil {
+					timeEnd = timeStart.Add(appointmentType.Duration)
+				}
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Either time_start or slot_id is required"})
+			return
+		}
+		if !timeEnd.After(timeStart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeEnd must be after TimeStart"})
+			return
+		}
+		previousStart := appointment.TimeStart
+		before := appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := utils.RescheduleAppointment(tx, &appointment, timeStart, timeEnd, userID, config.RescheduleMinNotice())
+			if err != nil {
+				return err
+			}
+			if err := scheduleReminders(tx, appointment); err != nil {
+				return err
+			}
+			//Creating notifications for doctor and patient with previous and new time
+			data := utils.AppointmentNotificationData(tx, appointment)
+			data.PreviousStart = previousStart
+			if err := utils.NotifyParticipants(tx, "Reschedule", data); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditUpdate, auth.ResourceAppointment, appointment.ID, before, appointment)
+		})
+		if err != nil {
+			if errors.Is(err, utils.ErrNoticeTooShort) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			bookingErrorResponse(c, err)
+			return
+		}
+		offerFreedTime(db, appointment.DoctorID)
+		c.JSON(http.StatusOK, appointment)
+	}
+}
+
+func fetchRequestedAppointmentType(c *gin.Context, db *gorm.DB, body *AddAppointmentRequestBody) (*model.AppointmentType, bool) {
+	//Fetching appointment type from request and deriving TimeEnd from its duration
+	if body.AppointmentTypeID == nil {
+		return nil, true
+	}
+	appointmentType, err := utils.FetchAppointmentType(db, *body.AppointmentTypeID, body.DoctorID)
+	if err != nil {
+		appointmentTypeErrorResponse(c, err)
+		return nil, false
+	}
+	body.TimeEnd = body.TimeStart.Add(appointmentType.Duration)
+	return &appointmentType, true
+}
 
-		c.JSON(http.StatusNoContent, gin.H{"msg": "Appointment succesfully deleted"})
+func bookingErrorResponse(c *gin.Context, err error) {
+	//Mapping booking errors to responses
+	switch {
+	case errors.Is(err, utils.ErrNoSchedule), errors.Is(err, utils.ErrTimeOff):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrSlotTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
 	}
 }
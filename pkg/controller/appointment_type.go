@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type AddAppointmentTypeRequestBody struct {
+	Name                string      `json:"name"`
+	DurationMinutes     int         `json:"duration_minutes"`
+	BufferBeforeMinutes int         `json:"buffer_before_minutes"`
+	BufferAfterMinutes  int         `json:"buffer_after_minutes"`
+	DoctorIDs           []uuid.UUID `json:"doctor_ids"`
+}
+
+func GetAppointmentTypesList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching all appointment types
+	//Optional query parameter "doctor_id" keeps only types offered by the doctor
+	return func(c *gin.Context) {
+		var appointmentTypes []model.AppointmentType
+		if result := db.Preload("Doctors").Find(&appointmentTypes); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch appointment types"})
+			return
+		}
+		if value := c.Query("doctor_id"); value != "" {
+			doctorID, err := uuid.FromString(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor_id parameter"})
+				return
+			}
+			offered := []model.AppointmentType{}
+			for _, t := range appointmentTypes {
+				if utils.DoctorOffersType(t, doctorID) {
+					offered = append(offered, t)
+				}
+			}
+			appointmentTypes = offered
+		}
+		c.JSON(http.StatusOK, appointmentTypes)
+	}
+}
+
+func GetAppointmentType(db *gorm.DB) func(c *gin.Context) {
+	//Fetching appointment type by id
+	return func(c *gin.Context) {
+		var appointmentType model.AppointmentType
+		if result := db.Preload("Doctors").First(&appointmentType, c.Param("id")); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch appointment type"})
+			return
+		}
+		c.JSON(http.StatusOK, appointmentType)
+	}
+}
+
+func CreateAppointmentType(db *gorm.DB) func(c *gin.Context) {
+	//Creating appointment type
+	//Only a doctor can create appointment type
+	//Empty doctor_ids means the type is offered by every doctor
+	//IMPORTANT: Structure of request
+	//{"name": "Initial consult",
+	//"duration_minutes": 45,
+	//"buffer_before_minutes": 5,
+	//"buffer_after_minutes": 10,
+	//"doctor_ids": ["0ec638e3-c9aa-4fd3-9f6d-a738a42a9b5b"]}
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := AddAppointmentTypeRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		var appointmentType model.AppointmentType
+		if err := fillAppointmentType(&appointmentType, body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		appointmentType.CreatedBy = auth.CurrentPrincipal(c).ID
+		if result := db.Create(&appointmentType); result.Error != nil {
+			c.AbortWithError(http.StatusNotFound, result.Error)
+			return
+		}
+		c.JSON(http.StatusCreated, appointmentType)
+	}
+}
+
+func UpdateAppointmentType(db *gorm.DB) func(c *gin.Context) {
+	//Updating appointment type
+	//Only a owner or admin managing the owner can update appointment type, existing appointments keep their buffers
+	//Structure of request is the same as for creating
+	//USE PUT METHOD
+	return func(c *gin.Context) {
+		appointmentType, ok := fetchOwnAppointmentType(c, db)
+		if !ok {
+			return
+		}
+		//Retrieving request body
+		body := AddAppointmentTypeRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if err := fillAppointmentType(&appointmentType, body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		//Replacing doctors list
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("appointment_type_id = ?", appointmentType.ID).Delete(&model.AppointmentTypeDoctor{}).Error; err != nil {
+				return err
+			}
+			return tx.Save(&appointmentType).Error
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, appointmentType)
+	}
+}
+
+func DeleteAppointmentType(db *gorm.DB) func(c *gin.Context) {
+	//Deleting appointment type
+	//Only a owner or admin managing the owner can delete appointment type
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		appointmentType, ok := fetchOwnAppointmentType(c, db)
+		if !ok {
+			return
+		}
+		db.Delete(&appointmentType)
+		c.JSON(http.StatusNoContent, gin.H{"message": "The object has been succesfully deleted"})
+	}
+}
+
+func fetchOwnAppointmentType(c *gin.Context, db *gorm.DB) (model.AppointmentType, bool) {
+	var appointmentType model.AppointmentType
+	if result := db.Preload("Doctors").First(&appointmentType, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch appointment type"})
+		return appointmentType, false
+	}
+	//Check if appointment type belongs to user or user manages its doctor
+	if auth.CurrentPrincipal(c).ID != appointmentType.CreatedBy &&
+		!policy(c, db).Manages(auth.ResourceAppointmentType, appointmentType.CreatedBy) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This appointment type does not belong to you"})
+		return appointmentType, false
+	}
+	return appointmentType, true
+}
+
+func fillAppointmentType(appointmentType *model.AppointmentType, body AddAppointmentTypeRequestBody) error {
+	//Checking for invalid values in request and copying them to object
+	if body.Name == "" {
+		return errors.New("Name is required")
+	}
+	if body.DurationMinutes <= 0 || body.DurationMinutes > 24*60 {
+		return errors.New("Duration must be between 1 minute and 24 hours")
+	}
+	if body.BufferBeforeMinutes < 0 || body.BufferAfterMinutes < 0 {
+		return errors.New("Buffers cannot be negative")
+	}
+	appointmentType.Name = body.Name
+	appointmentType.Duration = time.Duration(body.DurationMinutes) * time.Minute
+	appointmentType.BufferBefore = time.Duration(body.BufferBeforeMinutes) * time.Minute
+	appointmentType.BufferAfter = time.Duration(body.BufferAfterMinutes) * time.Minute
+	appointmentType.Doctors = nil
+	for _, doctorID := range body.DoctorIDs {
+		appointmentType.Doctors = append(appointmentType.Doctors, model.AppointmentTypeDoctor{DoctorID: doctorID})
+	}
+	return nil
+}
+
+func appointmentTypeErrorResponse(c *gin.Context, err error) {
+	//Mapping appointment type lookup errors to responses
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Appointment type not found"})
+	case errors.Is(err, utils.ErrTypeNotOffered):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Default and maximal number of audit entries in response
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+func auditActor(c *gin.Context) utils.AuditActor {
+	//User, client IP and request id of audited request
+	principal := auth.CurrentPrincipal(c)
+	return utils.AuditActor{
+		ID:        principal.ID,
+		Email:     principal.Email,
+		Roles:     principal.Roles,
+		ClientIP:  c.ClientIP(),
+		RequestID: c.GetString("request_id"),
+	}
+}
+
+func GetAuditLog(db *gorm.DB) func(c *gin.Context) {
+	//Fetching audit entries, newest first
+	//Optional query parameters: actor_id, action, resource_type, resource_id, request_id,
+	//from and to (RFC3339), limit (default 100, maximum 1000) and before_id for next page
+	return func(c *gin.Context) {
+		query := db.Order("id DESC")
+		if value := c.Query("actor_id"); value != "" {
+			actorID, err := uuid.FromString(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor_id parameter"})
+				return
+			}
+			query = query.Where("actor_id = ?", actorID)
+		}
+		for _, name := range []string{"action", "resource_type", "resource_id", "request_id"} {
+			if value := c.Query(name); value != "" {
+				query = query.Where(name+" = ?", value)
+			}
+		}
+		for name, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+			if value := c.Query(name); value != "" {
+				moment, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " parameter, use RFC3339 format"})
+					return
+				}
+				query = query.Where(condition, moment)
+			}
+		}
+		if value := c.Query("before_id"); value != "" {
+			beforeID, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before_id parameter"})
+				return
+			}
+			query = query.Where("id < ?", beforeID)
+		}
+		limit := auditDefaultLimit
+		if value := c.Query("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > auditMaxLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter, use number from 1 to 1000"})
+				return
+			}
+			limit = parsed
+		}
+		var entries []model.AuditEntry
+		if err := query.Limit(limit).Find(&entries).Error; err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		localize(c, &entries)
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+func VerifyAuditLog(db *gorm.DB) func(c *gin.Context) {
+	//Checking hash chain of the whole audit log
+	return func(c *gin.Context) {
+		result, err := utils.VerifyAuditChain(db)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
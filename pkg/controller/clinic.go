@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type AddClinicRequestBody struct {
+	Name     string `json:"name"`
+	TimeZone string `json:"time_zone"`
+}
+
+func GetClinicsList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching all clinic objects
+	return func(c *gin.Context) {
+		var clinics []model.Clinic
+		if result := db.Find(&clinics); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch clinics"})
+			return
+		}
+		c.JSON(http.StatusOK, clinics)
+	}
+}
+
+func GetClinic(db *gorm.DB) func(c *gin.Context) {
+	//Fetching clinic object by id
+	return func(c *gin.Context) {
+		var clinic model.Clinic
+		if result := db.First(&clinic, c.Param("id")); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch clinic"})
+			return
+		}
+		c.JSON(http.StatusOK, clinic)
+	}
+}
+
+func CreateClinic(db *gorm.DB) func(c *gin.Context) {
+	//Creating clinic object
+	//Only a doctor can create clinic
+	//IMPORTANT: Structure of request
+	//{"name": "Central clinic",
+	//"time_zone": "Europe/Moscow"}
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := AddClinicRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if _, err := utils.LoadLocation(body.TimeZone); err != nil || body.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name and valid time zone are required"})
+			return
+		}
+		clinic := model.Clinic{Name: body.Name, TimeZone: body.TimeZone, CreatedBy: auth.CurrentPrincipal(c).ID}
+		if result := db.Create(&clinic); result.Error != nil {
+			c.AbortWithError(http.StatusNotFound, result.Error)
+			return
+		}
+		c.JSON(http.StatusCreated, clinic)
+	}
+}
+
+func UpdateClinic(db *gorm.DB) func(c *gin.Context) {
+	//Updating clinic object
+	//Only a owner can update clinic
+	//Structure of request is the same as for creating
+	//USE PUT METHOD
+	return func(c *gin.Context) {
+		var clinic model.Clinic
+		if result := db.First(&clinic, c.Param("id")); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch clinic"})
+			return
+		}
+		if auth.CurrentPrincipal(c).ID != clinic.CreatedBy {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This clinic does not belong to you"})
+			return
+		}
+		//Retrieving request body
+		body := AddClinicRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if _, err := utils.LoadLocation(body.TimeZone); err != nil || body.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name and valid time zone are required"})
+			return
+		}
+		clinic.Name = body.Name
+		clinic.TimeZone = body.TimeZone
+		if result := db.Save(&clinic); result.Error != nil {
+			c.AbortWithError(http.StatusNotFound, result.Error)
+			return
+		}
+		c.JSON(http.StatusOK, clinic)
+	}
+}
+
+func AddClinicMember(db *gorm.DB) func(c *gin.Context) {
+	//Assigning user (usually doctor) to the clinic
+	//Membership decides which staff reads and manages objects of the doctor, so only
+	//clinic_admin of the clinic (by token) and system_admin assign it
+	//User of another clinic is moved only by admin managing that clinic too,
+	//user without clinic is assigned only by system_admin
+	//USE PUT METHOD
+	return func(c *gin.Context) {
+		clinic, profile, ok := fetchClinicMember(c, db)
+		if !ok {
+			return
+		}
+		if profile.ClinicID == nil && !auth.CurrentPrincipal(c).HasRole(auth.RoleSystemAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User without clinic is assigned only by system_admin"})
+			return
+		}
+		if profile.ClinicID != nil && *profile.ClinicID != clinic.ID &&
+			!policy(c, db).ManagesClinic(auth.ResourceClinic, *profile.ClinicID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User belongs to another clinic"})
+			return
+		}
+		saveClinicMember(c, db, profile, &clinic.ID)
+	}
+}
+
+func DeleteClinicMember(db *gorm.DB) func(c *gin.Context) {
+	//Removing user from the clinic
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		clinic, profile, ok := fetchClinicMember(c, db)
+		if !ok {
+			return
+		}
+		if profile.ClinicID == nil || *profile.ClinicID != clinic.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of the clinic"})
+			return
+		}
+		saveClinicMember(c, db, profile, nil)
+	}
+}
+
+func fetchClinicMember(c *gin.Context, db *gorm.DB) (model.Clinic, model.UserProfile, bool) {
+	//Fetching clinic managed by the user and profile of member, profile is prepared when user has none
+	var clinic model.Clinic
+	var profile model.UserProfile
+	if result := db.First(&clinic, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch clinic"})
+		return clinic, profile, false
+	}
+	if !policy(c, db).ManagesClinic(auth.ResourceClinic, clinic.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to manage members of this clinic"})
+		return clinic, profile, false
+	}
+	userID, err := uuid.FromString(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return clinic, profile, false
+	}
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&profile).Error; err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return clinic, profile, false
+	}
+	profile.UserID = userID
+	return clinic, profile, true
+}
+
+func saveClinicMember(c *gin.Context, db *gorm.DB, profile model.UserProfile, clinicID *uint) {
+	//Changing clinic of member together with audit entry
+	action := model.AuditUpdate
+	var before interface{} = profile
+	if profile.ID == 0 {
+		action, before = model.AuditCreate, nil
+	}
+	profile.ClinicID = clinicID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&profile).Error; err != nil {
+			return err
+		}
+		return utils.WriteAudit(tx, auditActor(c), action, auth.ResourceProfile, profile.ID, before, profile)
+	})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
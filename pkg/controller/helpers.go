@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/config"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Upper bound of time range requested through query parameters
+const maxQueryRange = 366 * 24 * time.Hour
+
+func parseTimeRange(c *gin.Context, defaultRange time.Duration) (time.Time, time.Time, error) {
+	//Parsing "from" and "to" RFC3339 query parameters
+	//Missing "from" means now, missing "to" means "from" plus default range
+	from := time.Now().UTC()
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, from, errors.New("Invalid from parameter, use RFC3339 format")
+		}
+		from = parsed
+	}
+	to := from.Add(defaultRange)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, errors.New("Invalid to parameter, use RFC3339 format")
+		}
+		to = parsed
+	}
+	if !to.After(from) {
+		return from, to, errors.New("Parameter to must be after from")
+	}
+	if to.Sub(from) > maxQueryRange {
+		return from, to, errors.New("Requested time range is too long")
+	}
+	return from, to, nil
+}
+
+func parseDurationQuery(c *gin.Context, name string, defaultValue time.Duration) (time.Duration, error) {
+	//Parsing duration query parameter like "30m" or "1h15m"
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < minSlotDuration {
+		return 0, fmt.Errorf("Invalid %s parameter, use duration like \"30m\" not shorter than %s", name, minSlotDuration)
+	}
+	return duration, nil
+}
+
+func localize(c *gin.Context, value interface{}) {
+	//Rendering time fields in time zone requested by "tz" query parameter
+	if loc, ok := c.Get("location"); ok {
+		utils.InLocation(value, loc.(*time.Location))
+	}
+}
+
+func offerFreedTime(db *gorm.DB, doctorIDs ...uuid.UUID) {
+	//Offering time released by cancellation, rescheduling or new schedules to waitlisted patients
+	for _, doctorID := range doctorIDs {
+		utils.ProcessWaitlistLogged(db, doctorID, config.WaitlistOfferTTL())
+	}
+}
+
+func scheduleReminders(tx *gorm.DB, appointment model.Appointment) error {
+	//Updating reminders of appointment in transaction changing it
+	return utils.ScheduleReminders(tx, appointment, config.ReminderOffsets())
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type AddSlotHoldRequestBody struct {
+	DoctorID    uuid.UUID `json:"doctor_id"`
+	DoctorEmail string    `json:"doctor_email"`
+	TimeStart   time.Time `json:"time_start"`
+	TimeEnd     time.Time `json:"time_end"`
+	Minutes     int       `json:"minutes"`
+	//Visit type of held slot, its buffers are held too
+	AppointmentTypeID *uint `json:"appointment_type_id"`
+}
+
+func GetSlotHoldsList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching active holds belonging to user
+	return func(c *gin.Context) {
+		userID := auth.CurrentPrincipal(c).ID
+		var holds []model.SlotHold
+		utils.ActiveHolds(db).Where("patient_id = ?", userID).Find(&holds)
+		localize(c, &holds)
+		c.JSON(http.StatusOK, holds)
+	}
+}
+
+func CreateSlotHold(db *gorm.DB) func(c *gin.Context) {
+	//Reserving doctor time range for the user while he fills in appointment details
+	//Held time is excluded from slots and cannot be appointed by other users
+	//minutes is optional hold lifetime, default 10, maximum 30
+	//appointment_type_id is optional, time_end is derived from type duration and type buffers are held as well
+	//IMPORTANT: Structure of request
+	// {"time_start": "2023-12-01T12:00:00Z",
+	//"time_end": "2023-12-01T12:30:00Z",
+	//"doctor_id": "0ec638e3-c9aa-4fd3-9f6d-a738a42a9b5b",
+	//"doctor_email":"doctor@test.com",
+	//"minutes": 10,
+	//"appointment_type_id": 1}
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := AddSlotHoldRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		var appointmentType *model.AppointmentType
+		if body.AppointmentTypeID != nil {
+			requested, err := utils.FetchAppointmentType(db, *body.AppointmentTypeID, body.DoctorID)
+			if err != nil {
+				appointmentTypeErrorResponse(c, err)
+				return
+			}
+			appointmentType = &requested
+			body.TimeEnd = body.TimeStart.Add(appointmentType.Duration)
+		}
+		//Checking for invalid values in request
+		if !body.TimeEnd.After(body.TimeStart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeEnd must be after TimeStart"})
+			return
+		}
+		if !utils.IsValidEmail(body.DoctorEmail) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+			return
+		}
+		if body.Minutes == 0 {
+			body.Minutes = utils.DefaultHoldMinutes
+		}
+		if body.Minutes < 0 || body.Minutes > utils.MaxHoldMinutes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Hold cannot be longer than 30 minutes"})
+			return
+		}
+		//Creating hold object
+		var hold model.SlotHold
+		hold.DoctorID = body.DoctorID
+		hold.DoctorEmail = body.DoctorEmail
+		hold.PatientID = auth.CurrentPrincipal(c).ID
+		hold.PatientEmail = auth.CurrentPrincipal(c).Email
+		hold.TimeStart = body.TimeStart
+		hold.TimeEnd = body.TimeEnd
+		hold.ExpiresAt = time.Now().Add(time.Duration(body.Minutes) * time.Minute)
+		if appointmentType != nil {
+			utils.ApplyHoldAppointmentType(&hold, appointmentType)
+		}
+		if err := utils.PlaceHold(db, &hold); err != nil {
+			bookingErrorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, hold)
+	}
+}
+
+func ConfirmSlotHold(db *gorm.DB) func(c *gin.Context) {
+	//Converting hold of the user into appointment of visit type of the hold
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		hold, ok := fetchOwnSlotHold(c, db)
+		if !ok {
+			return
+		}
+		var appointmentType *model.AppointmentType
+		if hold.AppointmentTypeID != nil {
+			appointmentType = &model.AppointmentType{}
+			if err := db.First(appointmentType, *hold.AppointmentTypeID).Error; err != nil {
+				appointmentTypeErrorResponse(c, err)
+				return
+			}
+		}
+		//Creating notifications for doctor and patient together with appointment
+		notificationType := "Create"
+		var appointment model.Appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			appointment, err = utils.ConfirmHold(tx, &hold, appointmentType)
+			if err != nil {
+				return err
+			}
+			if err := scheduleReminders(tx, appointment); err != nil {
+				return err
+			}
+			if err := utils.NotifyParticipants(tx, notificationType, utils.AppointmentNotificationData(tx, appointment)); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditCreate, auth.ResourceAppointment, appointment.ID, nil, appointment)
+		})
+		if err != nil {
+			if errors.Is(err, utils.ErrHoldExpired) {
+				c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+				return
+			}
+			bookingErrorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, appointment)
+	}
+}
+
+func DeleteSlotHold(db *gorm.DB) func(c *gin.Context) {
+	//Releasing hold of the user, released time is offered to waitlisted patients
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		hold, ok := fetchOwnSlotHold(c, db)
+		if !ok {
+			return
+		}
+		if err := db.Delete(&hold).Error; err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		offerFreedTime(db, hold.DoctorID)
+		c.JSON(http.StatusNoContent, gin.H{"message": "The hold has been succesfully released"})
+	}
+}
+
+func fetchOwnSlotHold(c *gin.Context, db *gorm.DB) (model.SlotHold, bool) {
+	//Fetching unconfirmed hold belonging to user
+	//Holds of waitlist offers are accepted and released through waitlist entry
+	var hold model.SlotHold
+	userID := auth.CurrentPrincipal(c).ID
+	result := db.Where("patient_id = ? AND appointment_id IS NULL AND NOT waitlist_offer", userID).First(&hold, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch hold"})
+		return hold, false
+	}
+	return hold, true
+}
@@ -1,9 +1,9 @@
 package controller
 
 import (
+	"ScheduleAPI/pkg/auth"
 	"ScheduleAPI/pkg/model"
 	"ScheduleAPI/pkg/utils"
-	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -19,21 +19,49 @@ type AddMedicalRecordRequestBody struct {
 
 func GetMedicalRecorsList(db *gorm.DB) func(c *gin.Context) {
 	//Request for fetching all MedicalRecord objects belongs to user
+	//Optional query parameter: patient_id filters records of one patient
 	return func(c *gin.Context) {
-		userID := c.MustGet("uuid").(uuid.UUID)
 		var medicalRecords []model.MedicalRecord
-		db.Where("doctor_id = ? OR patient_id = ?", userID, userID).Find(&medicalRecords)
+		query := db.Scopes(policy(c, db).Scope(auth.ResourceMedicalRecord))
+		if value := c.Query("patient_id"); value != "" {
+			patientID, err := uuid.FromString(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient_id parameter"})
+				return
+			}
+			query = query.Where("patient_id = ?", patientID)
+		}
+		query.Find(&medicalRecords)
+		//Every listed record is audited as read, records are not returned when audit cannot be written
+		ids := make([]uint, 0, len(medicalRecords))
+		for _, medicalRecord := range medicalRecords {
+			ids = append(ids, medicalRecord.ID)
+		}
+		if err := utils.AuditReads(db, auditActor(c), auth.ResourceMedicalRecord, ids...); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
 		c.JSON(http.StatusOK, medicalRecords)
 	}
 }
 
 func GetMedicalRecord(db *gorm.DB) func(c *gin.Context) {
-	//Request for fetching MedicalRecord object belongs to user
+	//Request for fetching MedicalRecord object the user may read
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		userID := c.MustGet("uuid").(uuid.UUID)
 		var medicalRecord model.MedicalRecord
-		db.Where("doctor_id = ? OR patient_id = ?", userID, userID).First(&medicalRecord, id)
+		if err := db.First(&medicalRecord, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch medical record"})
+			return
+		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourceMedicalRecord, medicalRecord.DoctorID, medicalRecord.PatientID) {
+			return
+		}
+		//Read is audited, record is not returned when audit cannot be written
+		if err := utils.AuditReads(db, auditActor(c), auth.ResourceMedicalRecord, medicalRecord.ID); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
 		c.JSON(http.StatusOK, medicalRecord)
 	}
 }
@@ -53,19 +81,15 @@ func CreateMedicalRecord(db *gorm.DB) func(c *gin.Context) {
 			c.AbortWithError(http.StatusBadRequest, err)
 			return
 		}
-		//Checking user is doctor
-		isDoctor, _ := c.Get("isDoctor")
-		if isDoctor != true {
-			c.AbortWithError(http.StatusBadRequest, errors.New("Only a doctor can create medical record"))
-		}
 		//Fetching user id and email
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		doctorEmail := c.MustGet("email").(string)
+		uuidParam := auth.CurrentPrincipal(c).ID
+		doctorEmail := auth.CurrentPrincipal(c).Email
 		//checking patient email
 		patientEmail := body.PatientEmail
 		validatePatientEmail := utils.IsValidEmail(patientEmail)
 		if validatePatientEmail != true {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient email"})
+			return
 		}
 		//Creating MedicalRecord object
 		var medicalRecord model.MedicalRecord
@@ -74,8 +98,17 @@ func CreateMedicalRecord(db *gorm.DB) func(c *gin.Context) {
 		medicalRecord.PatientID = body.PatientID
 		medicalRecord.PatientEmail = body.PatientEmail
 		medicalRecord.Text = body.Text
-		if result := db.Create(&medicalRecord); result.Error != nil {
-			c.AbortWithError(http.StatusNotFound, result.Error)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&medicalRecord).Error; err != nil {
+				return err
+			}
+			if err := utils.EmitEvent(tx, utils.EventMedicalRecordCreated, medicalRecord, medicalRecord.DoctorID, medicalRecord.PatientID); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditCreate, auth.ResourceMedicalRecord, medicalRecord.ID, nil, medicalRecord)
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusNotFound, err)
 			return
 		}
 		c.JSON(http.StatusCreated, medicalRecord)
@@ -100,12 +133,12 @@ func UpdateMedicalRecord(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch medical record"})
 			return
 		}
-		//Check if medical record belongs to user
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		if uuidParam != medicalRecord.DoctorID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This medical record does not belong to you"})
+		//Check if user may change medical record, only its doctor can
+		if !authorizeObject(c, db, auth.ActionUpdate, auth.ResourceMedicalRecord, medicalRecord.DoctorID, medicalRecord.PatientID) {
 			return
 		}
+		before := medicalRecord
+		uuidParam := auth.CurrentPrincipal(c).ID
 		//Retrieving request body
 		body := AddMedicalRecordRequestBody{}
 		if err := c.BindJSON(&body); err != nil {
@@ -113,12 +146,13 @@ func UpdateMedicalRecord(db *gorm.DB) func(c *gin.Context) {
 			return
 		}
 		//fetching user email
-		doctorEmail := c.MustGet("email").(string)
+		doctorEmail := auth.CurrentPrincipal(c).Email
 		//checking patient email
 		patientEmail := body.PatientEmail
 		validatePatientEmail := utils.IsValidEmail(patientEmail)
 		if validatePatientEmail != true {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient email"})
+			return
 		}
 		//Updating MedicalRecord object
 		medicalRecord.DoctorID = uuidParam
@@ -126,8 +160,17 @@ func UpdateMedicalRecord(db *gorm.DB) func(c *gin.Context) {
 		medicalRecord.PatientID = body.PatientID
 		medicalRecord.PatientEmail = body.PatientEmail
 		medicalRecord.Text = body.Text
-		if result := db.Save(&medicalRecord); result.Error != nil {
-			c.AbortWithError(http.StatusNotFound, result.Error)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&medicalRecord).Error; err != nil {
+				return err
+			}
+			if err := utils.EmitEvent(tx, utils.EventMedicalRecordUpdated, medicalRecord, medicalRecord.DoctorID, medicalRecord.PatientID); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditUpdate, auth.ResourceMedicalRecord, medicalRecord.ID, before, medicalRecord)
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusNotFound, err)
 			return
 		}
 		c.JSON(http.StatusOK, medicalRecord)
@@ -147,14 +190,24 @@ func DeleteMedicalRecord(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch medical record"})
 			return
 		}
-		//Check if appointment belongs to user
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		if uuidParam != medicalRecord.DoctorID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This medical record does not belong to you"})
+		//Check if user may delete medical record, only its doctor can
+		if !authorizeObject(c, db, auth.ActionDelete, auth.ResourceMedicalRecord, medicalRecord.DoctorID, medicalRecord.PatientID) {
 			return
 		}
 		//Deleting object
-		db.Delete(&medicalRecord)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&medicalRecord).Error; err != nil {
+				return err
+			}
+			if err := utils.EmitEvent(tx, utils.EventMedicalRecordDeleted, medicalRecord, medicalRecord.DoctorID, medicalRecord.PatientID); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditDelete, auth.ResourceMedicalRecord, medicalRecord.ID, medicalRecord, nil)
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
 		c.JSON(http.StatusNoContent, gin.H{"message": "The object has been succesfully deleted"})
 	}
 }
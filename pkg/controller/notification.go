@@ -1,33 +1,221 @@
 package controller
 
 import (
+	"ScheduleAPI/pkg/auth"
 	"ScheduleAPI/pkg/model"
-	"fmt"
+	"ScheduleAPI/pkg/stream"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
-	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
 )
 
 func GetNotificationsList(db *gorm.DB) func(c *gin.Context) {
-	//Fetching all Notifications objects belongs to user
+	//Fetching Notifications objects belongs to user, newest first
+	//Optional query parameters:
+	//"type" (Create, Change, Cancel, ...), "from" and "to" (RFC3339) filter by type and creation time
+	//"unread=true" returns only unread notifications, "archived=true" returns archived instead of active ones
+	//"delivery_status" (pending, sent, dead, skipped) filters notifications by delivery state
 	return func(c *gin.Context) {
 		var notifications []model.Notification
-		userID := c.MustGet("uuid").(uuid.UUID)
-		db.Where("user_id = ?", userID).Find(&notifications)
+		userID := auth.CurrentPrincipal(c).ID
+		query := db.Where("user_id = ?", userID)
+		if status := c.Query("delivery_status"); status != "" {
+			query = query.Where("delivery_status = ?", status)
+		}
+		if notificationType := c.Query("type"); notificationType != "" {
+			query = query.Where("type = ?", notificationType)
+		}
+		for _, param := range []struct{ name, condition string }{{"from", "created_at >= ?"}, {"to", "created_at < ?"}} {
+			if value := c.Query(param.name); value != "" {
+				t, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param.name + " parameter, use RFC3339 format"})
+					return
+				}
+				query = query.Where(param.condition, t)
+			}
+		}
+		if c.Query("unread") == "true" {
+			query = query.Where("read_at IS NULL")
+		}
+		if c.Query("archived") == "true" {
+			query = query.Where("archived_at IS NOT NULL")
+		} else {
+			query = query.Where("archived_at IS NULL")
+		}
+		query.Order("created_at DESC").Order("id DESC").Find(&notifications)
+		localize(c, &notifications)
 		c.JSON(http.StatusOK, notifications)
 	}
 }
 
 func GetNotification(db *gorm.DB) func(c *gin.Context) {
-	//Fetching Notification object belongs to user
+	//Fetching Notification object belongs to user together with its delivery through every channel
 	return func(c *gin.Context) {
-		userID := c.MustGet("uuid").(uuid.UUID)
-		id := c.Param("id")
-		fmt.Println()
-		var notification model.Notification
-		db.Where("user_id = ?", userID).First(&notification, id)
+		notification, ok := fetchOwnNotification(c, db)
+		if !ok {
+			return
+		}
+		db.Where("notification_id = ?", notification.ID).Order("id").Find(&notification.Deliveries)
+		localize(c, &notification)
 		c.JSON(http.StatusOK, notification)
 	}
 }
+
+func GetUnreadNotificationsCount(db *gorm.DB) func(c *gin.Context) {
+	//Counting unread not archived notifications of user
+	return func(c *gin.Context) {
+		userID := auth.CurrentPrincipal(c).ID
+		var count int64
+		db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL AND archived_at IS NULL", userID).Count(&count)
+		c.JSON(http.StatusOK, gin.H{"unread": count})
+	}
+}
+
+func MarkNotificationRead(db *gorm.DB) func(c *gin.Context) {
+	//Marking notification of user as read, read time of already read notification is kept
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		notification, ok := fetchOwnNotification(c, db)
+		if !ok {
+			return
+		}
+		if notification.ReadAt == nil {
+			now := time.Now()
+			notification.ReadAt = &now
+			db.Model(&notification).Update("read_at", now)
+		}
+		c.JSON(http.StatusOK, notification)
+	}
+}
+
+func MarkAllNotificationsRead(db *gorm.DB) func(c *gin.Context) {
+	//Marking all unread notifications of user as read
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		userID := auth.CurrentPrincipal(c).ID
+		result := db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", time.Now())
+		if result.Error != nil {
+			c.AbortWithError(http.StatusInternalServerError, result.Error)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+	}
+}
+
+func ArchiveNotification(db *gorm.DB) func(c *gin.Context) {
+	//Hiding notification of user from default list, archived notification is considered read
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		notification, ok := fetchOwnNotification(c, db)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		if notification.ReadAt == nil {
+			notification.ReadAt = &now
+		}
+		if notification.ArchivedAt == nil {
+			notification.ArchivedAt = &now
+		}
+		db.Model(&notification).Updates(map[string]interface{}{"read_at": notification.ReadAt, "archived_at": notification.ArchivedAt})
+		c.JSON(http.StatusOK, notification)
+	}
+}
+
+func DeleteNotification(db *gorm.DB) func(c *gin.Context) {
+	//Deleting notification of user
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		notification, ok := fetchOwnNotification(c, db)
+		if !ok {
+			return
+		}
+		db.Delete(&notification)
+		c.JSON(http.StatusNoContent, gin.H{"message": "The object has been succesfully deleted"})
+	}
+}
+
+func fetchOwnNotification(c *gin.Context, db *gorm.DB) (model.Notification, bool) {
+	//Fetching notification belonging to user
+	var notification model.Notification
+	userID := auth.CurrentPrincipal(c).ID
+	if result := db.Where("user_id = ?", userID).First(&notification, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch notification"})
+		return notification, false
+	}
+	return notification, true
+}
+
+func StreamNotifications(db *gorm.DB, hub stream.Hub, heartbeat time.Duration) func(c *gin.Context) {
+	//Pushing new notifications of user as Server-Sent Events, event id is notification id
+	//Client reconnecting with "Last-Event-ID" header (or "last_event_id" query parameter)
+	//first receives notifications created after that id
+	//Notifications are streamed in commit order, so live ids are not increasing and are never used to skip events
+	return func(c *gin.Context) {
+		userID := auth.CurrentPrincipal(c).ID
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("last_event_id")
+		}
+		var lastID uint64
+		if lastEventID != "" {
+			var err error
+			if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+				return
+			}
+		}
+		//Subscribing before fetching missed notifications, so nothing is lost in between
+		subscription := hub.Subscribe(userID)
+		defer subscription.Close()
+		var missed []model.Notification
+		if lastID > 0 {
+			//Archived notifications are not listed nor streamed live, so they are not replayed either
+			err := db.Where("user_id = ? AND id > ? AND archived_at IS NULL", userID, lastID).Order("id").Find(&missed).Error
+			if err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		//Replayed notifications may be published live as well, each is sent once
+		sent := map[uint]bool{}
+		send := func(notification model.Notification) {
+			if sent[notification.ID] {
+				return
+			}
+			sent[notification.ID] = true
+			c.Render(-1, sse.Event{Id: strconv.FormatUint(uint64(notification.ID), 10), Event: "notification", Data: notification})
+		}
+		for _, notification := range missed {
+			send(notification)
+		}
+		c.Writer.Flush()
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case notification, ok := <-subscription.C:
+				if !ok {
+					//Subscriber fell behind, client reconnects with Last-Event-ID
+					return
+				}
+				send(notification)
+			case <-ticker.C:
+				c.Writer.WriteString(": heartbeat\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
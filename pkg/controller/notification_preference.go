@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/notifier"
+	"ScheduleAPI/pkg/utils"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type NotificationPreferenceBody struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+type UpdateNotificationPreferencesRequestBody struct {
+	Preferences     []NotificationPreferenceBody `json:"preferences"`
+	QuietHoursStart string                       `json:"quiet_hours_start"`
+	QuietHoursEnd   string                       `json:"quiet_hours_end"`
+	DailyDigest     bool                         `json:"daily_digest"`
+}
+
+type NotificationPreferencesResponse struct {
+	Preferences     []model.NotificationPreference `json:"preferences"`
+	QuietHoursStart string                         `json:"quiet_hours_start"`
+	QuietHoursEnd   string                         `json:"quiet_hours_end"`
+	DailyDigest     bool                           `json:"daily_digest"`
+	// Channels used for every notification type after applying preferences
+	Effective map[string]map[string]bool `json:"effective"`
+}
+
+func GetNotificationPreferences(db *gorm.DB, channels map[string]notifier.Notifier) func(c *gin.Context) {
+	//Fetching notification preferences of the user together with channels effective for every notification type
+	//Channels without configured notifier are never effective
+	return func(c *gin.Context) {
+		profile, err := fetchProfile(db, c)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		response, err := notificationPreferencesResponse(db, channels, profile)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func UpdateNotificationPreferences(db *gorm.DB, channels map[string]notifier.Notifier) func(c *gin.Context) {
+	//Replacing notification preferences of the user
+	//type is notification type (Create, Change, Cancel, ...) or "*" for any type, preference of the type wins
+	//channel is one of email, in_app, sms, webhook, only configured channels can be enabled
+	//Without preferences email and in_app are enabled
+	//Email and SMS are postponed until the end of quiet hours, in time zone of the user, they may span midnight
+	//Empty quiet_hours_start and quiet_hours_end disable quiet hours
+	//IMPORTANT: Structure of request
+	//{"preferences": [{"type": "*", "channel": "email", "enabled": false},
+	//{"type": "Reminder", "channel": "email", "enabled": true}],
+	//"quiet_hours_start": "22:00",
+	//"quiet_hours_end": "07:00",
+	//"daily_digest": true}
+	//USE PUT METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := UpdateNotificationPreferencesRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		//Checking for invalid values in request
+		userID := auth.CurrentPrincipal(c).ID
+		preferences := []model.NotificationPreference{}
+		seen := map[[2]string]bool{}
+		for _, preference := range body.Preferences {
+			if preference.Type != model.AnyNotificationType && !utils.IsNotificationType(preference.Type) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type " + preference.Type})
+				return
+			}
+			if !utils.IsChannel(preference.Channel) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification channel " + preference.Channel})
+				return
+			}
+			//Deliveries through channel without notifier would never be sent
+			if preference.Enabled && !utils.ChannelConfigured(channels, preference.Channel) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Notification channel " + preference.Channel + " is not configured"})
+				return
+			}
+			key := [2]string{preference.Type, preference.Channel}
+			if seen[key] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate preference for " + preference.Type + " " + preference.Channel})
+				return
+			}
+			seen[key] = true
+			preferences = append(preferences, model.NotificationPreference{
+				UserID:  userID,
+				Type:    preference.Type,
+				Channel: preference.Channel,
+				Enabled: preference.Enabled,
+			})
+		}
+		if (body.QuietHoursStart == "") != (body.QuietHoursEnd == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Both quiet_hours_start and quiet_hours_end must be set"})
+			return
+		}
+		for _, clock := range []string{body.QuietHoursStart, body.QuietHoursEnd} {
+			if clock == "" {
+				continue
+			}
+			if _, err := utils.ParseClock(clock); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		profile, err := fetchProfile(db, c)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		profile.QuietHoursStart = body.QuietHoursStart
+		profile.QuietHoursEnd = body.QuietHoursEnd
+		profile.DailyDigest = body.DailyDigest
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ?", userID).Delete(&model.NotificationPreference{}).Error; err != nil {
+				return err
+			}
+			if len(preferences) > 0 {
+				if err := tx.Create(&preferences).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Save(&profile).Error
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		response, err := notificationPreferencesResponse(db, channels, profile)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func notificationPreferencesResponse(db *gorm.DB, channels map[string]notifier.Notifier, profile model.UserProfile) (NotificationPreferencesResponse, error) {
+	preferences, err := utils.UserPreferences(db, profile.UserID)
+	if err != nil {
+		return NotificationPreferencesResponse{}, err
+	}
+	sort.Slice(preferences, func(i, j int) bool {
+		if preferences[i].Type != preferences[j].Type {
+			return preferences[i].Type < preferences[j].Type
+		}
+		return preferences[i].Channel < preferences[j].Channel
+	})
+	effective := map[string]map[string]bool{}
+	for _, notificationType := range utils.NotificationTypes() {
+		effective[notificationType] = utils.ResolveChannels(preferences, notificationType)
+		for channel := range effective[notificationType] {
+			if !utils.ChannelConfigured(channels, channel) {
+				effective[notificationType][channel] = false
+			}
+		}
+	}
+	return NotificationPreferencesResponse{
+		Preferences:     preferences,
+		QuietHoursStart: profile.QuietHoursStart,
+		QuietHoursEnd:   profile.QuietHoursEnd,
+		DailyDigest:     profile.DailyDigest,
+		Effective:       effective,
+	}, nil
+}
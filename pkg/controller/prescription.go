@@ -1,9 +1,10 @@
 package controller
 
 import (
+	"ScheduleAPI/pkg/auth"
 	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/templates"
 	"ScheduleAPI/pkg/utils"
-	"errors"
 	"net/http"
 	"time"
 
@@ -21,22 +22,26 @@ type AddPrescriptionRequestBody struct {
 }
 
 func GetPrescriptionList(db *gorm.DB) func(c *gin.Context) {
-	//Request for fetching all Prescription objects belongs to user
+	//Request for fetching all Prescription objects the user may read
 	return func(c *gin.Context) {
-		userID := c.MustGet("uuid").(uuid.UUID)
 		var prescriptions []model.Prescription
-		db.Where("doctor_id = ? OR patient_id = ?", userID, userID).Find(&prescriptions)
+		db.Scopes(policy(c, db).Scope(auth.ResourcePrescription)).Find(&prescriptions)
 		c.JSON(http.StatusOK, prescriptions)
 	}
 }
 
 func GetPrescription(db *gorm.DB) func(c *gin.Context) {
-	//Request for fetching Prescription object belongs to user
+	//Request for fetching Prescription object the user may read
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		userID := c.MustGet("uuid").(uuid.UUID)
 		var prescription model.Prescription
-		db.Where("doctor_id = ? OR patient_id = ?", userID, userID).First(&prescription, id)
+		if err := db.First(&prescription, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch prescription"})
+			return
+		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourcePrescription, prescription.DoctorID, prescription.PatientID) {
+			return
+		}
 		c.JSON(http.StatusOK, prescription)
 	}
 }
@@ -64,14 +69,9 @@ func CreatePrescription(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
 			return
 		}
-		//Checking user is doctor
-		isDoctor, _ := c.Get("isDoctor")
-		if isDoctor != true {
-			c.AbortWithError(http.StatusBadRequest, errors.New("Only a doctor can create prescription"))
-		}
 		//Fetching user id and email
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		doctorEmail := c.MustGet("email").(string)
+		uuidParam := auth.CurrentPrincipal(c).ID
+		doctorEmail := auth.CurrentPrincipal(c).Email
 		//Creating Prescription object
 		var prescription model.Prescription
 		prescription.DoctorID = uuidParam
@@ -81,8 +81,22 @@ func CreatePrescription(db *gorm.DB) func(c *gin.Context) {
 		prescription.DrugName = body.DrugName
 		prescription.Duration = body.Duration
 		prescription.Dosage = body.Dosage
-		if result := db.Create(&prescription); result.Error != nil {
-			c.AbortWithError(http.StatusNotFound, result.Error)
+		//Creating notification for patient and webhook event together with prescription
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&prescription).Error; err != nil {
+				return err
+			}
+			data := templates.Data{Prescription: &prescription, Location: utils.UserClinicName(tx, prescription.DoctorID)}
+			if err := utils.CreateNotification(tx, "Prescription", prescription.PatientEmail, prescription.PatientID, data); err != nil {
+				return err
+			}
+			if err := utils.EmitEvent(tx, utils.EventPrescriptionCreated, prescription, prescription.DoctorID, prescription.PatientID); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditCreate, auth.ResourcePrescription, prescription.ID, nil, prescription)
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusNotFound, err)
 			return
 		}
 		c.JSON(http.StatusCreated, prescription)
@@ -108,14 +122,14 @@ func UpdatePrescription(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch prescription"})
 			return
 		}
-		//Check if prescription belongs to user
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		if uuidParam != prescription.DoctorID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This prescription does not belong to you"})
+		//Check if user may change prescription, only its doctor can
+		if !authorizeObject(c, db, auth.ActionUpdate, auth.ResourcePrescription, prescription.DoctorID, prescription.PatientID) {
 			return
 		}
+		before := prescription
+		uuidParam := auth.CurrentPrincipal(c).ID
 		//Fetching user email
-		doctorEmail := c.MustGet("email").(string)
+		doctorEmail := auth.CurrentPrincipal(c).Email
 		//Retrieving request body
 		body := AddPrescriptionRequestBody{}
 		if err := c.BindJSON(&body); err != nil {
@@ -136,8 +150,17 @@ func UpdatePrescription(db *gorm.DB) func(c *gin.Context) {
 		prescription.DrugName = body.DrugName
 		prescription.Duration = body.Duration
 		prescription.Dosage = body.Dosage
-		if result := db.Save(&prescription); result.Error != nil {
-			c.AbortWithError(http.StatusNotFound, result.Error)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&prescription).Error; err != nil {
+				return err
+			}
+			if err := utils.EmitEvent(tx, utils.EventPrescriptionUpdated, prescription, prescription.DoctorID, prescription.PatientID); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditUpdate, auth.ResourcePrescription, prescription.ID, before, prescription)
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusNotFound, err)
 			return
 		}
 		c.JSON(http.StatusOK, prescription)
@@ -157,14 +180,24 @@ func DeletePrescription(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch prescription"})
 			return
 		}
-		//Check if appointment belongs to user
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		if uuidParam != prescription.DoctorID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This prescription does not belong to you"})
+		//Check if user may delete prescription, only its doctor can
+		if !authorizeObject(c, db, auth.ActionDelete, auth.ResourcePrescription, prescription.DoctorID, prescription.PatientID) {
 			return
 		}
 		//Deleting object
-		db.Delete(&prescription)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&prescription).Error; err != nil {
+				return err
+			}
+			if err := utils.EmitEvent(tx, utils.EventPrescriptionDeleted, prescription, prescription.DoctorID, prescription.PatientID); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditDelete, auth.ResourcePrescription, prescription.ID, prescription, nil)
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
 		c.JSON(http.StatusNoContent, gin.H{"message": "The object has been succesfully deleted"})
 	}
 }
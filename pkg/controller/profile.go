@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/sms"
+	"ScheduleAPI/pkg/templates"
+	"ScheduleAPI/pkg/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UpdateProfileRequestBody struct {
+	TimeZone string `json:"time_zone"`
+	Locale   string `json:"locale"`
+	Phone    string `json:"phone"`
+}
+
+func GetProfile(db *gorm.DB) func(c *gin.Context) {
+	//Fetching profile of the user, empty profile is returned until it is saved
+	return func(c *gin.Context) {
+		profile, err := fetchProfile(db, c)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, profile)
+	}
+}
+
+func UpdateProfile(db *gorm.DB) func(c *gin.Context) {
+	//Updating profile of the user
+	//Empty time_zone means time zone of the clinic
+	//locale is language of notifications: en (default) or ru
+	//phone is number for SMS notifications in international format, empty phone disables SMS
+	//Clinic of the user grants staff access to his objects, it is assigned by clinic admins (see AddClinicMember)
+	//IMPORTANT: Structure of request
+	//{"time_zone": "Europe/Moscow",
+	//"locale": "ru",
+	//"phone": "+7 999 123-45-67"}
+	//USE PUT METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := UpdateProfileRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		//Checking for invalid values in request
+		if _, err := utils.LoadLocation(body.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+			return
+		}
+		if body.Locale != "" && !templates.IsSupportedLocale(body.Locale) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+			return
+		}
+		phone := body.Phone
+		if phone != "" {
+			var err error
+			if phone, err = sms.NormalizePhone(phone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		profile, err := fetchProfile(db, c)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		profile.TimeZone = body.TimeZone
+		profile.Locale = body.Locale
+		profile.Phone = phone
+		if result := db.Save(&profile); result.Error != nil {
+			c.AbortWithError(http.StatusInternalServerError, result.Error)
+			return
+		}
+		c.JSON(http.StatusOK, profile)
+	}
+}
+
+func fetchProfile(db *gorm.DB, c *gin.Context) (model.UserProfile, error) {
+	//Fetching profile of the user or preparing a new one
+	userID := auth.CurrentPrincipal(c).ID
+	var profile model.UserProfile
+	err := db.Where("user_id = ?", userID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.UserProfile{UserID: userID, Email: auth.CurrentPrincipal(c).Email}, nil
+	}
+	return profile, err
+}
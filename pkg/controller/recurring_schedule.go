@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type AddRecurringScheduleRequestBody struct {
+	TimeStart time.Time   `json:"time_start"`
+	TimeEnd   time.Time   `json:"time_end"`
+	RRule     string      `json:"rrule"`
+	ExDates   []time.Time `json:"exdates"`
+	TimeZone  string      `json:"time_zone"`
+	//Doctor of schedule created by admin, default is the user
+	DoctorID    *uuid.UUID `json:"doctor_id"`
+	DoctorEmail string     `json:"doctor_email"`
+}
+
+func GetRecurringSchedulesList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching recurring schedule objects the user may read
+	return func(c *gin.Context) {
+		var recurringSchedules []model.RecurringSchedule
+		result := db.Scopes(policy(c, db).Scope(auth.ResourceSchedule)).Find(&recurringSchedules)
+		if result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch recurring schedules"})
+			return
+		}
+		localize(c, &recurringSchedules)
+		c.JSON(http.StatusOK, recurringSchedules)
+	}
+}
+
+func GetRecurringSchedule(db *gorm.DB) func(c *gin.Context) {
+	//Fetching recurring schedule object by id
+	return func(c *gin.Context) {
+		var recurringSchedule model.RecurringSchedule
+		result := db.First(&recurringSchedule, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch recurring schedule"})
+			return
+		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourceSchedule, recurringSchedule.DoctorID, uuid.Nil) {
+			return
+		}
+		localize(c, &recurringSchedule)
+		c.JSON(http.StatusOK, recurringSchedule)
+	}
+}
+
+func GetRecurringScheduleOccurrences(db *gorm.DB) func(c *gin.Context) {
+	//Expanding recurring schedule into concrete windows
+	//Query parameters "from" and "to" in RFC3339 format, default range is 30 days from now
+	return func(c *gin.Context) {
+		var recurringSchedule model.RecurringSchedule
+		result := db.First(&recurringSchedule, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch recurring schedule"})
+			return
+		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourceSchedule, recurringSchedule.DoctorID, uuid.Nil) {
+			return
+		}
+		from, to, err := parseTimeRange(c, 30*24*time.Hour)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		occurrences, err := utils.ExpandRecurringSchedule(recurringSchedule, from, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		localize(c, &occurrences)
+		c.JSON(http.StatusOK, occurrences)
+	}
+}
+
+func CreateRecurringSchedule(db *gorm.DB) func(c *gin.Context) {
+	//Creating recurring schedule object
+	//time_start and time_end start the series and set time of its occurrences,
+	//they are an occurrence themselves only when they match the rule
+	//IMPORTANT! Structure of request:
+	//  {"time_start": "2026-11-02T09:00:00Z",
+	//	"time_end": "2026-11-02T13:00:00Z",
+	//	"rrule": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20270331T235959Z",
+	//	"exdates": ["2026-12-25T09:00:00Z"],
+	//	"time_zone": "Europe/Moscow"}
+	//Occurrences keep wall-clock time of time_start in time_zone, default is doctor time zone
+	//Admins add "doctor_id" and "doctor_email" to create schedule of another doctor
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := AddRecurringScheduleRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		//Checking for invalid values in request
+		if err := validateRecurringScheduleBody(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		//Resolving doctor of schedule
+		doctorID, ok := targetDoctor(c, db, auth.ResourceSchedule, body.DoctorID)
+		if !ok {
+			return
+		}
+		doctorEmail := auth.CurrentPrincipal(c).Email
+		if doctorID != auth.CurrentPrincipal(c).ID {
+			if !utils.IsValidEmail(body.DoctorEmail) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor_email"})
+				return
+			}
+			doctorEmail = body.DoctorEmail
+		}
+		//Creating recurring schedule object
+		var recurringSchedule model.RecurringSchedule
+		recurringSchedule.DoctorID = doctorID
+		recurringSchedule.DoctorEmail = doctorEmail
+		recurringSchedule.TimeStart = body.TimeStart
+		recurringSchedule.TimeEnd = body.TimeEnd
+		recurringSchedule.RRule = body.RRule
+		recurringSchedule.ExDates = utils.FormatExDates(body.ExDates)
+		recurringSchedule.TimeZone = body.TimeZone
+		if body.TimeZone == "" {
+			recurringSchedule.TimeZone = utils.UserLocation(db, recurringSchedule.DoctorID).String()
+		}
+		if result := db.Create(&recurringSchedule); result.Error != nil {
+			c.AbortWithError(http.StatusNotFound, result.Error)
+			return
+		}
+		offerFreedTime(db, recurringSchedule.DoctorID)
+		c.JSON(http.StatusCreated, &recurringSchedule)
+	}
+}
+
+func UpdateRecurringSchedule(db *gorm.DB) func(c *gin.Context) {
+	//Updating the whole series of recurring schedule
+	//Structure of request is the same as for creating
+	//USE PUT METHOD
+	return func(c *gin.Context) {
+		//Fetch recurring schedule
+		var recurringSchedule model.RecurringSchedule
+		result := db.First(&recurringSchedule, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch recurring schedule"})
+			return
+		}
+		//Check if user may change recurring schedule
+		if !authorizeObject(c, db, auth.ActionUpdate, auth.ResourceSchedule, recurringSchedule.DoctorID, uuid.Nil) {
+			return
+		}
+		//Retrieving request body
+		body := AddRecurringScheduleRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if err := validateRecurringScheduleBody(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		//Updating recurring schedule object
+		recurringSchedule.TimeStart = body.TimeStart
+		recurringSchedule.TimeEnd = body.TimeEnd
+		recurringSchedule.RRule = body.RRule
+		recurringSchedule.ExDates = utils.FormatExDates(body.ExDates)
+		if body.TimeZone != "" {
+			recurringSchedule.TimeZone = body.TimeZone
+		}
+		if result := db.Save(&recurringSchedule); result.Error != nil {
+			c.AbortWithError(http.StatusNotFound, result.Error)
+			return
+		}
+		c.JSON(http.StatusOK, &recurringSchedule)
+	}
+}
+
+func DeleteRecurringSchedule(db *gorm.DB) func(c *gin.Context) {
+	//Deleting the whole series of recurring schedule including edited occurrences
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		//Fetch recurring schedule
+		var recurringSchedule model.RecurringSchedule
+		result := db.First(&recurringSchedule, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch recurring schedule"})
+			return
+		}
+		//Check if user may change recurring schedule
+		if !authorizeObject(c, db, auth.ActionDelete, auth.ResourceSchedule, recurringSchedule.DoctorID, uuid.Nil) {
+			return
+		}
+		//Deleting series with detached occurrences
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("recurring_schedule_id = ?", recurringSchedule.ID).Delete(&model.Schedule{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&recurringSchedule).Error
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusNoContent, gin.H{"message": "The object has been succesfully deleted"})
+	}
+}
+
+func UpdateRecurringScheduleOccurrence(db *gorm.DB) func(c *gin.Context) {
+	//Editing single occurrence of recurring schedule
+	//Occurrence is excluded from the series and replaced with one-off schedule
+	//Path parameter "start" is the original occurrence start in RFC3339 format
+	//IMPORTANT! Structure of request:
+	//  {"time_start": "2026-11-04T10:00:00Z",
+	//	"time_end": "2026-11-04T14:00:00Z"}
+	//USE PUT METHOD
+	return func(c *gin.Context) {
+		recurringSchedule, occurrence, ok := fetchOccurrence(c, db)
+		if !ok {
+			return
+		}
+		//Retrieving request body
+		body := AddScheduleRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if !body.TimeEnd.After(body.TimeStart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeEnd must be after TimeStart"})
+			return
+		}
+		//Excluding occurrence and creating detached schedule object
+		recurringSchedule.ExDates = utils.FormatExDates(append(utils.ParseExDates(recurringSchedule.ExDates), occurrence))
+		schedule := model.Schedule{
+			DoctorID:            recurringSchedule.DoctorID,
+			DoctorEmail:         recurringSchedule.DoctorEmail,
+			TimeStart:           body.TimeStart,
+			TimeEnd:             body.TimeEnd,
+			RecurringScheduleID: &recurringSchedule.ID,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&recurringSchedule).Error; err != nil {
+				return err
+			}
+			return tx.Create(&schedule).Error
+		})
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, &schedule)
+	}
+}
+
+func DeleteRecurringScheduleOccurrence(db *gorm.DB) func(c *gin.Context) {
+	//Deleting single occurrence of recurring schedule
+	//Path parameter "start" is the occurrence start in RFC3339 format
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		recurringSchedule, occurrence, ok := fetchOccurrence(c, db)
+		if !ok {
+			return
+		}
+		recurringSchedule.ExDates = utils.FormatExDates(append(utils.ParseExDates(recurringSchedule.ExDates), occurrence))
+		if result := db.Save(&recurringSchedule); result.Error != nil {
+			c.AbortWithError(http.StatusInternalServerError, result.Error)
+			return
+		}
+		c.JSON(http.StatusNoContent, gin.H{"message": "The occurrence has been succesfully deleted"})
+	}
+}
+
+func fetchOccurrence(c *gin.Context, db *gorm.DB) (model.RecurringSchedule, time.Time, bool) {
+	//Fetching recurring schedule owned or managed by user and checking occurrence belongs to the series
+	var recurringSchedule model.RecurringSchedule
+	result := db.First(&recurringSchedule, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch recurring schedule"})
+		return recurringSchedule, time.Time{}, false
+	}
+	if !authorizeObject(c, db, auth.ActionUpdate, auth.ResourceSchedule, recurringSchedule.DoctorID, uuid.Nil) {
+		return recurringSchedule, time.Time{}, false
+	}
+	occurrence, err := time.Parse(time.RFC3339, c.Param("start"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid occurrence start, use RFC3339 format"})
+		return recurringSchedule, time.Time{}, false
+	}
+	windows, err := utils.ExpandRecurringSchedule(recurringSchedule, occurrence, occurrence.Add(time.Nanosecond))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return recurringSchedule, time.Time{}, false
+	}
+	for _, w := range windows {
+		if w.TimeStart.Equal(occurrence) {
+			return recurringSchedule, w.TimeStart, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Occurrence not found in the series"})
+	return recurringSchedule, time.Time{}, false
+}
+
+func validateRecurringScheduleBody(body AddRecurringScheduleRequestBody) error {
+	if !body.TimeEnd.After(body.TimeStart) {
+		return errors.New("TimeEnd must be after TimeStart")
+	}
+	if body.TimeEnd.Sub(body.TimeStart) > 24*time.Hour {
+		return errors.New("Single occurrence cannot be longer than a day")
+	}
+	if _, err := utils.LoadLocation(body.TimeZone); err != nil {
+		return errors.New("Invalid time zone")
+	}
+	_, err := utils.ParseRRule(body.RRule)
+	return err
+}
@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"ScheduleAPI/pkg/auth"
 	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
 	"net/http"
 	"time"
 
@@ -13,17 +15,21 @@ import (
 type AddScheduleRequestBody struct {
 	TimeStart time.Time `json:"time_start"`
 	TimeEnd   time.Time `json:"time_end"`
+	//Doctor of schedule created by admin, default is the user
+	DoctorID    *uuid.UUID `json:"doctor_id"`
+	DoctorEmail string     `json:"doctor_email"`
 }
 
 func GetShedulesList(db *gorm.DB) func(c *gin.Context) {
-	//Fetching all schedule objects
+	//Fetching schedule objects the user may read
 	return func(c *gin.Context) {
 		var schedules []model.Schedule
-		result := db.Find(&schedules)
+		result := db.Scopes(policy(c, db).Scope(auth.ResourceSchedule)).Find(&schedules)
 		if result.Error != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch schedules"})
 			return
 		}
+		localize(c, &schedules)
 		c.JSON(http.StatusOK, schedules)
 	}
 }
@@ -38,6 +44,10 @@ func GetScheduleById(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch schedule"})
 			return
 		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourceSchedule, schedule.DoctorID, uuid.Nil) {
+			return
+		}
+		localize(c, &schedule)
 		c.JSON(http.StatusOK, schedule)
 	}
 }
@@ -47,13 +57,9 @@ func CreateSchedule(db *gorm.DB) func(c *gin.Context) {
 	//IMPORTANT! Structure of request:
 	//  {"time_start": "2023-12-01T13:00:00Z",
 	//	"time_end": "2023-12-01T15:00:00Z"}
+	//Admins add "doctor_id" and "doctor_email" to create schedule of another doctor
 	//USE POST METHOD
 	return func(c *gin.Context) {
-		// Checking if user is doctor
-		isDoctor, _ := c.Get("isDoctor")
-		if isDoctor != true {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Only a user with doctor role can create schedule"})
-		}
 		//Retrieving request body
 		body := AddScheduleRequestBody{}
 		if err := c.BindJSON(&body); err != nil {
@@ -65,9 +71,20 @@ func CreateSchedule(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeEnd must be after TimeStart"})
 			return
 		}
+		//Resolving doctor of schedule
+		doctorID, ok := targetDoctor(c, db, auth.ResourceSchedule, body.DoctorID)
+		if !ok {
+			return
+		}
+		doctorEmail := auth.CurrentPrincipal(c).Email
+		if doctorID != auth.CurrentPrincipal(c).ID {
+			if !utils.IsValidEmail(body.DoctorEmail) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor_email"})
+				return
+			}
+			doctorEmail = body.DoctorEmail
+		}
 		// Checking the time is not appointed
-		doctorID := c.MustGet("uuid").(uuid.UUID)
-		doctorEmail := c.MustGet("email").(string)
 		var schedules []model.Schedule
 		db.Where("doctor_id = ? AND doctor_emai = ?", doctorID, doctorEmail).Find(&schedules)
 		for _, s := range schedules {
@@ -86,6 +103,7 @@ func CreateSchedule(db *gorm.DB) func(c *gin.Context) {
 			c.AbortWithError(http.StatusNotFound, result.Error)
 			return
 		}
+		offerFreedTime(db, schedule.DoctorID)
 		c.JSON(http.StatusCreated, &schedule)
 	}
 }
@@ -105,11 +123,9 @@ func UpdateSchedule(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch schedule"})
 			return
 		}
-		//Check if schedule belongs to user
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		doctorEmail := c.MustGet("email").(string)
-		if uuidParam != schedule.DoctorID || doctorEmail != schedule.DoctorEmail {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This schedule does not belong to you"})
+		//Check if user may change schedule
+		if !authorizeObject(c, db, auth.ActionUpdate, auth.ResourceSchedule, schedule.DoctorID, uuid.Nil) {
+			return
 		}
 		//Retrieving request body
 		body := AddScheduleRequestBody{}
@@ -123,7 +139,7 @@ func UpdateSchedule(db *gorm.DB) func(c *gin.Context) {
 			return
 		}
 		var schedules []model.Schedule
-		db.Where("time_start <= ? AND time_end >= ?", body.TimeEnd, body.TimeStart).Where("doctor_id = ? AND doctor_email = ?", uuidParam, doctorEmail).Find(&schedules)
+		db.Where("time_start <= ? AND time_end >= ?", body.TimeEnd, body.TimeStart).Where("doctor_id = ? AND doctor_email = ?", schedule.DoctorID, schedule.DoctorEmail).Find(&schedules)
 		for _, s := range schedules {
 			if s.ID == schedule.ID {
 				continue //Do nothing with object instance
@@ -156,11 +172,9 @@ func DeleteSchedule(db *gorm.DB) func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch schedule"})
 			return
 		}
-		//Check if schedule belongs to user
-		uuidParam := c.MustGet("uuid").(uuid.UUID)
-		doctorEmail := c.MustGet("email").(string)
-		if uuidParam != schedule.DoctorID || doctorEmail != schedule.DoctorEmail {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This schedule does not belong to you"})
+		//Check if user may change schedule
+		if !authorizeObject(c, db, auth.ActionDelete, auth.ResourceSchedule, schedule.DoctorID, uuid.Nil) {
+			return
 		}
 		//Deleting object
 		db.Delete(&schedule)
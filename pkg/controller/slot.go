@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Bounds of slot length and step accepted by slots endpoint
+const (
+	defaultSlotDuration = 30 * time.Minute
+	minSlotDuration     = 5 * time.Minute
+	maxSlotRange        = 31 * 24 * time.Hour
+)
+
+type Slot struct {
+	ID string `json:"id"`
+	utils.Window
+}
+
+func GetDoctorSlots(db *gorm.DB) func(c *gin.Context) {
+	//Fetching free bookable slots of doctor
+	//Query parameters:
+	//from, to - RFC3339 time range, default range is 7 days from now
+	//duration - slot length like "30m", default 30 minutes
+	//step - distance between slot starts like "15m", default equals duration
+	//appointment_type_id - visit type, its duration is used by default and its buffers are kept free
+	//USE GET METHOD
+	return func(c *gin.Context) {
+		doctorID, err := uuid.FromString(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor id"})
+			return
+		}
+		from, to, err := parseTimeRange(c, 7*24*time.Hour)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if to.Sub(from) > maxSlotRange {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Requested time range is too long"})
+			return
+		}
+		//Using duration and buffers of requested appointment type
+		defaultDuration := defaultSlotDuration
+		var bufferBefore, bufferAfter time.Duration
+		if value := c.Query("appointment_type_id"); value != "" {
+			typeID, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment_type_id parameter"})
+				return
+			}
+			appointmentType, err := utils.FetchAppointmentType(db, uint(typeID), doctorID)
+			if err != nil {
+				appointmentTypeErrorResponse(c, err)
+				return
+			}
+			defaultDuration = appointmentType.Duration
+			bufferBefore = appointmentType.BufferBefore
+			bufferAfter = appointmentType.BufferAfter
+		}
+		duration, err := parseDurationQuery(c, "duration", defaultDuration)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		step, err := parseDurationQuery(c, "step", duration)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		slots, err := utils.GetFreeSlots(db, doctorID, from, to, duration, step, bufferBefore, bufferAfter)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		//Slot id can be used for rescheduling instead of time range
+		response := make([]Slot, 0, len(slots))
+		for _, slot := range slots {
+			response = append(response, Slot{ID: utils.SlotID(slot), Window: slot})
+		}
+		localize(c, &response)
+		c.JSON(http.StatusOK, response)
+	}
+}
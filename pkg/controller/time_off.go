@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type AddTimeOffRequestBody struct {
+	TimeStart  time.Time `json:"time_start"`
+	TimeEnd    time.Time `json:"time_end"`
+	ClinicWide bool      `json:"clinic_wide"`
+	Reason     string    `json:"reason"`
+	//Clinic of clinic-wide holiday, default is clinic of the user token
+	ClinicID *uint `json:"clinic_id"`
+	//Doctor of time-off created by admin, default is the user
+	DoctorID *uuid.UUID `json:"doctor_id"`
+}
+
+type TimeOffResponse struct {
+	TimeOff                 model.TimeOff       `json:"time_off"`
+	ConflictingAppointments []model.Appointment `json:"conflicting_appointments"`
+}
+
+func GetTimeOffList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching time-off of doctor and clinic-wide holidays
+	//Optional query parameter "doctor_id", default is the user
+	//Time-off of another doctor is listed only to users who may read the doctor schedule
+	return func(c *gin.Context) {
+		doctorID := auth.CurrentPrincipal(c).ID
+		if value := c.Query("doctor_id"); value != "" {
+			var err error
+			doctorID, err = uuid.FromString(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor_id parameter"})
+				return
+			}
+		}
+		if !authorizeObject(c, db, auth.ActionRead, auth.ResourceSchedule, doctorID, uuid.Nil) {
+			return
+		}
+		var timeOffs []model.TimeOff
+		utils.DoctorTimeOff(db, doctorID).Order("time_start").Find(&timeOffs)
+		localize(c, &timeOffs)
+		c.JSON(http.StatusOK, timeOffs)
+	}
+}
+
+func CreateTimeOff(db *gorm.DB) func(c *gin.Context) {
+	//Creating doctor time-off or clinic-wide holiday
+	//Doctors create their time-off, admins create time-off of doctors and clinic-wide holidays
+	//Clinic-wide holiday blocks doctors of its clinic, clinic_admin creates it for clinic of the token,
+	//system_admin for clinic_id of request
+	//Booking is rejected in blocked range
+	//Response contains appointments the user may read which have to be rescheduled
+	//IMPORTANT! Structure of request:
+	//  {"time_start": "2026-12-31T00:00:00Z",
+	//	"time_end": "2027-01-02T00:00:00Z",
+	//	"clinic_wide": true,
+	//	"clinic_id": 1,
+	//	"reason": "New Year holidays"}
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := AddTimeOffRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		//Checking for invalid values in request
+		if !body.TimeEnd.After(body.TimeStart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeEnd must be after TimeStart"})
+			return
+		}
+		//Creating time-off object
+		userID := auth.CurrentPrincipal(c).ID
+		access := policy(c, db)
+		var timeOff model.TimeOff
+		if body.ClinicWide {
+			clinicID := body.ClinicID
+			if clinicID == nil {
+				clinicID = auth.CurrentPrincipal(c).ClinicID
+			}
+			if clinicID == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "clinic_id is required"})
+				return
+			}
+			if !access.ManagesClinic(auth.ResourceSchedule, *clinicID) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to manage time-off of this clinic"})
+				return
+			}
+			if err := db.First(&model.Clinic{}, *clinicID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Clinic not found"})
+				return
+			}
+			timeOff.ClinicID = clinicID
+		} else {
+			doctorID, ok := targetDoctor(c, db, auth.ResourceSchedule, body.DoctorID)
+			if !ok {
+				return
+			}
+			timeOff.DoctorID = doctorID
+		}
+		timeOff.ClinicWide = body.ClinicWide
+		timeOff.TimeStart = body.TimeStart
+		timeOff.TimeEnd = body.TimeEnd
+		timeOff.Reason = body.Reason
+		timeOff.CreatedBy = userID
+		if result := db.Create(&timeOff); result.Error != nil {
+			c.AbortWithError(http.StatusNotFound, result.Error)
+			return
+		}
+		found, err := utils.FindTimeOffConflicts(db, timeOff)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		response := TimeOffResponse{TimeOff: timeOff, ConflictingAppointments: readableAppointments(access, found)}
+		localize(c, &response)
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+func GetTimeOffConflicts(db *gorm.DB) func(c *gin.Context) {
+	//Fetching appointments which conflict with time-off
+	//Only a owner or admin managing the doctor can fetch conflicts of time-off
+	//Only appointments the user may read are listed
+	return func(c *gin.Context) {
+		var timeOff model.TimeOff
+		if result := db.First(&timeOff, c.Param("id")); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch time-off"})
+			return
+		}
+		access := policy(c, db)
+		if !managesTimeOff(c, access, timeOff) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This time-off does not belong to you"})
+			return
+		}
+		found, err := utils.FindTimeOffConflicts(db, timeOff)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		response := TimeOffResponse{TimeOff: timeOff, ConflictingAppointments: readableAppointments(access, found)}
+		localize(c, &response)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func DeleteTimeOff(db *gorm.DB) func(c *gin.Context) {
+	//Deleting time-off
+	//Only a owner or admin managing the doctor can delete time-off
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		var timeOff model.TimeOff
+		if result := db.First(&timeOff, c.Param("id")); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch time-off"})
+			return
+		}
+		if !managesTimeOff(c, policy(c, db), timeOff) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This time-off does not belong to you"})
+			return
+		}
+		db.Delete(&timeOff)
+		if timeOff.ClinicWide {
+			doctors, _ := utils.WaitlistedDoctors(db)
+			offerFreedTime(db, doctors...)
+		} else {
+			offerFreedTime(db, timeOff.DoctorID)
+		}
+		c.JSON(http.StatusNoContent, gin.H{"message": "The object has been succesfully deleted"})
+	}
+}
+
+func managesTimeOff(c *gin.Context, access *auth.Policy, timeOff model.TimeOff) bool {
+	//Owner of time-off, admin managing the doctor or the clinic of clinic-wide holiday
+	if auth.CurrentPrincipal(c).ID == timeOff.CreatedBy {
+		return true
+	}
+	if timeOff.ClinicWide {
+		return timeOff.ClinicID != nil && access.ManagesClinic(auth.ResourceSchedule, *timeOff.ClinicID)
+	}
+	return access.CanModify(auth.ResourceSchedule, timeOff.DoctorID, uuid.Nil)
+}
+
+func readableAppointments(access *auth.Policy, appointments []model.Appointment) []model.Appointment {
+	//Leaving out appointments the user may not read
+	readable := []model.Appointment{}
+	for _, appointment := range appointments {
+		if access.CanRead(auth.ResourceAppointment, appointment.DoctorID, appointment.PatientID) {
+			readable = append(readable, appointment)
+		}
+	}
+	return readable
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/config"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Duration of appointment requested through waitlist without appointment type
+const defaultWaitlistDuration = 30 * time.Minute
+
+type AddWaitlistEntryRequestBody struct {
+	DoctorID          uuid.UUID `json:"doctor_id"`
+	DoctorEmail       string    `json:"doctor_email"`
+	TimeFrom          time.Time `json:"time_from"`
+	TimeTo            time.Time `json:"time_to"`
+	AppointmentTypeID *uint     `json:"appointment_type_id"`
+	DurationMinutes   int       `json:"duration_minutes"`
+}
+
+func GetWaitlistEntriesList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching waitlist entries of the user
+	//Optional query parameter: status (waiting, offered, booked, expired, cancelled)
+	return func(c *gin.Context) {
+		userID := auth.CurrentPrincipal(c).ID
+		query := db.Where("patient_id = ?", userID)
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		var entries []model.WaitlistEntry
+		query.Order("created_at").Find(&entries)
+		localize(c, &entries)
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+func CreateWaitlistEntry(db *gorm.DB) func(c *gin.Context) {
+	//Joining waitlist of doctor for desired time range
+	//When matching slot becomes free it is held for the patient and offered through notification
+	//Offer must be accepted before WAITLIST_OFFER_TTL (default 2h) expires
+	//IMPORTANT: Structure of request
+	// {"time_from": "2023-12-01T08:00:00Z",
+	//"time_to": "2023-12-05T18:00:00Z",
+	//"doctor_id": "0ec638e3-c9aa-4fd3-9f6d-a738a42a9b5b",
+	//"doctor_email":"doctor@test.com",
+	//"appointment_type_id": 1}
+	//appointment_type_id is optional, without it duration_minutes (default 30) is used
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := AddWaitlistEntryRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		//Checking for invalid values in request
+		if !body.TimeTo.After(body.TimeFrom) || !body.TimeTo.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "TimeTo must be after TimeFrom and in the future"})
+			return
+		}
+		if !utils.IsValidEmail(body.DoctorEmail) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+			return
+		}
+		if body.DurationMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duration cannot be negative"})
+			return
+		}
+		//Creating waitlist entry object
+		var entry model.WaitlistEntry
+		entry.DoctorID = body.DoctorID
+		entry.DoctorEmail = body.DoctorEmail
+		entry.PatientID = auth.CurrentPrincipal(c).ID
+		entry.PatientEmail = auth.CurrentPrincipal(c).Email
+		entry.TimeFrom = body.TimeFrom
+		entry.TimeTo = body.TimeTo
+		entry.Duration = defaultWaitlistDuration
+		if body.DurationMinutes > 0 {
+			entry.Duration = time.Duration(body.DurationMinutes) * time.Minute
+		}
+		if body.AppointmentTypeID != nil {
+			appointmentType, err := utils.FetchAppointmentType(db, *body.AppointmentTypeID, body.DoctorID)
+			if err != nil {
+				appointmentTypeErrorResponse(c, err)
+				return
+			}
+			entry.AppointmentTypeID = &appointmentType.ID
+			entry.Duration = appointmentType.Duration
+		}
+		entry.Status = model.WaitlistWaiting
+		if result := db.Create(&entry); result.Error != nil {
+			c.AbortWithError(http.StatusInternalServerError, result.Error)
+			return
+		}
+		//Slot may be already free
+		utils.ProcessWaitlistLogged(db, entry.DoctorID, config.WaitlistOfferTTL())
+		db.First(&entry, entry.ID)
+		c.JSON(http.StatusCreated, &entry)
+	}
+}
+
+func AcceptWaitlistOffer(db *gorm.DB) func(c *gin.Context) {
+	//Booking slot offered to the user through waitlist
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		entry, ok := fetchOwnWaitlistEntry(c, db)
+		if !ok {
+			return
+		}
+		//Creating notifications for doctor and patient together with appointment
+		notificationType := "Create"
+		var appointment model.Appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			appointment, err = utils.AcceptWaitlistOffer(tx, &entry)
+			if err != nil {
+				return err
+			}
+			if err := scheduleReminders(tx, appointment); err != nil {
+				return err
+			}
+			if err := utils.NotifyParticipants(tx, notificationType, utils.AppointmentNotificationData(tx, appointment)); err != nil {
+				return err
+			}
+			return utils.WriteAudit(tx, auditActor(c), model.AuditCreate, auth.ResourceAppointment, appointment.ID, nil, appointment)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, utils.ErrOfferNotActive):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, utils.ErrHoldExpired):
+				c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			default:
+				bookingErrorResponse(c, err)
+			}
+			return
+		}
+		c.JSON(http.StatusCreated, appointment)
+	}
+}
+
+func DeleteWaitlistEntry(db *gorm.DB) func(c *gin.Context) {
+	//Leaving waitlist, offered slot is released and offered to next patient
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		entry, ok := fetchOwnWaitlistEntry(c, db)
+		if !ok {
+			return
+		}
+		if entry.Status != model.WaitlistWaiting && entry.Status != model.WaitlistOffered {
+			c.JSON(http.StatusConflict, gin.H{"error": "Waitlist entry with status " + entry.Status + " cannot be cancelled"})
+			return
+		}
+		offered := entry.Status == model.WaitlistOffered
+		if offered && entry.HoldID != nil {
+			db.Delete(&model.SlotHold{}, *entry.HoldID)
+		}
+		entry.Status = model.WaitlistCancelled
+		db.Save(&entry)
+		if offered {
+			offerFreedTime(db, entry.DoctorID)
+		}
+		c.JSON(http.StatusNoContent, gin.H{"message": "The waitlist entry has been succesfully cancelled"})
+	}
+}
+
+func fetchOwnWaitlistEntry(c *gin.Context, db *gorm.DB) (model.WaitlistEntry, bool) {
+	//Fetching waitlist entry belonging to user
+	var entry model.WaitlistEntry
+	userID := auth.CurrentPrincipal(c).ID
+	if result := db.Where("patient_id = ?", userID).First(&entry, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch waitlist entry"})
+		return entry, false
+	}
+	return entry, true
+}
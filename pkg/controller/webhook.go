@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/utils"
+	"ScheduleAPI/pkg/webhook"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Shortest secret accepted from user
+const minWebhookSecretLength = 16
+
+// Maximal number of deliveries in list
+const webhookDeliveriesLimit = 100
+
+type AddWebhookRequestBody struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	//user (default), clinic or system
+	Scope string `json:"scope"`
+	//Clinic of clinic scope, default is clinic of the user token
+	ClinicID *uint `json:"clinic_id"`
+}
+
+func GetWebhooksList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching webhook subscriptions of the user
+	return func(c *gin.Context) {
+		userID := auth.CurrentPrincipal(c).ID
+		var subscriptions []model.WebhookSubscription
+		db.Where("created_by = ?", userID).Order("id").Find(&subscriptions)
+		localize(c, &subscriptions)
+		c.JSON(http.StatusOK, subscriptions)
+	}
+}
+
+func CreateWebhook(db *gorm.DB, sender *webhook.Sender) func(c *gin.Context) {
+	//Subscribing URL to events of appointments, prescriptions and medical records the user participates in
+	//Integrations and admins subscribe to events of doctors of clinic with "clinic" scope,
+	//system_admin subscribes to events of all doctors with "system" scope
+	//URL must resolve to public addresses, internal hosts are rejected unless WEBHOOK_ALLOW_PRIVATE is set
+	//Every request is JSON payload signed with HMAC-SHA256 of the secret, see Documentation.md
+	//Without secret a random one is generated, secret is returned only in this response
+	//Empty events list subscribes to all events
+	//IMPORTANT: Structure of request
+	//{"url": "https://ehr.example.com/hooks/schedule",
+	//"secret": "0123456789abcdef0123",
+	//"events": ["appointment.created", "appointment.cancelled"],
+	//"scope": "clinic",
+	//"clinic_id": 1}
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		//Retrieving request body
+		body := AddWebhookRequestBody{}
+		if err := c.BindJSON(&body); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		//Checking for invalid values in request
+		if err := sender.CheckURL(body.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, event := range body.Events {
+			if !utils.IsWebhookEvent(event) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event " + event})
+				return
+			}
+		}
+		var clinicID *uint
+		switch body.Scope {
+		case "", model.WebhookScopeUser:
+			body.Scope = model.WebhookScopeUser
+		case model.WebhookScopeClinic:
+			clinicID = body.ClinicID
+			if clinicID == nil {
+				clinicID = auth.CurrentPrincipal(c).ClinicID
+			}
+			if clinicID == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "clinic_id is required"})
+				return
+			}
+			if !policy(c, db).ManagesClinic(auth.ResourceWebhook, *clinicID) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to subscribe to events of this clinic"})
+				return
+			}
+			if err := db.First(&model.Clinic{}, *clinicID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Clinic not found"})
+				return
+			}
+		case model.WebhookScopeSystem:
+			if !auth.CurrentPrincipal(c).HasRole(auth.RoleSystemAdmin) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Only system_admin can subscribe to events of all clinics"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope " + body.Scope})
+			return
+		}
+		secret := body.Secret
+		if secret == "" {
+			random := make([]byte, 32)
+			if _, err := rand.Read(random); err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			secret = hex.EncodeToString(random)
+		} else if len(secret) < minWebhookSecretLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Secret must be at least 16 characters long"})
+			return
+		}
+		//Creating WebhookSubscription object
+		var subscription model.WebhookSubscription
+		subscription.URL = body.URL
+		subscription.Secret = secret
+		subscription.Events = strings.Join(body.Events, ",")
+		subscription.Scope = body.Scope
+		subscription.ClinicID = clinicID
+		subscription.CreatedBy = auth.CurrentPrincipal(c).ID
+		if result := db.Create(&subscription); result.Error != nil {
+			c.AbortWithError(http.StatusInternalServerError, result.Error)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"subscription": subscription, "secret": secret})
+	}
+}
+
+func DeleteWebhook(db *gorm.DB) func(c *gin.Context) {
+	//Deleting webhook subscription of the user, its pending deliveries are not sent
+	//USE DELETE METHOD
+	return func(c *gin.Context) {
+		subscription, ok := fetchOwnWebhook(c, db)
+		if !ok {
+			return
+		}
+		db.Delete(&subscription)
+		c.JSON(http.StatusNoContent, gin.H{"message": "The object has been succesfully deleted"})
+	}
+}
+
+func TestWebhook(db *gorm.DB, sender *webhook.Sender) func(c *gin.Context) {
+	//Sending "ping" event to subscription right away, response is the delivery with the attempt
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		subscription, ok := fetchOwnWebhook(c, db)
+		if !ok {
+			return
+		}
+		delivery, err := utils.FireTestWebhook(db, sender, subscription)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		localize(c, &delivery)
+		c.JSON(http.StatusOK, delivery)
+	}
+}
+
+func GetWebhookDeliveriesList(db *gorm.DB) func(c *gin.Context) {
+	//Fetching the latest deliveries of subscription with their attempts, newest first
+	//Optional query parameters: status (pending, sent, dead), event
+	return func(c *gin.Context) {
+		subscription, ok := fetchOwnWebhook(c, db)
+		if !ok {
+			return
+		}
+		query := db.Where("subscription_id = ?", subscription.ID)
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if event := c.Query("event"); event != "" {
+			query = query.Where("event = ?", event)
+		}
+		var deliveries []model.WebhookDelivery
+		query.Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			Order("id DESC").Limit(webhookDeliveriesLimit).Find(&deliveries)
+		localize(c, &deliveries)
+		c.JSON(http.StatusOK, deliveries)
+	}
+}
+
+func ReplayWebhookDelivery(db *gorm.DB) func(c *gin.Context) {
+	//Queueing payload of delivery again, e.g. after receiver was fixed
+	//Replayed payload keeps event id, so receiver can skip already processed events
+	//USE POST METHOD
+	return func(c *gin.Context) {
+		subscription, ok := fetchOwnWebhook(c, db)
+		if !ok {
+			return
+		}
+		var delivery model.WebhookDelivery
+		if err := db.Where("subscription_id = ?", subscription.ID).First(&delivery, c.Param("delivery_id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch webhook delivery"})
+			return
+		}
+		replay, err := utils.ReplayWebhookDelivery(db, delivery)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusCreated, replay)
+	}
+}
+
+func fetchOwnWebhook(c *gin.Context, db *gorm.DB) (model.WebhookSubscription, bool) {
+	//Fetching webhook subscription belonging to user
+	var subscription model.WebhookSubscription
+	userID := auth.CurrentPrincipal(c).ID
+	if result := db.Where("created_by = ?", userID).First(&subscription, c.Param("id")); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to fetch webhook subscription"})
+		return subscription, false
+	}
+	return subscription, true
+}
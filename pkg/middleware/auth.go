@@ -1,44 +1,45 @@
 package middleware
 
 import (
-	"ScheduleAPI/pkg/utils"
+	"ScheduleAPI/pkg/auth"
 	"errors"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"github.com/gofrs/uuid"
-	"gorm.io/gorm"
 )
 
-func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+func AuthMiddleware(verifier *auth.Verifier) gin.HandlerFunc {
+	//Verifying bearer token and storing its Principal on context, see auth.CurrentPrincipal
+	//Requests without valid token get 401 with JSON error body
 	return func(c *gin.Context) {
 		// Retrieve token string from header
-		tokenString := c.GetHeader("Authorization")
-		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-		// Parse token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Return secret key
-			return []byte(os.Getenv("JWT_SECRET")), nil //
-		})
+		header := c.GetHeader("Authorization")
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+		if tokenString == "" || tokenString == header {
+			unauthorized(c, "Missing bearer token")
+			return
+		}
+		// Verify signature, algorithm and registered claims of token
+		claims, err := verifier.Verify(tokenString)
 		if err != nil {
-			c.AbortWithError(http.StatusUnauthorized, errors.New("Invalid token"))
+			if errors.Is(err, auth.ErrTokenExpired) {
+				unauthorized(c, "Token is expired")
+			} else {
+				unauthorized(c, "Invalid token")
+			}
 			return
 		}
-		claims := token.Claims.(jwt.MapClaims)
-		userID := claims["user_id"].(string) // Access the "user_id" field from claims
-		// Use the userID variable here
-		id := uuid.Must(uuid.FromString(userID))
-		c.Set("uuid", id)
-		isDoctor := claims["is_doctor"].(bool) // Acess the "is_doctor" field from claims
-		c.Set("isDoctor", isDoctor)
-		userEmail := claims["email"].(string) //Acess the "email" field from claims
-		emailValidate := utils.IsValidEmail(userEmail)
-		if emailValidate != true {
-			c.AbortWithError(http.StatusUnauthorized, errors.New("Invalid email"))
+		principal, err := auth.PrincipalFromClaims(claims)
+		if err != nil {
+			unauthorized(c, err.Error())
+			return
 		}
-		c.Set("email", userEmail)
+		auth.SetPrincipal(c, principal)
 	}
 }
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
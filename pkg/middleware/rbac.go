@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"ScheduleAPI/pkg/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RequirePermission(resource, action string) gin.HandlerFunc {
+	//Rejecting request with 403 when none of roles of user may perform action on resource
+	//Use after AuthMiddleware, see auth.Allowed for the permission matrix
+	return func(c *gin.Context) {
+		if !auth.CurrentPrincipal(c).Can(resource, action) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Your role is not allowed to " + action + " " + resource})
+			return
+		}
+	}
+}
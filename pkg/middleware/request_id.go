@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Header carrying request id between proxies, API and clients
+const RequestIDHeader = "X-Request-ID"
+
+// Accepted request ids of clients and proxies
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+func RequestIDMiddleware() gin.HandlerFunc {
+	//Keeping request id of proxy or generating new one, it is returned in response and written to audit log
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.Must(uuid.NewV4()).String()
+		}
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"ScheduleAPI/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TimeZoneMiddleware() gin.HandlerFunc {
+	//Reading optional "tz" query parameter with IANA time zone for rendering responses
+	return func(c *gin.Context) {
+		tz := c.Query("tz")
+		if tz == "" {
+			return
+		}
+		loc, err := utils.LoadLocation(tz)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid tz parameter"})
+			return
+		}
+		c.Set("location", loc)
+	}
+}
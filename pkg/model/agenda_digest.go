@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Daily agenda digest sent to doctor, at most one per doctor per local day
+type AgendaDigest struct {
+	ID       uint      `gorm:"primarykey"`
+	DoctorID uuid.UUID `gorm:"uniqueIndex:idx_agenda_digest"`
+	//Local day of doctor, "2006-01-02"
+	Date         string `gorm:"uniqueIndex:idx_agenda_digest"`
+	Appointments int
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
@@ -7,6 +7,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// Appointment statuses, cancelled and no-show appointments do not occupy time
+const (
+	AppointmentRequested = "requested"
+	AppointmentConfirmed = "confirmed"
+	AppointmentCheckedIn = "checked_in"
+	AppointmentCompleted = "completed"
+	AppointmentNoShow    = "no_show"
+	AppointmentCancelled = "cancelled"
+)
+
 type Appointment struct {
 	gorm.Model
 	DoctorID     uuid.UUID
@@ -16,4 +26,19 @@ type Appointment struct {
 	CreatedAt    time.Time `gorm:"autoCreateTime"`
 	TimeStart    time.Time
 	TimeEnd      time.Time
+	//Visit type and its buffers, time range occupied by appointment is [BlockStart, BlockEnd)
+	AppointmentTypeID *uint
+	BufferBefore      time.Duration
+	BufferAfter       time.Duration
+	BlockStart        time.Time
+	BlockEnd          time.Time
+	Status            string `gorm:"default:requested"`
+	ConfirmedAt       *time.Time
+	CheckedInAt       *time.Time
+	CompletedAt       *time.Time
+	NoShowAt          *time.Time
+	CancelledAt       *time.Time
+	CancelledBy       *uuid.UUID
+	Reschedules       []AppointmentReschedule
+	Reminders         []AppointmentReminder
 }
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// Appointment reminder statuses
+const (
+	ReminderPending   = "pending"
+	ReminderSent      = "sent"
+	ReminderCancelled = "cancelled"
+)
+
+// Reminder sent to patient Offset before appointment start
+type AppointmentReminder struct {
+	ID            uint `gorm:"primarykey"`
+	AppointmentID uint `gorm:"index"`
+	Offset        time.Duration
+	SendAt        time.Time `gorm:"index"`
+	Status        string    `gorm:"default:pending;index"`
+	SentAt        *time.Time
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
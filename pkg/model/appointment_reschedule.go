@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type AppointmentReschedule struct {
+	ID            uint `gorm:"primarykey"`
+	AppointmentID uint `gorm:"index"`
+	PreviousStart time.Time
+	PreviousEnd   time.Time
+	NewStart      time.Time
+	NewEnd        time.Time
+	RescheduledBy uuid.UUID
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type AppointmentType struct {
+	gorm.Model
+	Name         string
+	Duration     time.Duration
+	BufferBefore time.Duration
+	BufferAfter  time.Duration
+	CreatedBy    uuid.UUID
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	//Doctors offering the type, empty list means every doctor
+	Doctors []AppointmentTypeDoctor
+}
+
+type AppointmentTypeDoctor struct {
+	ID                uint `gorm:"primarykey"`
+	AppointmentTypeID uint `gorm:"index"`
+	DoctorID          uuid.UUID
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Audited actions
+const (
+	AuditRead   = "read"
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Entry of append-only audit log of access to medical data
+// Every entry contains hash of the previous entry of the same object, so changed or removed entries break the chain
+type AuditEntry struct {
+	ID         uint      `gorm:"primarykey"`
+	ActorID    uuid.UUID `gorm:"index"`
+	ActorEmail string
+	//Comma separated roles of actor
+	ActorRoles   string
+	Action       string
+	ResourceType string `gorm:"index:idx_audit_resource,priority:1"`
+	ResourceID   string `gorm:"index:idx_audit_resource,priority:2"`
+	ClientIP     string
+	RequestID    string `gorm:"index"`
+	//JSON object of changed fields {"Field": {"old": ..., "new": ...}}, empty for reads
+	Diff      string
+	CreatedAt time.Time `gorm:"index"`
+	PrevHash  string
+	Hash      string `gorm:"uniqueIndex"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type Clinic struct {
+	gorm.Model
+	Name      string
+	TimeZone  string
+	CreatedBy uuid.UUID
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
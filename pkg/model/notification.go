@@ -7,11 +7,33 @@ import (
 	"gorm.io/gorm"
 )
 
+// Notification delivery statuses
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	//Delivery failed after maximal number of attempts
+	NotificationDead = "dead"
+	//All channels are disabled by user preferences
+	NotificationSkipped = "skipped"
+)
+
 type Notification struct {
 	gorm.Model
 	Type      string
 	UserID    uuid.UUID
 	UserEmail string
 	CreatedAt time.Time `gorm:"autoCreateTime"`
-	Text      string
+	Subject   string
+	//Plain text and html versions of rendered template
+	Text string
+	HTML string
+	//Aggregated status of deliveries through all channels
+	DeliveryStatus   string `gorm:"default:pending;index"`
+	DeliveryAttempts int
+	DeliveryError    string
+	DeliveredAt      *time.Time
+	//In-app state, archived notifications are hidden from default list
+	ReadAt     *time.Time `gorm:"index"`
+	ArchivedAt *time.Time
+	Deliveries []NotificationOutbox
 }
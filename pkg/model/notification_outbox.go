@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// Delivery of notification through one channel, written in the same transaction as the notification
+// and processed by delivery worker until it is sent or dead
+type NotificationOutbox struct {
+	ID             uint   `gorm:"primarykey"`
+	NotificationID uint   `gorm:"index"`
+	Channel        string `gorm:"default:email"`
+	Status         string `gorm:"default:pending;index:idx_outbox_due,priority:1"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index:idx_outbox_due,priority:2"`
+	LastError      string
+	SentAt         *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
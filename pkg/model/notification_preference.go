@@ -0,0 +1,25 @@
+package model
+
+import (
+	"github.com/gofrs/uuid"
+)
+
+// Notification channels
+const (
+	ChannelEmail   = "email"
+	ChannelInApp   = "in_app"
+	ChannelSMS     = "sms"
+	ChannelWebhook = "webhook"
+)
+
+// Type of preference applied to all notification types
+const AnyNotificationType = "*"
+
+// Choice of user to get notifications of the type through the channel
+type NotificationPreference struct {
+	ID      uint      `gorm:"primarykey"`
+	UserID  uuid.UUID `gorm:"uniqueIndex:idx_notification_preference"`
+	Type    string    `gorm:"uniqueIndex:idx_notification_preference"`
+	Channel string    `gorm:"uniqueIndex:idx_notification_preference"`
+	Enabled bool
+}
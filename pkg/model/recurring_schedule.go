@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type RecurringSchedule struct {
+	gorm.Model
+	DoctorID    uuid.UUID
+	DoctorEmail string
+	TimeStart   time.Time
+	TimeEnd     time.Time
+	RRule       string
+	ExDates     string
+	//IANA time zone, occurrences keep wall-clock time of TimeStart in it
+	TimeZone  string
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
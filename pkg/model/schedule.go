@@ -13,5 +13,7 @@ type Schedule struct {
 	DoctorEmail string
 	TimeStart   time.Time
 	TimeEnd     time.Time
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	//Set when schedule is a detached occurrence of a recurring schedule
+	RecurringScheduleID *uint
+	CreatedAt           time.Time `gorm:"autoCreateTime"`
 }
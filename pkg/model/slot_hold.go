@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type SlotHold struct {
+	gorm.Model
+	DoctorID      uuid.UUID
+	DoctorEmail   string
+	PatientID     uuid.UUID
+	PatientEmail  string
+	TimeStart     time.Time
+	TimeEnd       time.Time
+	ExpiresAt     time.Time
+	AppointmentID *uint
+	//Visit type of held slot and its buffers, time range held is [BlockStart, BlockEnd)
+	AppointmentTypeID *uint
+	BufferBefore      time.Duration
+	BufferAfter       time.Duration
+	BlockStart        time.Time
+	BlockEnd          time.Time
+	//Hold backs slot offered through waitlist, it is not released by checkout holds of the patient
+	WaitlistOffer bool
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type TimeOff struct {
+	gorm.Model
+	//Doctor on vacation, not set for clinic-wide holidays
+	DoctorID   uuid.UUID
+	ClinicWide bool
+	//Clinic of clinic-wide holiday, it blocks only doctors of this clinic
+	ClinicID  *uint `gorm:"index"`
+	TimeStart time.Time
+	TimeEnd   time.Time
+	Reason    string
+	CreatedBy uuid.UUID
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
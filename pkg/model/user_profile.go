@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type UserProfile struct {
+	gorm.Model
+	UserID   uuid.UUID `gorm:"uniqueIndex"`
+	Email    string
+	ClinicID *uint
+	TimeZone string
+	//Language of notifications: en or ru
+	Locale string
+	//Phone number for SMS notifications in E.164 format
+	Phone string
+	//Email and SMS notifications are postponed during quiet hours ("22:00" - "07:00" in user time zone)
+	QuietHoursStart string
+	QuietHoursEnd   string
+	//Doctor receives daily agenda digest
+	DailyDigest bool
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
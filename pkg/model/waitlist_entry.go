@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Waitlist entry statuses
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistOffered   = "offered"
+	WaitlistBooked    = "booked"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+type WaitlistEntry struct {
+	gorm.Model
+	DoctorID          uuid.UUID
+	DoctorEmail       string
+	PatientID         uuid.UUID
+	PatientEmail      string
+	TimeFrom          time.Time
+	TimeTo            time.Time
+	Duration          time.Duration
+	AppointmentTypeID *uint
+	Status            string `gorm:"default:waiting"`
+	//Offer is backed by hold of the offered slot
+	HoldID         *uint
+	OfferExpiresAt *time.Time
+	AppointmentID  *uint
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookPending = "pending"
+	WebhookSent    = "sent"
+	WebhookDead    = "dead"
+)
+
+// Scopes of webhook subscriptions
+const (
+	//Objects the creator of subscription participates in
+	WebhookScopeUser = "user"
+	//Objects of doctors of clinic of subscription
+	WebhookScopeClinic = "clinic"
+	//Objects of all doctors
+	WebhookScopeSystem = "system"
+)
+
+// Endpoint receiving events of objects of its scope
+type WebhookSubscription struct {
+	gorm.Model
+	URL string
+	//user, clinic or system, integrations of clinic (EHR, billing) use clinic scope
+	Scope    string `gorm:"default:user;index"`
+	ClinicID *uint  `gorm:"index"`
+	//Key of HMAC-SHA256 signature, returned only when subscription is created
+	Secret string `json:"-"`
+	//Comma separated event types, empty means all events
+	Events    string
+	CreatedBy uuid.UUID `gorm:"index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Event payload queued for subscription, written in the same transaction as the change
+type WebhookDelivery struct {
+	ID             uint   `gorm:"primarykey"`
+	SubscriptionID uint   `gorm:"index"`
+	EventID        string `gorm:"index"`
+	Event          string
+	Payload        string
+	Status         string `gorm:"default:pending;index:idx_webhook_due,priority:1"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index:idx_webhook_due,priority:2"`
+	LastError      string
+	LastStatusCode int
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time        `gorm:"autoCreateTime"`
+	History        []WebhookAttempt `gorm:"foreignKey:DeliveryID"`
+}
+
+// Single HTTP request of delivery
+type WebhookAttempt struct {
+	ID         uint `gorm:"primarykey"`
+	DeliveryID uint `gorm:"index"`
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
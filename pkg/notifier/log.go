@@ -0,0 +1,20 @@
+package notifier
+
+import (
+	"log"
+)
+
+// LogNotifier only writes notifications to log, used when no mail server is configured
+type LogNotifier struct{}
+
+func (LogNotifier) Send(message Message) error {
+	log.Printf("Notification %q for %s: %s\n%s", message.Type, message.UserEmail, message.Subject, message.Text)
+	return nil
+}
+
+// NopNotifier drops notifications
+type NopNotifier struct{}
+
+func (NopNotifier) Send(message Message) error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package notifier
+
+import (
+	"sync"
+)
+
+// MemoryNotifier captures sent notifications, used in tests
+type MemoryNotifier struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (n *MemoryNotifier) Send(message Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func (n *MemoryNotifier) SetError(err error) {
+	//Making following sends fail with err, nil restores delivery
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.err = err
+}
+
+func (n *MemoryNotifier) Messages() []Message {
+	//Returning copy of captured notifications
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Message(nil), n.messages...)
+}
+
+func (n *MemoryNotifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = nil
+}
@@ -0,0 +1,23 @@
+package notifier
+
+import (
+	"github.com/gofrs/uuid"
+)
+
+// Message delivered to a single recipient
+type Message struct {
+	Type      string
+	UserID    uuid.UUID
+	UserEmail string
+	//Phone number of user in E.164 format, used by SMS
+	UserPhone string
+	Subject   string
+	Text      string
+	//Optional html alternative of Text
+	HTML string
+}
+
+// Notifier delivers notifications to users, implementations are chosen at startup
+type Notifier interface {
+	Send(message Message) error
+}
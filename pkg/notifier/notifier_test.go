@@ -0,0 +1,141 @@
+package notifier
+
+import (
+	"ScheduleAPI/pkg/sms"
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryNotifierCapturesMessages(t *testing.T) {
+	n := NewMemoryNotifier()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.Send(Message{Type: "Create", UserID: uuid.Must(uuid.NewV4()), UserEmail: "patient@test.com", Text: "Appointment data created"})
+		}()
+	}
+	wg.Wait()
+	messages := n.Messages()
+	assert.Len(t, messages, 10)
+	assert.Equal(t, "Create", messages[0].Type)
+	// Returned slice is a copy
+	messages[0].Type = "Changed"
+	assert.Equal(t, "Create", n.Messages()[0].Type)
+	n.Reset()
+	assert.Empty(t, n.Messages())
+}
+
+func TestMemoryNotifierFailure(t *testing.T) {
+	n := NewMemoryNotifier()
+	n.SetError(errors.New("connection refused"))
+	assert.Error(t, n.Send(Message{Type: "Create"}))
+	assert.Empty(t, n.Messages())
+	n.SetError(nil)
+	assert.NoError(t, n.Send(Message{Type: "Create"}))
+	assert.Len(t, n.Messages(), 1)
+}
+
+// Test SMTP server accepting one session and capturing its message
+func newSMTPServer(t *testing.T) (int, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+			case "DATA":
+				text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestSMTPNotifierSendsMultipartEmail(t *testing.T) {
+	port, received := newSMTPServer(t)
+	n := NewSMTPNotifier("127.0.0.1", port, "clinic@test.com", "")
+	err := n.Send(Message{Type: "Create", UserEmail: "patient@test.com", Subject: "Appointment booked",
+		Text: "See you on Monday", HTML: "<p>See you on Monday</p>"})
+	assert.NoError(t, err)
+	message := <-received
+	assert.Contains(t, message, "From: clinic@test.com")
+	assert.Contains(t, message, "To: patient@test.com")
+	assert.Contains(t, message, "Subject: Appointment booked")
+	assert.Contains(t, message, "Content-Type: text/plain")
+	assert.Contains(t, message, "Content-Type: text/html")
+	assert.Contains(t, message, "<p>See you on Monday</p>")
+}
+
+func TestSMTPNotifierUnavailableServer(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	// Failed delivery is returned, so outbox retries it
+	assert.Error(t, NewSMTPNotifier("127.0.0.1", port, "clinic@test.com", "").Send(Message{Type: "Create", UserEmail: "patient@test.com"}))
+}
+
+func TestLogNotifierWritesMessage(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	assert.NoError(t, LogNotifier{}.Send(Message{Type: "Create", UserEmail: "patient@test.com", Subject: "Appointment booked", Text: "See you on Monday"}))
+	assert.Contains(t, output.String(), `Notification "Create" for patient@test.com: Appointment booked`)
+	assert.Contains(t, output.String(), "See you on Monday")
+	// Nop notifier drops messages without error
+	output.Reset()
+	assert.NoError(t, NopNotifier{}.Send(Message{Type: "Create", UserEmail: "patient@test.com"}))
+	assert.Empty(t, output.String())
+}
+
+func TestSMSNotifier(t *testing.T) {
+	provider := sms.NewMemoryProvider()
+	n := NewSMSNotifier(provider, 1)
+	assert.Error(t, n.Send(Message{Type: "Create", Subject: "Appointment booked"}))
+	message := Message{Type: "Create", UserPhone: "+79991234567", Subject: "Appointment booked", Text: strings.Repeat("Details ", 40)}
+	assert.NoError(t, n.Send(message))
+	messages := provider.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "+79991234567", messages[0].To)
+		assert.True(t, strings.HasPrefix(messages[0].Body, "Appointment booked\nDetails"))
+		assert.Equal(t, 1, sms.Segments(messages[0].Body))
+	}
+	provider.SetError(errors.New("provider unavailable"))
+	assert.EqualError(t, n.Send(message), "provider unavailable")
+}
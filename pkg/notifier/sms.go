@@ -0,0 +1,31 @@
+package notifier
+
+import (
+	"ScheduleAPI/pkg/sms"
+	"errors"
+	"strings"
+)
+
+// SMSNotifier sends subject and text of notification as SMS through provider
+type SMSNotifier struct {
+	provider    sms.Provider
+	maxSegments int
+}
+
+func NewSMSNotifier(provider sms.Provider, maxSegments int) *SMSNotifier {
+	return &SMSNotifier{provider: provider, maxSegments: maxSegments}
+}
+
+func SMSBody(message Message, maxSegments int) string {
+	//Subject followed by plain text, shortened to maxSegments segments
+	body := strings.TrimSpace(message.Subject + "\n" + message.Text)
+	return sms.Fit(body, maxSegments)
+}
+
+func (n *SMSNotifier) Send(message Message) error {
+	if message.UserPhone == "" {
+		return errors.New("User has no phone number")
+	}
+	_, err := n.provider.Send(message.UserPhone, SMSBody(message, n.maxSegments))
+	return err
+}
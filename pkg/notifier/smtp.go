@@ -0,0 +1,27 @@
+package notifier
+
+import (
+	"gopkg.in/gomail.v2"
+)
+
+type SMTPNotifier struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+func NewSMTPNotifier(host string, port int, user, password string) *SMTPNotifier {
+	return &SMTPNotifier{dialer: gomail.NewDialer(host, port, user, password), from: user}
+}
+
+func (n *SMTPNotifier) Send(message Message) error {
+	//Sending notification as multipart email with plain text and html parts
+	m := gomail.NewMessage()
+	m.SetHeader("From", n.from)
+	m.SetHeader("To", message.UserEmail)
+	m.SetHeader("Subject", message.Subject)
+	m.SetBody("text/plain", message.Text)
+	if message.HTML != "" {
+		m.AddAlternative("text/html", message.HTML)
+	}
+	return n.dialer.DialAndSend(m)
+}
@@ -0,0 +1,105 @@
+package sms
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Provider sends text messages, implementations are chosen at startup
+type Provider interface {
+	//Sending body to phone number in E.164 format, returns id of message at provider
+	Send(to, body string) (string, error)
+}
+
+// Segment sizes of single and concatenated messages
+const (
+	gsmSingleLength  = 160
+	gsmPartLength    = 153
+	ucs2SingleLength = 70
+	ucs2PartLength   = 67
+)
+
+var ErrInvalidPhone = errors.New("Invalid phone number, use international format like +79991234567")
+
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// Characters of GSM 03.38 basic set, extension characters take two septets
+const gsmBasic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+const gsmExtension = "^{}\\[~]|€\f"
+
+func NormalizePhone(phone string) (string, error) {
+	//Removing spaces, dashes and parentheses, number must be in E.164 format
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, phone)
+	if !phonePattern.MatchString(normalized) {
+		return "", ErrInvalidPhone
+	}
+	return normalized, nil
+}
+
+func septets(body string) (int, bool) {
+	//Length of body in GSM septets, false when body needs UCS-2 encoding
+	length := 0
+	for _, r := range body {
+		switch {
+		case strings.ContainsRune(gsmBasic, r):
+			length++
+		case strings.ContainsRune(gsmExtension, r):
+			length += 2
+		default:
+			return 0, false
+		}
+	}
+	return length, true
+}
+
+func units(body string) (int, int, int) {
+	//Length of body in encoding units with single and concatenated segment sizes
+	if length, ok := septets(body); ok {
+		return length, gsmSingleLength, gsmPartLength
+	}
+	//UCS-2 counts UTF-16 code units, characters outside BMP take two
+	length := 0
+	for _, r := range body {
+		length++
+		if r > 0xFFFF {
+			length++
+		}
+	}
+	return length, ucs2SingleLength, ucs2PartLength
+}
+
+func Segments(body string) int {
+	//Number of SMS segments needed for body
+	length, single, part := units(body)
+	if length <= single {
+		return 1
+	}
+	return (length + part - 1) / part
+}
+
+func Fit(body string, maxSegments int) string {
+	//Shortening body with ellipsis to fit into maxSegments segments
+	if maxSegments < 1 || Segments(body) <= maxSegments {
+		return body
+	}
+	const ellipsis = "..."
+	runes := []rune(body)
+	//Removing characters until shortened text fits, encoding may change on the way
+	low, high := 0, len(runes)
+	for low < high {
+		middle := (low + high + 1) / 2
+		if Segments(strings.TrimSpace(string(runes[:middle]))+ellipsis) <= maxSegments {
+			low = middle
+		} else {
+			high = middle - 1
+		}
+	}
+	return strings.TrimSpace(string(runes[:low])) + ellipsis
+}
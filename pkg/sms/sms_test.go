@@ -0,0 +1,99 @@
+package sms
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	phone, err := NormalizePhone("+7 (999) 123-45-67")
+	assert.NoError(t, err)
+	assert.Equal(t, "+79991234567", phone)
+	for _, invalid := range []string{"89991234567", "+0123456789", "+7999", "+7999abc4567"} {
+		_, err := NormalizePhone(invalid)
+		assert.ErrorIs(t, err, ErrInvalidPhone, invalid)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	assert.Equal(t, 1, Segments(strings.Repeat("a", 160)))
+	assert.Equal(t, 2, Segments(strings.Repeat("a", 161)))
+	assert.Equal(t, 3, Segments(strings.Repeat("a", 307)))
+	// Extension characters take two septets
+	assert.Equal(t, 2, Segments(strings.Repeat("€", 81)))
+	// Cyrillic needs UCS-2
+	assert.Equal(t, 1, Segments(strings.Repeat("я", 70)))
+	assert.Equal(t, 2, Segments(strings.Repeat("я", 71)))
+}
+
+func TestFit(t *testing.T) {
+	short := "Appointment booked"
+	assert.Equal(t, short, Fit(short, 1))
+	long := strings.Repeat("Приём ", 100)
+	fitted := Fit(long, 2)
+	assert.Equal(t, 2, Segments(fitted))
+	assert.True(t, strings.HasSuffix(fitted, "..."))
+	// Zero means no limit
+	assert.Equal(t, long, Fit(long, 0))
+}
+
+func TestTwilioProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+		assert.Equal(t, "AC123", user)
+		assert.Equal(t, "token", password)
+		assert.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("To") == "+10000000000" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number", "status": 400}`))
+			return
+		}
+		assert.Equal(t, "+15005550006", r.PostForm.Get("From"))
+		assert.Equal(t, "Hello", r.PostForm.Get("Body"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "SM42", "status": "queued"}`))
+	}))
+	defer server.Close()
+
+	provider := NewTwilioProvider(server.URL, "AC123", "token", "+15005550006")
+	id, err := provider.Send("+79991234567", "Hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "SM42", id)
+	_, err = provider.Send("+10000000000", "Hello")
+	assert.EqualError(t, err, "SMS provider responded with status 400: Invalid 'To' Phone Number (code 21211)")
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.log")
+	provider := NewFileProvider(path)
+	for _, body := range []string{"First", "Second"} {
+		_, err := provider.Send("+79991234567", body)
+		assert.NoError(t, err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var messages []Message
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message Message
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+		messages = append(messages, message)
+	}
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "Second", messages[1].Body)
+		assert.Equal(t, "+79991234567", messages[1].To)
+	}
+}
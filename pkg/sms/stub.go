@@ -0,0 +1,89 @@
+package sms
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message captured by stub providers
+type Message struct {
+	ID     string    `json:"id"`
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// MemoryProvider keeps sent messages in memory, used in tests
+type MemoryProvider struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{}
+}
+
+func (p *MemoryProvider) Send(to, body string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return "", p.err
+	}
+	message := Message{ID: fmt.Sprintf("SM%d", len(p.messages)+1), To: to, Body: body, SentAt: time.Now()}
+	p.messages = append(p.messages, message)
+	return message.ID, nil
+}
+
+func (p *MemoryProvider) SetError(err error) {
+	//Making following sends fail with err, nil restores delivery
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *MemoryProvider) Messages() []Message {
+	//Returning copy of captured messages
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
+
+func (p *MemoryProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = nil
+}
+
+// FileProvider appends messages to file as JSON lines, used for local development
+type FileProvider struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (p *FileProvider) Send(to, body string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sentAt := time.Now()
+	message := Message{ID: fmt.Sprintf("SM%d", sentAt.UnixNano()), To: to, Body: body, SentAt: sentAt}
+	line, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return "", err
+	}
+	return message.ID, nil
+}
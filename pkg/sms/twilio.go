@@ -0,0 +1,73 @@
+package sms
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Default address of Twilio REST API
+const TwilioBaseURL = "https://api.twilio.com"
+
+// TwilioProvider sends messages through Twilio Messages API or any service with the same shape:
+// form POST of To, From and Body to <base>/2010-04-01/Accounts/<sid>/Messages.json with basic auth
+type TwilioProvider struct {
+	client     *http.Client
+	baseURL    string
+	accountSID string
+	authToken  string
+	from       string
+}
+
+func NewTwilioProvider(baseURL, accountSID, authToken, from string) *TwilioProvider {
+	if baseURL == "" {
+		baseURL = TwilioBaseURL
+	}
+	return &TwilioProvider{
+		client:     &http.Client{Timeout: 10 * time.Second},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+	}
+}
+
+// Fields of Twilio response used by provider
+type twilioResponse struct {
+	SID     string `json:"sid"`
+	Status  string `json:"status"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *TwilioProvider) Send(to, body string) (string, error) {
+	form := url.Values{"To": {to}, "From": {p.from}, "Body": {body}}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", p.baseURL, url.PathEscape(p.accountSID))
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth(p.accountSID, p.authToken)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	response, err := p.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var result twilioResponse
+	decodeErr := json.NewDecoder(response.Body).Decode(&result)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		if result.Message != "" {
+			return "", fmt.Errorf("SMS provider responded with status %d: %s (code %d)", response.StatusCode, result.Message, result.Code)
+		}
+		return "", fmt.Errorf("SMS provider responded with status %d", response.StatusCode)
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("Invalid response of SMS provider: %v", decodeErr)
+	}
+	return result.SID, nil
+}
@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"ScheduleAPI/pkg/model"
+	"sync"
+
+	"github.com/gofrs/uuid"
+)
+
+// Notifications buffered for slow subscriber before it is disconnected
+const subscriptionBuffer = 64
+
+// Hub broadcasts created notifications to connected users.
+// MemoryHub serves users connected to its API instance, every instance
+// feeds its hub from PostgreSQL LISTEN/NOTIFY (see utils.ListenNotifications).
+type Hub interface {
+	Publish(notification model.Notification)
+	Subscribe(userID uuid.UUID) *Subscription
+}
+
+type Subscription struct {
+	//Closed when subscription is closed or subscriber falls behind,
+	//in the latter case client should reconnect with Last-Event-ID
+	C      <-chan model.Notification
+	cancel func()
+}
+
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+type MemoryHub struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan model.Notification]struct{}
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{subscribers: map[uuid.UUID]map[chan model.Notification]struct{}{}}
+}
+
+func (h *MemoryHub) Publish(notification model.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[notification.UserID] {
+		select {
+		case ch <- notification:
+		default:
+			//Subscriber is too slow, dropping it instead of blocking publisher
+			h.remove(notification.UserID, ch)
+		}
+	}
+}
+
+func (h *MemoryHub) Subscribe(userID uuid.UUID) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan model.Notification, subscriptionBuffer)
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[chan model.Notification]struct{}{}
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	return &Subscription{C: ch, cancel: func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(userID, ch)
+	}}
+}
+
+func (h *MemoryHub) remove(userID uuid.UUID, ch chan model.Notification) {
+	//Closing subscriber channel once, caller holds the lock
+	if _, ok := h.subscribers[userID][ch]; !ok {
+		return
+	}
+	delete(h.subscribers[userID], ch)
+	if len(h.subscribers[userID]) == 0 {
+		delete(h.subscribers, userID)
+	}
+	close(ch)
+}
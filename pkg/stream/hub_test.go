@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"ScheduleAPI/pkg/model"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func notificationFor(userID uuid.UUID, id uint) model.Notification {
+	notification := model.Notification{UserID: userID, Type: "Create"}
+	notification.ID = id
+	return notification
+}
+
+func TestMemoryHubDeliversToUserSubscribers(t *testing.T) {
+	hub := NewMemoryHub()
+	userID := uuid.Must(uuid.NewV4())
+	first := hub.Subscribe(userID)
+	second := hub.Subscribe(userID)
+	other := hub.Subscribe(uuid.Must(uuid.NewV4()))
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+
+	hub.Publish(notificationFor(userID, 1))
+	assert.Equal(t, uint(1), (<-first.C).ID)
+	assert.Equal(t, uint(1), (<-second.C).ID)
+	assert.Len(t, other.C, 0)
+}
+
+func TestMemoryHubClose(t *testing.T) {
+	hub := NewMemoryHub()
+	userID := uuid.Must(uuid.NewV4())
+	subscription := hub.Subscribe(userID)
+	subscription.Close()
+	// Closing twice is safe and publishing has no subscribers
+	subscription.Close()
+	hub.Publish(notificationFor(userID, 1))
+	_, open := <-subscription.C
+	assert.False(t, open)
+	assert.Empty(t, hub.subscribers)
+}
+
+func TestMemoryHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewMemoryHub()
+	userID := uuid.Must(uuid.NewV4())
+	subscription := hub.Subscribe(userID)
+	defer subscription.Close()
+	for i := 0; i <= subscriptionBuffer; i++ {
+		hub.Publish(notificationFor(userID, uint(i+1)))
+	}
+	received := 0
+	for range subscription.C {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+}
@@ -0,0 +1,176 @@
+package templates
+
+import (
+	"ScheduleAPI/pkg/model"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Each notification template consists of "<locale>/<name>.txt" defining "subject" and "text"
+// and "<locale>/<name>.html" defining "html"
+//
+//go:embed en ru
+var files embed.FS
+
+const DefaultLocale = "en"
+
+var Locales = []string{"en", "ru"}
+
+var Names = []string{
+	"appointment_created",
+	"appointment_changed",
+	"appointment_cancelled",
+	"appointment_status",
+	"appointment_rescheduled",
+	"appointment_reminder",
+	"prescription_issued",
+	"waitlist_offer",
+	"agenda_digest",
+}
+
+// Date-time layouts of locales, times are rendered in recipient time zone
+var dateTimeLayouts = map[string]string{
+	"en": "Mon, 02 Jan 2006 15:04 MST",
+	"ru": "02.01.2006 15:04 MST",
+}
+
+var dateLayouts = map[string]string{
+	"en": "Mon, 02 Jan 2006",
+	"ru": "02.01.2006",
+}
+
+var statusNames = map[string]map[string]string{
+	"ru": {
+		model.AppointmentRequested: "запрошен",
+		model.AppointmentConfirmed: "подтверждён",
+		model.AppointmentCheckedIn: "пациент пришёл",
+		model.AppointmentCompleted: "завершён",
+		model.AppointmentNoShow:    "пациент не пришёл",
+		model.AppointmentCancelled: "отменён",
+	},
+}
+
+// Values available in templates, fields not related to notification are empty
+type Data struct {
+	Appointment   *model.Appointment
+	Prescription  *model.Prescription
+	Hold          *model.SlotHold
+	PreviousStart time.Time
+	//Name of doctor clinic
+	Location string
+	//Local day of agenda digest and its appointments
+	Date   time.Time
+	Agenda []AgendaItem
+}
+
+// Appointment of agenda digest with links to its details and patient notes
+type AgendaItem struct {
+	Appointment    model.Appointment
+	TypeName       string
+	AppointmentURL string
+	NotesURL       string
+}
+
+type Content struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type localeTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var parsed = parseAll()
+
+func parseAll() map[string]map[string]localeTemplates {
+	//Parsing embedded templates once, broken template is a programming error
+	stubs := funcs(DefaultLocale, time.UTC)
+	result := map[string]map[string]localeTemplates{}
+	for _, locale := range Locales {
+		result[locale] = map[string]localeTemplates{}
+		for _, name := range Names {
+			path := locale + "/" + name
+			result[locale][name] = localeTemplates{
+				text: texttemplate.Must(texttemplate.New(name).Funcs(stubs).ParseFS(files, path+".txt")),
+				html: htmltemplate.Must(htmltemplate.New(name).Funcs(stubs).ParseFS(files, path+".html")),
+			}
+		}
+	}
+	return result
+}
+
+func funcs(locale string, loc *time.Location) map[string]interface{} {
+	return map[string]interface{}{
+		"datetime": func(t time.Time) string {
+			return t.In(loc).Format(dateTimeLayouts[locale])
+		},
+		"date": func(t time.Time) string {
+			return t.In(loc).Format(dateLayouts[locale])
+		},
+		"clock": func(t time.Time) string {
+			return t.In(loc).Format("15:04")
+		},
+		"status": func(status string) string {
+			if name, ok := statusNames[locale][status]; ok {
+				return name
+			}
+			return strings.ReplaceAll(status, "_", " ")
+		},
+	}
+}
+
+func IsSupportedLocale(locale string) bool {
+	for _, value := range Locales {
+		if value == locale {
+			return true
+		}
+	}
+	return false
+}
+
+func Render(name, locale string, loc *time.Location, data Data) (Content, error) {
+	//Rendering subject, plain text and html of named template in locale and time zone of recipient
+	//Unsupported locale falls back to English
+	if !IsSupportedLocale(locale) {
+		locale = DefaultLocale
+	}
+	set, ok := parsed[locale][name]
+	if !ok {
+		return Content{}, fmt.Errorf("Unknown notification template %q", name)
+	}
+	bound := funcs(locale, loc)
+	text, err := set.text.Clone()
+	if err != nil {
+		return Content{}, err
+	}
+	html, err := set.html.Clone()
+	if err != nil {
+		return Content{}, err
+	}
+	text.Funcs(bound)
+	html.Funcs(bound)
+	var content Content
+	var buffer bytes.Buffer
+	if err := text.ExecuteTemplate(&buffer, "subject", data); err != nil {
+		return Content{}, err
+	}
+	content.Subject = strings.TrimSpace(buffer.String())
+	buffer.Reset()
+	if err := text.ExecuteTemplate(&buffer, "text", data); err != nil {
+		return Content{}, err
+	}
+	content.Text = strings.TrimSpace(buffer.String())
+	buffer.Reset()
+	if err := html.ExecuteTemplate(&buffer, "html", data); err != nil {
+		return Content{}, err
+	}
+	content.HTML = strings.TrimSpace(buffer.String())
+	return content, nil
+}
@@ -0,0 +1,85 @@
+package templates
+
+import (
+	"ScheduleAPI/pkg/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleData() Data {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	appointment := &model.Appointment{
+		DoctorEmail:  "doctor@test.com",
+		PatientEmail: "patient@test.com",
+		TimeStart:    start,
+		TimeEnd:      start.Add(30 * time.Minute),
+		Status:       model.AppointmentConfirmed,
+	}
+	return Data{
+		Appointment:   appointment,
+		Prescription:  &model.Prescription{DrugName: "Aspirin", Dosage: "2 capsules", DoctorEmail: "doctor@test.com"},
+		Hold:          &model.SlotHold{DoctorEmail: "doctor@test.com", TimeStart: start, TimeEnd: start.Add(30 * time.Minute), ExpiresAt: start.Add(-time.Hour)},
+		PreviousStart: start.Add(-24 * time.Hour),
+		Location:      "Central <Clinic>",
+		Date:          start.Truncate(24 * time.Hour),
+		Agenda: []AgendaItem{{
+			Appointment:    *appointment,
+			TypeName:       "Consultation",
+			AppointmentURL: "https://schedule.test/api/appointments/1",
+			NotesURL:       "https://schedule.test/api/medical_records/?patient_id=1",
+		}},
+	}
+}
+
+func TestRenderAllTemplates(t *testing.T) {
+	for _, locale := range Locales {
+		for _, name := range Names {
+			content, err := Render(name, locale, time.UTC, sampleData())
+			if assert.NoError(t, err, "%s/%s", locale, name) {
+				assert.NotEmpty(t, content.Subject, "%s/%s", locale, name)
+				assert.NotEmpty(t, content.Text, "%s/%s", locale, name)
+				assert.NotEmpty(t, content.HTML, "%s/%s", locale, name)
+			}
+		}
+	}
+}
+
+func TestRenderRecipientTimeZone(t *testing.T) {
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	content, err := Render("appointment_created", "en", moscow, sampleData())
+	assert.NoError(t, err)
+	assert.Equal(t, "Appointment booked for Tue, 10 Mar 2026 12:00 MSK", content.Subject)
+	assert.Contains(t, content.Text, "12:00 MSK - 12:30")
+}
+
+func TestRenderLocales(t *testing.T) {
+	content, err := Render("appointment_status", "ru", time.UTC, sampleData())
+	assert.NoError(t, err)
+	assert.Equal(t, "Приём 10.03.2026 09:00 UTC: подтверждён", content.Subject)
+	// Unsupported locale falls back to English
+	content, err = Render("appointment_status", "de", time.UTC, sampleData())
+	assert.NoError(t, err)
+	assert.Equal(t, "Appointment on Tue, 10 Mar 2026 09:00 UTC is confirmed", content.Subject)
+	_, err = Render("unknown", "en", time.UTC, sampleData())
+	assert.Error(t, err)
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	content, err := Render("appointment_created", "en", time.UTC, sampleData())
+	assert.NoError(t, err)
+	assert.Contains(t, content.HTML, "Central &lt;Clinic&gt;")
+	assert.Contains(t, content.Text, "Central <Clinic>")
+}
+
+func TestRenderAgendaDigest(t *testing.T) {
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	data := sampleData()
+	data.Date = time.Date(2026, 3, 10, 0, 0, 0, 0, moscow)
+	content, err := Render("agenda_digest", "en", moscow, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Your agenda for Tue, 10 Mar 2026: 1 appointment(s)", content.Subject)
+	assert.Contains(t, content.Text, "12:00 - 12:30 patient@test.com (Consultation), confirmed")
+	assert.Contains(t, content.HTML, `<a href="https://schedule.test/api/medical_records/?patient_id=1">Notes</a>`)
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownAction         = errors.New("Unknown appointment action")
+	ErrActionNotAllowed      = errors.New("You are not allowed to perform this action")
+	ErrTransitionNotAllowed  = errors.New("Appointment status does not allow this action")
+	ErrAppointmentNotStarted = errors.New("Appointment has not started yet")
+)
+
+type AppointmentTransition struct {
+	From           []string
+	To             string
+	DoctorAllowed  bool
+	PatientAllowed bool
+}
+
+// Appointment state machine: action name -> allowed source statuses, target status and actors
+var AppointmentTransitions = map[string]AppointmentTransition{
+	"confirm": {
+		From:          []string{model.AppointmentRequested},
+		To:            model.AppointmentConfirmed,
+		DoctorAllowed: true,
+	},
+	"check-in": {
+		From:          []string{model.AppointmentConfirmed},
+		To:            model.AppointmentCheckedIn,
+		DoctorAllowed: true,
+	},
+	"complete": {
+		From:          []string{model.AppointmentCheckedIn},
+		To:            model.AppointmentCompleted,
+		DoctorAllowed: true,
+	},
+	"no-show": {
+		From:          []string{model.AppointmentRequested, model.AppointmentConfirmed},
+		To:            model.AppointmentNoShow,
+		DoctorAllowed: true,
+	},
+	"cancel": {
+		From:           []string{model.AppointmentRequested, model.AppointmentConfirmed},
+		To:             model.AppointmentCancelled,
+		DoctorAllowed:  true,
+		PatientAllowed: true,
+	},
+}
+
+func OccupyingAppointments(db *gorm.DB) *gorm.DB {
+	//Appointments which still take doctor time
+	return db.Where("status NOT IN ?", []string{model.AppointmentCancelled, model.AppointmentNoShow})
+}
+
+func IsAppointmentFinal(appointment model.Appointment) bool {
+	//Completed, cancelled and no-show appointments cannot be changed anymore
+	switch appointment.Status {
+	case model.AppointmentCompleted, model.AppointmentCancelled, model.AppointmentNoShow:
+		return true
+	}
+	return false
+}
+
+func ApplyAppointmentAction(appointment *model.Appointment, action string, actorID uuid.UUID, managesDoctor bool, now time.Time) error {
+	//Moving appointment to the next status if action is allowed for actor
+	//Staff managing appointments of the doctor (managesDoctor) performs actions of the doctor
+	transition, ok := AppointmentTransitions[action]
+	if !ok {
+		return ErrUnknownAction
+	}
+	isDoctor := actorID == appointment.DoctorID || managesDoctor
+	isPatient := actorID == appointment.PatientID
+	if !(transition.DoctorAllowed && isDoctor) && !(transition.PatientAllowed && isPatient) {
+		return ErrActionNotAllowed
+	}
+	status := appointment.Status
+	if status == "" {
+		status = model.AppointmentRequested
+	}
+	allowed := false
+	for _, from := range transition.From {
+		if from == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return ErrTransitionNotAllowed
+	}
+	if transition.To == model.AppointmentNoShow && now.Before(appointment.TimeStart) {
+		return ErrAppointmentNotStarted
+	}
+	appointment.Status = transition.To
+	switch transition.To {
+	case model.AppointmentConfirmed:
+		appointment.ConfirmedAt = &now
+	case model.AppointmentCheckedIn:
+		appointment.CheckedInAt = &now
+	case model.AppointmentCompleted:
+		appointment.CompletedAt = &now
+	case model.AppointmentNoShow:
+		appointment.NoShowAt = &now
+	case model.AppointmentCancelled:
+		appointment.CancelledAt = &now
+		appointment.CancelledBy = &actorID
+	}
+	return nil
+}
+
+func TransitionAppointment(db *gorm.DB, id string, action string, actorID uuid.UUID, managesDoctor bool) (model.Appointment, error) {
+	//Locking appointment row and applying action to it
+	var appointment model.Appointment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&appointment, id).Error; err != nil {
+			return err
+		}
+		if err := ApplyAppointmentAction(&appointment, action, actorID, managesDoctor, time.Now()); err != nil {
+			return err
+		}
+		return tx.Save(&appointment).Error
+	})
+	return appointment, err
+}
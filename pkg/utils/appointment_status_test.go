@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"ScheduleAPI/pkg/model"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppointmentLifecycle(t *testing.T) {
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	now := time.Date(2026, 11, 2, 10, 0, 0, 0, time.UTC)
+	appointment := model.Appointment{DoctorID: doctorID, PatientID: patientID, TimeStart: now, TimeEnd: now.Add(time.Hour)}
+
+	// Patient cannot confirm own appointment
+	assert.ErrorIs(t, ApplyAppointmentAction(&appointment, "confirm", patientID, false, now), ErrActionNotAllowed)
+	// Appointment must be checked in before completion
+	assert.ErrorIs(t, ApplyAppointmentAction(&appointment, "complete", doctorID, false, now), ErrTransitionNotAllowed)
+	for _, action := range []string{"confirm", "check-in", "complete"} {
+		assert.NoError(t, ApplyAppointmentAction(&appointment, action, doctorID, false, now), action)
+	}
+	assert.Equal(t, model.AppointmentCompleted, appointment.Status)
+	assert.NotNil(t, appointment.ConfirmedAt)
+	assert.NotNil(t, appointment.CheckedInAt)
+	assert.NotNil(t, appointment.CompletedAt)
+	// Completed appointment cannot be cancelled
+	assert.ErrorIs(t, ApplyAppointmentAction(&appointment, "cancel", patientID, false, now), ErrTransitionNotAllowed)
+}
+
+func TestAppointmentCancelAndNoShow(t *testing.T) {
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	start := time.Date(2026, 11, 2, 10, 0, 0, 0, time.UTC)
+	appointment := model.Appointment{DoctorID: doctorID, PatientID: patientID, Status: model.AppointmentConfirmed, TimeStart: start}
+
+	assert.ErrorIs(t, ApplyAppointmentAction(&appointment, "no-show", doctorID, false, start.Add(-time.Minute)), ErrAppointmentNotStarted)
+	assert.ErrorIs(t, ApplyAppointmentAction(&appointment, "cancel", uuid.Must(uuid.NewV4()), false, start), ErrActionNotAllowed)
+	assert.ErrorIs(t, ApplyAppointmentAction(&appointment, "delete", doctorID, false, start), ErrUnknownAction)
+	assert.NoError(t, ApplyAppointmentAction(&appointment, "cancel", patientID, false, start))
+	assert.Equal(t, model.AppointmentCancelled, appointment.Status)
+	assert.Equal(t, patientID, *appointment.CancelledBy)
+	assert.True(t, IsAppointmentFinal(appointment))
+}
+
+func TestAppointmentActionsOfManagingStaff(t *testing.T) {
+	doctorID := uuid.Must(uuid.NewV4())
+	patientID := uuid.Must(uuid.NewV4())
+	now := time.Date(2026, 11, 2, 10, 0, 0, 0, time.UTC)
+	appointment := model.Appointment{DoctorID: doctorID, PatientID: patientID, TimeStart: now, TimeEnd: now.Add(time.Hour)}
+	staffID := uuid.Must(uuid.NewV4())
+
+	// Staff not managing the doctor cannot act for the doctor
+	assert.ErrorIs(t, ApplyAppointmentAction(&appointment, "confirm", staffID, false, now), ErrActionNotAllowed)
+	// Staff managing the doctor confirms and cancels appointment
+	assert.NoError(t, ApplyAppointmentAction(&appointment, "confirm", staffID, true, now))
+	assert.Equal(t, model.AppointmentConfirmed, appointment.Status)
+	assert.NoError(t, ApplyAppointmentAction(&appointment, "cancel", staffID, true, now))
+	assert.Equal(t, model.AppointmentCancelled, appointment.Status)
+	assert.Equal(t, staffID, *appointment.CancelledBy)
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"errors"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrTypeNotOffered = errors.New("Doctor does not offer this appointment type")
+
+func DoctorOffersType(appointmentType model.AppointmentType, doctorID uuid.UUID) bool {
+	//Type without doctors list is offered by every doctor
+	if len(appointmentType.Doctors) == 0 {
+		return true
+	}
+	for _, d := range appointmentType.Doctors {
+		if d.DoctorID == doctorID {
+			return true
+		}
+	}
+	return false
+}
+
+func FetchAppointmentType(db *gorm.DB, id uint, doctorID uuid.UUID) (model.AppointmentType, error) {
+	//Fetching appointment type offered by doctor
+	var appointmentType model.AppointmentType
+	if err := db.Preload("Doctors").First(&appointmentType, id).Error; err != nil {
+		return appointmentType, err
+	}
+	if !DoctorOffersType(appointmentType, doctorID) {
+		return appointmentType, ErrTypeNotOffered
+	}
+	return appointmentType, nil
+}
+
+func ApplyHoldAppointmentType(hold *model.SlotHold, appointmentType *model.AppointmentType) {
+	//Deriving end of held slot and buffers from visit type the patient books
+	hold.AppointmentTypeID = &appointmentType.ID
+	hold.TimeEnd = hold.TimeStart.Add(appointmentType.Duration)
+	hold.BufferBefore = appointmentType.BufferBefore
+	hold.BufferAfter = appointmentType.BufferAfter
+}
+
+func ApplyAppointmentType(appointment *model.Appointment, appointmentType *model.AppointmentType) {
+	//Deriving end of appointment and buffers from its type, nil type removes them
+	if appointmentType == nil {
+		appointment.AppointmentTypeID = nil
+		appointment.BufferBefore = 0
+		appointment.BufferAfter = 0
+		return
+	}
+	appointment.AppointmentTypeID = &appointmentType.ID
+	appointment.TimeEnd = appointment.TimeStart.Add(appointmentType.Duration)
+	appointment.BufferBefore = appointmentType.BufferBefore
+	appointment.BufferAfter = appointmentType.BufferAfter
+}
@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Number of audit entries verified in one query
+const auditBatchSize = 500
+
+// Fields left out of audit diff: timestamps and preloaded relations
+var auditIgnoredFields = map[string]bool{
+	"CreatedAt":   true,
+	"UpdatedAt":   true,
+	"DeletedAt":   true,
+	"Reschedules": true,
+	"Reminders":   true,
+}
+
+// User and request performing audited action
+type AuditActor struct {
+	ID        uuid.UUID
+	Email     string
+	Roles     []string
+	ClientIP  string
+	RequestID string
+}
+
+// Change of one field in audit diff, Old is missing for created and New for deleted objects
+type AuditChange struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// Result of audit hash chain verification
+type AuditVerification struct {
+	Checked int  `json:"checked"`
+	Valid   bool `json:"valid"`
+	//First entry whose hash or link to previous entry does not match
+	BrokenID uint `json:"broken_id,omitempty"`
+	//Hash of the last entries of all chains, keep it outside of database to detect removed tail entries
+	LastHash string `json:"last_hash"`
+}
+
+func AuditDiff(before, after interface{}) (map[string]AuditChange, error) {
+	//Comparing fields of object before and after change
+	//nil before means object is created, nil after means object is deleted
+	oldFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	diff := map[string]AuditChange{}
+	for name, value := range newFields {
+		if !auditIgnoredFields[name] && !reflect.DeepEqual(oldFields[name], value) {
+			diff[name] = AuditChange{Old: oldFields[name], New: value}
+		}
+	}
+	for name, value := range oldFields {
+		if _, ok := newFields[name]; !ok && !auditIgnoredFields[name] {
+			diff[name] = AuditChange{Old: value}
+		}
+	}
+	return diff, nil
+}
+
+func auditFields(object interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if object == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+func WriteAudit(tx *gorm.DB, actor AuditActor, action, resourceType string, resourceID uint, before, after interface{}) error {
+	//Recording change of object in transaction of the change
+	diff, err := AuditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	entry := newAuditEntry(actor, action, resourceType, resourceID)
+	entry.Diff = string(data)
+	return appendAudit(tx, []model.AuditEntry{entry})
+}
+
+func AuditReads(tx *gorm.DB, actor AuditActor, resourceType string, resourceIDs ...uint) error {
+	//Recording read of objects, one entry per object, runs in its own transaction when db is not one
+	if len(resourceIDs) == 0 {
+		return nil
+	}
+	entries := make([]model.AuditEntry, 0, len(resourceIDs))
+	for _, id := range resourceIDs {
+		entries = append(entries, newAuditEntry(actor, model.AuditRead, resourceType, id))
+	}
+	return appendAudit(tx, entries)
+}
+
+func newAuditEntry(actor AuditActor, action, resourceType string, resourceID uint) model.AuditEntry {
+	return model.AuditEntry{
+		ActorID:      actor.ID,
+		ActorEmail:   actor.Email,
+		ActorRoles:   strings.Join(actor.Roles, ","),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   strconv.FormatUint(uint64(resourceID), 10),
+		ClientIP:     actor.ClientIP,
+		RequestID:    actor.RequestID,
+	}
+}
+
+func auditChain(entry model.AuditEntry) string {
+	//Every audited object has its own hash chain
+	return entry.ResourceType + ":" + entry.ResourceID
+}
+
+func appendAudit(db *gorm.DB, entries []model.AuditEntry) error {
+	//Linking entries to the last entry of chain of their object under transaction-level lock of the chain,
+	//the lock is held until commit, so only changes of the same object append one after another
+	//Chains are locked in sorted order, reads of many objects do not deadlock each other
+	chains := map[string][]int{}
+	for i, entry := range entries {
+		chains[auditChain(entry)] = append(chains[auditChain(entry)], i)
+	}
+	keys := make([]string, 0, len(chains))
+	for key := range chains {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return db.Transaction(func(tx *gorm.DB) error {
+		//Postgres keeps microseconds, hash is computed from the stored value
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		for _, key := range keys {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "audit:"+key).Error; err != nil {
+				return err
+			}
+			first := entries[chains[key][0]]
+			var last model.AuditEntry
+			if err := tx.Where("resource_type = ? AND resource_id = ?", first.ResourceType, first.ResourceID).
+				Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+				return err
+			}
+			prevHash := last.Hash
+			for _, i := range chains[key] {
+				entries[i].CreatedAt = now
+				entries[i].PrevHash = prevHash
+				entries[i].Hash = AuditHash(entries[i])
+				prevHash = entries[i].Hash
+			}
+		}
+		return tx.Create(&entries).Error
+	})
+}
+
+func AuditHash(entry model.AuditEntry) string {
+	//SHA-256 of fields of entry and hash of the previous entry, fields are separated by zero byte
+	hash := sha256.New()
+	fields := []string{
+		entry.PrevHash,
+		entry.ActorID.String(),
+		entry.ActorEmail,
+		entry.ActorRoles,
+		entry.Action,
+		entry.ResourceType,
+		entry.ResourceID,
+		entry.ClientIP,
+		entry.RequestID,
+		entry.Diff,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	for _, field := range fields {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func VerifyAuditChain(db *gorm.DB) (AuditVerification, error) {
+	//Recomputing hash chains of all objects from the first entry
+	var result AuditVerification
+	chains := AuditChains{}
+	var lastID uint
+	for {
+		var entries []model.AuditEntry
+		if err := db.Where("id > ?", lastID).Order("id").Limit(auditBatchSize).Find(&entries).Error; err != nil {
+			return result, err
+		}
+		for _, entry := range entries {
+			if !chains.Link(entry) {
+				result.BrokenID = entry.ID
+				return result, nil
+			}
+			result.Checked++
+			lastID = entry.ID
+		}
+		if len(entries) < auditBatchSize {
+			result.Valid = true
+			result.LastHash = chains.Hash()
+			return result, nil
+		}
+	}
+}
+
+// Hash of the last verified entry of every audit chain
+type AuditChains map[string]string
+
+func (chains AuditChains) Link(entry model.AuditEntry) bool {
+	//Checking entry follows the last entry of its chain, entries are linked in order of id
+	if entry.PrevHash != chains[auditChain(entry)] || AuditHash(entry) != entry.Hash {
+		return false
+	}
+	chains[auditChain(entry)] = entry.Hash
+	return true
+}
+
+func (chains AuditChains) Hash() string {
+	//SHA-256 of the last hashes of all chains in order of chain, empty for empty audit log
+	if len(chains) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(chains))
+	for key := range chains {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(chains[key]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
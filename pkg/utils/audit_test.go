@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"ScheduleAPI/pkg/model"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditDiff(t *testing.T) {
+	doctorID := uuid.Must(uuid.NewV4())
+	before := model.Prescription{DrugName: "Aspirin", Dosage: "100mg", DoctorID: doctorID, DoctorEmail: "doctor@test.com"}
+	after := before
+	after.Dosage = "200mg"
+	after.UpdatedAt = time.Now()
+
+	// Only changed fields are recorded, timestamps are ignored
+	diff, err := AuditDiff(before, after)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]AuditChange{"Dosage": {Old: "100mg", New: "200mg"}}, diff)
+
+	// Created object has only new values
+	diff, err = AuditDiff(nil, before)
+	assert.NoError(t, err)
+	assert.Equal(t, AuditChange{New: "Aspirin"}, diff["DrugName"])
+	assert.Equal(t, AuditChange{New: doctorID.String()}, diff["DoctorID"])
+	assert.NotContains(t, diff, "CreatedAt")
+
+	// Deleted object has only old values
+	diff, err = AuditDiff(before, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, AuditChange{Old: "100mg"}, diff["Dosage"])
+
+	// Unchanged object has empty diff
+	diff, err = AuditDiff(before, before)
+	assert.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestAuditHashChain(t *testing.T) {
+	entry := model.AuditEntry{
+		ActorID:      uuid.Must(uuid.NewV4()),
+		ActorEmail:   "doctor@test.com",
+		ActorRoles:   "doctor",
+		Action:       model.AuditRead,
+		ResourceType: "medical_record",
+		ResourceID:   "1",
+		CreatedAt:    time.Date(2026, 11, 2, 10, 0, 0, 123456000, time.UTC),
+	}
+	hash := AuditHash(entry)
+	assert.Len(t, hash, 64)
+	// Hash does not depend on time zone of timestamp
+	local := entry
+	local.CreatedAt = entry.CreatedAt.In(time.FixedZone("UTC+3", 3*60*60))
+	assert.Equal(t, hash, AuditHash(local))
+
+	// Any changed field or link to another previous entry changes the hash
+	changed := entry
+	changed.ResourceID = "2"
+	assert.NotEqual(t, hash, AuditHash(changed))
+	linked := entry
+	linked.PrevHash = hash
+	assert.NotEqual(t, hash, AuditHash(linked))
+	// Fields are separated, moving text between fields changes the hash
+	shifted := entry
+	shifted.ActorEmail = entry.ActorEmail + entry.ActorRoles
+	shifted.ActorRoles = ""
+	assert.NotEqual(t, hash, AuditHash(shifted))
+}
+
+func TestAuditChainsPerObject(t *testing.T) {
+	chained := func(prevHash, resourceID string, minute int) model.AuditEntry {
+		entry := model.AuditEntry{
+			Action:       model.AuditUpdate,
+			ResourceType: "appointment",
+			ResourceID:   resourceID,
+			CreatedAt:    time.Date(2026, 11, 2, 10, minute, 0, 0, time.UTC),
+			PrevHash:     prevHash,
+		}
+		entry.Hash = AuditHash(entry)
+		return entry
+	}
+	// Interleaved entries of two objects link to the previous entry of their own object
+	first := chained("", "1", 0)
+	other := chained("", "2", 1)
+	second := chained(first.Hash, "1", 2)
+	chains := AuditChains{}
+	for _, entry := range []model.AuditEntry{first, other, second} {
+		assert.True(t, chains.Link(entry))
+	}
+	assert.Equal(t, AuditChains{"appointment:1": second.Hash, "appointment:2": other.Hash}, chains)
+	assert.Len(t, chains.Hash(), 64)
+	assert.Empty(t, AuditChains{}.Hash())
+
+	// Entry linked to another object or changed entry breaks the chain
+	assert.False(t, AuditChains{}.Link(chained(other.Hash, "1", 3)))
+	changed := second
+	changed.Action = model.AuditDelete
+	assert.False(t, AuditChains{"appointment:1": first.Hash}.Link(changed))
+
+	// Removed last entry of a chain changes hash of all chains
+	removed := AuditChains{}
+	removed.Link(first)
+	removed.Link(other)
+	assert.NotEqual(t, chains.Hash(), removed.Hash())
+}
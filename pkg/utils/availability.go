@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type Window struct {
+	TimeStart time.Time `json:"time_start"`
+	TimeEnd   time.Time `json:"time_end"`
+}
+
+func ParseExDates(exDates string) []time.Time {
+	//Parsing comma separated RFC3339 occurrence starts stored on recurring schedule
+	var result []time.Time
+	for _, value := range strings.Split(exDates, ",") {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(value)); err == nil {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func FormatExDates(exDates []time.Time) string {
+	values := make([]string, 0, len(exDates))
+	for _, t := range exDates {
+		values = append(values, t.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(values, ",")
+}
+
+func ExpandRecurringSchedule(rs model.RecurringSchedule, from, to time.Time) ([]Window, error) {
+	//Expanding recurring schedule into concrete windows overlapping [from, to)
+	//Occurrences keep wall-clock start and end of the first one in schedule time zone
+	rule, err := ParseRRule(rs.RRule)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(rs.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	dtstart := rs.TimeStart.In(loc)
+	dtend := rs.TimeEnd.In(loc)
+	endHour, endMin, endSec := dtend.Clock()
+	startYear, startMonth, startDay := dtstart.Date()
+	endYear, endMonth, endDay := dtend.Date()
+	days := int(time.Date(endYear, endMonth, endDay, 0, 0, 0, 0, time.UTC).Sub(time.Date(startYear, startMonth, startDay, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	//Occurrence cannot be longer than its duration plus DST shift
+	maxDuration := dtend.Sub(dtstart) + 2*time.Hour
+	exDates := ParseExDates(rs.ExDates)
+	var windows []Window
+	for _, start := range rule.Between(dtstart, from.Add(-maxDuration), to) {
+		year, month, day := start.Date()
+		end := WallClock(year, month, day+days, endHour, endMin, endSec, loc)
+		if !end.After(from) || !end.After(start) || containsTime(exDates, start) {
+			continue
+		}
+		windows = append(windows, Window{TimeStart: start.UTC(), TimeEnd: end.UTC()})
+	}
+	return windows, nil
+}
+
+func GetAvailabilityWindows(db *gorm.DB, doctorID uuid.UUID, from, to time.Time) ([]Window, error) {
+	//Collecting one-off and recurring schedules of doctor overlapping [from, to) without time-off
+	var windows []Window
+	var schedules []model.Schedule
+	if err := db.Where("doctor_id = ? AND time_start < ? AND time_end > ?", doctorID, to, from).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range schedules {
+		windows = append(windows, Window{TimeStart: s.TimeStart, TimeEnd: s.TimeEnd})
+	}
+	var recurringSchedules []model.RecurringSchedule
+	if err := db.Where("doctor_id = ? AND time_start < ?", doctorID, to).Find(&recurringSchedules).Error; err != nil {
+		return nil, err
+	}
+	for _, rs := range recurringSchedules {
+		occurrences, err := ExpandRecurringSchedule(rs, from, to)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, occurrences...)
+	}
+	//Time-off and clinic holidays override schedules
+	timeOffs, err := FindTimeOff(db, doctorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return SubtractWindows(MergeWindows(windows), timeOffWindows(timeOffs)), nil
+}
+
+func IsWithinSchedule(db *gorm.DB, doctorID uuid.UUID, timeStart, timeEnd time.Time) (bool, error) {
+	//Checking that whole time range is covered by doctor schedules
+	windows, err := GetAvailabilityWindows(db, doctorID, timeStart, timeEnd)
+	if err != nil {
+		return false, err
+	}
+	for _, w := range windows {
+		if !w.TimeStart.After(timeStart) && !w.TimeEnd.Before(timeEnd) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func MergeWindows(windows []Window) []Window {
+	//Merging overlapping and adjacent windows into sorted disjoint ones
+	sort.Slice(windows, func(i, j int) bool { return windows[i].TimeStart.Before(windows[j].TimeStart) })
+	var merged []Window
+	for _, w := range windows {
+		last := len(merged) - 1
+		if last >= 0 && !w.TimeStart.After(merged[last].TimeEnd) {
+			if w.TimeEnd.After(merged[last].TimeEnd) {
+				merged[last].TimeEnd = w.TimeEnd
+			}
+			continue
+		}
+		merged = append(merged, w)
+	}
+	return merged
+}
+
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, value := range times {
+		if value.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
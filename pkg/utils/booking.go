@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNoSchedule = errors.New("No free schedules for your request")
+	ErrSlotTaken  = errors.New("This time is already appointed")
+)
+
+// SQLSTATE of exclusion constraint violation (appointments_no_overlap)
+const exclusionViolationCode = "23P01"
+
+func LockDoctor(tx *gorm.DB, doctorID uuid.UUID) error {
+	//Serializing bookings of the same doctor until the end of transaction
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", doctorID.String()).Error
+}
+
+func FindOverlappingAppointments(db *gorm.DB, doctorID uuid.UUID, timeStart, timeEnd time.Time, excludeID uint) ([]model.Appointment, error) {
+	//Fetching appointments of doctor whose occupied range (including buffers) intersects [timeStart, timeEnd)
+	//Cancelled and no-show appointments do not take time
+	var appointments []model.Appointment
+	query := OccupyingAppointments(db).Where("doctor_id = ? AND block_start < ? AND block_end > ?", doctorID, timeEnd, timeStart)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	err := query.Find(&appointments).Error
+	return appointments, err
+}
+
+func BookAppointment(db *gorm.DB, appointment *model.Appointment) error {
+	//Creating or updating appointment atomically
+	//Checks and write are done in one transaction holding per-doctor lock,
+	//appointments_no_overlap constraint guarantees consistency on database level
+	//Appointment occupies its time range extended by type buffers
+	appointment.BlockStart = appointment.TimeStart.Add(-appointment.BufferBefore)
+	appointment.BlockEnd = appointment.TimeEnd.Add(appointment.BufferAfter)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := LockDoctor(tx, appointment.DoctorID); err != nil {
+			return err
+		}
+		timeOffs, err := FindTimeOff(tx, appointment.DoctorID, appointment.TimeStart, appointment.TimeEnd)
+		if err != nil {
+			return err
+		}
+		if len(timeOffs) > 0 {
+			return ErrTimeOff
+		}
+		available, err := IsWithinSchedule(tx, appointment.DoctorID, appointment.TimeStart, appointment.TimeEnd)
+		if err != nil {
+			return err
+		}
+		if !available {
+			return ErrNoSchedule
+		}
+		overlapping, err := FindOverlappingAppointments(tx, appointment.DoctorID, appointment.BlockStart, appointment.BlockEnd, appointment.ID)
+		if err != nil {
+			return err
+		}
+		//Time held by other patients is not available either
+		holds, err := FindOverlappingHolds(tx, appointment.DoctorID, appointment.BlockStart, appointment.BlockEnd, appointment.PatientID)
+		if err != nil {
+			return err
+		}
+		if len(overlapping) > 0 || len(holds) > 0 {
+			return ErrSlotTaken
+		}
+		if err := tx.Omit(clause.Associations).Save(appointment).Error; err != nil {
+			if IsExclusionViolation(err) {
+				return ErrSlotTaken
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+func IsExclusionViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == exclusionViolationCode
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/templates"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Layout of local day of agenda digest
+const DigestDateLayout = "2006-01-02"
+
+func LocalDay(date time.Time, loc *time.Location) (time.Time, time.Time) {
+	//Bounds of local day containing date, day may be 23 or 25 hours long on DST change
+	year, month, day := date.In(loc).Date()
+	return WallClock(year, month, day, 0, 0, 0, loc), WallClock(year, month, day+1, 0, 0, 0, loc)
+}
+
+func DoctorAgenda(db *gorm.DB, doctorID uuid.UUID, start, end time.Time) ([]model.Appointment, error) {
+	//Appointments of doctor starting in [start, end), cancelled and no-show appointments are left out
+	var appointments []model.Appointment
+	err := db.Where("doctor_id = ? AND time_start >= ? AND time_start < ?", doctorID, start, end).
+		Where("status NOT IN ?", []string{model.AppointmentCancelled, model.AppointmentNoShow}).
+		Order("time_start").Find(&appointments).Error
+	return appointments, err
+}
+
+func AgendaDigestData(db *gorm.DB, doctor model.UserProfile, date time.Time, baseURL string) (templates.Data, error) {
+	//Collecting appointments of doctor for local day of date with links to their details and patient notes
+	loc := UserLocation(db, doctor.UserID)
+	start, end := LocalDay(date, loc)
+	appointments, err := DoctorAgenda(db, doctor.UserID, start, end)
+	if err != nil {
+		return templates.Data{}, err
+	}
+	typeNames := map[uint]string{}
+	agenda := make([]templates.AgendaItem, 0, len(appointments))
+	for _, appointment := range appointments {
+		item := templates.AgendaItem{
+			Appointment:    appointment,
+			AppointmentURL: fmt.Sprintf("%s/api/appointments/%d", baseURL, appointment.ID),
+			NotesURL:       fmt.Sprintf("%s/api/medical_records/?patient_id=%s", baseURL, appointment.PatientID),
+		}
+		if appointment.AppointmentTypeID != nil {
+			name, ok := typeNames[*appointment.AppointmentTypeID]
+			if !ok {
+				var appointmentType model.AppointmentType
+				if err := db.Unscoped().First(&appointmentType, *appointment.AppointmentTypeID).Error; err == nil {
+					name = appointmentType.Name
+				}
+				typeNames[*appointment.AppointmentTypeID] = name
+			}
+			item.TypeName = name
+		}
+		agenda = append(agenda, item)
+	}
+	return templates.Data{Date: start, Agenda: agenda, Location: UserClinicName(db, doctor.UserID)}, nil
+}
+
+func SendDueDigests(db *gorm.DB, digestTime time.Duration, baseURL string, now time.Time) (int, error) {
+	//Queueing "Digest" notifications for doctors subscribed to daily agenda whose local time passed digestTime
+	//Digest is recorded per doctor and local day, so it is sent once even with several API instances
+	//Days without appointments are recorded without notification
+	var doctors []model.UserProfile
+	if err := db.Where("daily_digest = ?", true).Find(&doctors).Error; err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, doctor := range doctors {
+		local := now.In(UserLocation(db, doctor.UserID))
+		if time.Duration(local.Hour())*time.Hour+time.Duration(local.Minute())*time.Minute < digestTime {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			digest := model.AgendaDigest{DoctorID: doctor.UserID, Date: local.Format(DigestDateLayout)}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&digest)
+			if result.Error != nil || result.RowsAffected == 0 {
+				//Digest of the day is already sent
+				return result.Error
+			}
+			data, err := AgendaDigestData(tx, doctor, local, baseURL)
+			if err != nil {
+				return err
+			}
+			if len(data.Agenda) == 0 {
+				return nil
+			}
+			if err := tx.Model(&digest).Update("appointments", len(data.Agenda)).Error; err != nil {
+				return err
+			}
+			if err := CreateNotification(tx, "Digest", doctor.Email, doctor.UserID, data); err != nil {
+				return err
+			}
+			sent++
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to send agenda digest to %s: %v", doctor.UserID, err)
+		}
+	}
+	return sent, nil
+}
+
+func RunDigestScheduler(db *gorm.DB, interval, digestTime time.Duration, baseURL string) {
+	//Background loop sending daily agenda digests, run it in separate goroutine
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := SendDueDigests(db, digestTime, baseURL, time.Now()); err != nil {
+			log.Print("Failed to send agenda digests: ", err)
+		}
+	}
+}
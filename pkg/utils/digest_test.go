@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalDay(t *testing.T) {
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	// 22:30 UTC is already next day in Moscow
+	start, end := LocalDay(time.Date(2026, 3, 10, 22, 30, 0, 0, time.UTC), moscow)
+	assert.Equal(t, time.Date(2026, 3, 11, 0, 0, 0, 0, moscow), start)
+	assert.Equal(t, time.Date(2026, 3, 12, 0, 0, 0, 0, moscow), end)
+
+	// Day of DST change is 23 hours long
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	start, end = LocalDay(time.Date(2026, 3, 29, 12, 0, 0, 0, berlin), berlin)
+	assert.Equal(t, 23*time.Hour, end.Sub(start))
+}
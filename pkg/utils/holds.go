@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Bounds of hold lifetime in minutes
+const (
+	DefaultHoldMinutes = 10
+	MaxHoldMinutes     = 30
+)
+
+var ErrHoldExpired = errors.New("Hold has expired")
+
+func ActiveHolds(db *gorm.DB) *gorm.DB {
+	//Holds which are neither confirmed nor expired
+	return db.Where("expires_at > ? AND appointment_id IS NULL", time.Now())
+}
+
+func FindOverlappingHolds(db *gorm.DB, doctorID uuid.UUID, timeStart, timeEnd time.Time, excludePatientID uuid.UUID) ([]model.SlotHold, error) {
+	//Fetching active holds of other patients whose held range (including buffers) intersects [timeStart, timeEnd)
+	var holds []model.SlotHold
+	err := ActiveHolds(db).
+		Where("doctor_id = ? AND block_start < ? AND block_end > ?", doctorID, timeEnd, timeStart).
+		Where("patient_id <> ?", excludePatientID).
+		Find(&holds).Error
+	return holds, err
+}
+
+func PlaceHold(db *gorm.DB, hold *model.SlotHold) error {
+	//Reserving doctor time range for patient until hold.ExpiresAt
+	//Previous checkout holds of the patient for the same doctor are released, waitlist offers are kept
+	return placeHold(db, hold, true)
+}
+
+func PlaceOfferHold(db *gorm.DB, hold *model.SlotHold) error {
+	//Reserving slot offered to waitlisted patient, other holds of the patient are kept
+	hold.WaitlistOffer = true
+	return placeHold(db, hold, false)
+}
+
+func placeHold(db *gorm.DB, hold *model.SlotHold, releasePrevious bool) error {
+	//Hold keeps buffers of its visit type free, like appointment booked from it
+	hold.BlockStart = hold.TimeStart.Add(-hold.BufferBefore)
+	hold.BlockEnd = hold.TimeEnd.Add(hold.BufferAfter)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := LockDoctor(tx, hold.DoctorID); err != nil {
+			return err
+		}
+		available, err := IsWithinSchedule(tx, hold.DoctorID, hold.TimeStart, hold.TimeEnd)
+		if err != nil {
+			return err
+		}
+		if !available {
+			return ErrNoSchedule
+		}
+		appointments, err := FindOverlappingAppointments(tx, hold.DoctorID, hold.BlockStart, hold.BlockEnd, 0)
+		if err != nil {
+			return err
+		}
+		holds, err := FindOverlappingHolds(tx, hold.DoctorID, hold.BlockStart, hold.BlockEnd, hold.PatientID)
+		if err != nil {
+			return err
+		}
+		if len(appointments) > 0 || len(holds) > 0 {
+			return ErrSlotTaken
+		}
+		if releasePrevious {
+			err = tx.Where("doctor_id = ? AND patient_id = ? AND appointment_id IS NULL AND NOT waitlist_offer", hold.DoctorID, hold.PatientID).
+				Delete(&model.SlotHold{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(hold).Error
+	})
+}
+
+func ConfirmHold(db *gorm.DB, hold *model.SlotHold, appointmentType *model.AppointmentType) (model.Appointment, error) {
+	//Converting hold into appointment, optionally of given type
+	appointment := model.Appointment{
+		DoctorID:     hold.DoctorID,
+		DoctorEmail:  hold.DoctorEmail,
+		PatientID:    hold.PatientID,
+		PatientEmail: hold.PatientEmail,
+		TimeStart:    hold.TimeStart,
+		TimeEnd:      hold.TimeEnd,
+	}
+	if appointmentType != nil {
+		ApplyAppointmentType(&appointment, appointmentType)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := LockDoctor(tx, hold.DoctorID); err != nil {
+			return err
+		}
+		if !hold.ExpiresAt.After(time.Now()) {
+			return ErrHoldExpired
+		}
+		if err := BookAppointment(tx, &appointment); err != nil {
+			return err
+		}
+		hold.AppointmentID = &appointment.ID
+		if err := tx.Save(hold).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hold).Error
+	})
+	return appointment, err
+}
+
+func SweepExpiredHolds(db *gorm.DB) ([]model.SlotHold, error) {
+	//Releasing holds which were not confirmed in time
+	var holds []model.SlotHold
+	err := db.Where("expires_at <= ? AND appointment_id IS NULL", time.Now()).Find(&holds).Error
+	if err != nil || len(holds) == 0 {
+		return nil, err
+	}
+	return holds, db.Delete(&holds).Error
+}
+
+func RunHoldSweeper(db *gorm.DB, interval, offerTTL time.Duration) {
+	//Background loop releasing expired holds and waitlist offers, run it in separate goroutine
+	//Released time is offered to waitlisted patients
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		holds, err := SweepExpiredHolds(db)
+		if err != nil {
+			log.Print("Failed to release expired holds: ", err)
+		}
+		doctors, err := ExpireWaitlist(db)
+		if err != nil {
+			log.Print("Failed to expire waitlist: ", err)
+		}
+		for _, h := range holds {
+			doctors = append(doctors, h.DoctorID)
+		}
+		processed := map[uuid.UUID]bool{}
+		for _, doctorID := range doctors {
+			if !processed[doctorID] {
+				processed[doctorID] = true
+				ProcessWaitlistLogged(db, doctorID, offerTTL)
+			}
+		}
+		if len(holds) > 0 {
+			log.Printf("Released %d expired holds", len(holds))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/stream"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// PostgreSQL channel announcing ids of committed in-app notifications
+const notificationsChannel = "notifications"
+
+func ListenNotifications(ctx context.Context, db *gorm.DB, hub stream.Hub) error {
+	//Publishing in-app notifications to connected users as soon as transactions creating them commit
+	//Every API instance listens on its own, so users connected to any instance receive notifications
+	//Returns when ctx is done or connection fails, missed notifications are replayed by clients reconnecting with Last-Event-ID
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn interface{}) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("Listening for notifications requires pgx connection")
+		}
+		pgConn := stdlibConn.Conn()
+		//Listening connection is not returned to pool
+		defer pgConn.Close(context.Background())
+		if _, err := pgConn.Exec(ctx, "LISTEN "+notificationsChannel); err != nil {
+			return err
+		}
+		for {
+			event, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			id, err := strconv.ParseUint(event.Payload, 10, 64)
+			if err != nil {
+				continue
+			}
+			var notification model.Notification
+			if err := db.WithContext(ctx).First(&notification, id).Error; err != nil {
+				log.Printf("Failed to fetch notification %d for stream: %v", id, err)
+				continue
+			}
+			hub.Publish(notification)
+		}
+	})
+}
+
+func RunNotificationListener(db *gorm.DB, hub stream.Hub, retry time.Duration) {
+	//Background loop streaming notifications, run it in separate goroutine
+	for {
+		err := ListenNotifications(context.Background(), db, hub)
+		log.Print("Notification listener stopped, restarting: ", err)
+		time.Sleep(retry)
+	}
+}
@@ -2,44 +2,139 @@ package utils
 
 import (
 	"ScheduleAPI/pkg/model"
-	"log"
-	"os"
+	"ScheduleAPI/pkg/templates"
 	"strconv"
+	"time"
 
 	"github.com/gofrs/uuid"
 
-	"gopkg.in/gomail.v2"
 	"gorm.io/gorm"
 )
 
-func CreateNotification(db *gorm.DB, notificationText, notificationType, userEmail string, userID uuid.UUID) error {
+// Templates of notification types
+var notificationTemplates = map[string]string{
+	"Create":        "appointment_created",
+	"Change":        "appointment_changed",
+	"Cancel":        "appointment_cancelled",
+	"Status":        "appointment_status",
+	"Reschedule":    "appointment_rescheduled",
+	"Reminder":      "appointment_reminder",
+	"Prescription":  "prescription_issued",
+	"WaitlistOffer": "waitlist_offer",
+	"Digest":        "agenda_digest",
+}
+
+func CreateNotification(db *gorm.DB, notificationType, userEmail string, userID uuid.UUID, data templates.Data) error {
+	//Rendering notification in locale and time zone of recipient, storing it and queueing its delivery
+	//through channels enabled by user preferences, email and SMS are postponed until the end of quiet hours
+	//SMS is skipped for users without phone number
+	//Pass transaction of the change being notified, so notification is written only when change is committed
+	loc := UserLocation(db, userID)
+	content, err := RenderNotification(db, notificationType, userID, data)
+	if err != nil {
+		return err
+	}
+	preferences, err := UserPreferences(db, userID)
+	if err != nil {
+		return err
+	}
+	var profile model.UserProfile
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&profile).Error; err != nil {
+		return err
+	}
+	now := time.Now()
 	notification := model.Notification{
-		Type:      notificationType,
-		UserID:    userID,
-		UserEmail: userEmail,
-		Text:      notificationText,
+		Type:           notificationType,
+		UserID:         userID,
+		UserEmail:      userEmail,
+		Subject:        content.Subject,
+		Text:           content.Text,
+		HTML:           content.HTML,
+		DeliveryStatus: model.NotificationPending,
+	}
+	channels := ResolveChannels(preferences, notificationType)
+	if !channels[model.ChannelInApp] {
+		//Notification is kept for history but hidden from in-app list
+		notification.ReadAt = &now
+		notification.ArchivedAt = &now
+	}
+	for _, channel := range Channels {
+		if !channels[channel] || (channel == model.ChannelSMS && profile.Phone == "") {
+			continue
+		}
+		nextAttemptAt := now
+		if intrusiveChannels[channel] {
+			if end, quiet := QuietHoursEnd(profile.QuietHoursStart, profile.QuietHoursEnd, loc, now); quiet {
+				nextAttemptAt = end
+			}
+		}
+		notification.Deliveries = append(notification.Deliveries, model.NotificationOutbox{
+			Channel:       channel,
+			Status:        model.NotificationPending,
+			NextAttemptAt: nextAttemptAt,
+		})
 	}
+	if len(notification.Deliveries) == 0 {
+		notification.DeliveryStatus = model.NotificationSkipped
+	}
+	//Deliveries are created together with notification
 	if err := db.Create(&notification).Error; err != nil {
 		return err
 	}
-	//Sending email
-	m := gomail.NewMessage()
-	m.SetHeader("From", os.Getenv("EMAIL_USER"))
-	m.SetHeader("To", userEmail)
-	m.SetHeader("Subject", "New doctor appointment!")
-	m.SetBody("text/html", notificationText)
+	if notification.ArchivedAt != nil {
+		return nil
+	}
+	//Connected users get notification when transaction commits, PostgreSQL drops it on rollback
+	return db.Exec("SELECT pg_notify(?, ?)", notificationsChannel, strconv.FormatUint(uint64(notification.ID), 10)).Error
+}
 
-	emailHost := os.Getenv("EMAIL_HOST")
-	emailPort, err := strconv.Atoi(os.Getenv("EMAIL_PORT"))
-	if err != nil {
-		panic(err)
+func RenderNotification(db *gorm.DB, notificationType string, userID uuid.UUID, data templates.Data) (templates.Content, error) {
+	//Rendering notification of the type in locale and time zone of recipient
+	return templates.Render(notificationTemplates[notificationType], UserLocale(db, userID), UserLocation(db, userID), data)
+}
+
+func AppointmentNotificationData(db *gorm.DB, appointment model.Appointment) templates.Data {
+	//Appointment details with clinic of doctor as location
+	return templates.Data{Appointment: &appointment, Location: UserClinicName(db, appointment.DoctorID)}
+}
+
+func NotifyParticipants(db *gorm.DB, notificationType string, data templates.Data) error {
+	//Creating notification of appointment for its doctor and patient and event for their webhooks
+	appointment := data.Appointment
+	if err := CreateNotification(db, notificationType, appointment.DoctorEmail, appointment.DoctorID, data); err != nil {
+		return err
+	}
+	if err := CreateNotification(db, notificationType, appointment.PatientEmail, appointment.PatientID, data); err != nil {
+		return err
 	}
-	emailUser := os.Getenv("EMAIL_USER")
-	emailPassword := os.Getenv("EMAIL_PASSWORD")
+	return EmitEvent(db, appointmentEvents[notificationType], appointment, appointment.DoctorID, appointment.PatientID)
+}
 
-	d := gomail.NewDialer(emailHost, emailPort, emailUser, emailPassword)
-	if err := d.DialAndSend(m); err != nil {
-		log.Print(err)
+func UserLocale(db *gorm.DB, userID uuid.UUID) string {
+	//Locale of user profile, English by default
+	var profile model.UserProfile
+	if err := db.Where("user_id = ?", userID).First(&profile).Error; err != nil || profile.Locale == "" {
+		return templates.DefaultLocale
+	}
+	return profile.Locale
+}
+
+func UserPhone(db *gorm.DB, userID uuid.UUID) string {
+	//Phone number of user profile, empty when it is not set
+	var profile model.UserProfile
+	if err := db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		return ""
+	}
+	return profile.Phone
+}
+
+func UserClinicName(db *gorm.DB, userID uuid.UUID) string {
+	//Name of clinic from user profile, empty when user has no clinic
+	var clinic model.Clinic
+	err := db.Joins("JOIN user_profiles ON user_profiles.clinic_id = clinics.id").
+		Where("user_profiles.user_id = ? AND user_profiles.deleted_at IS NULL", userID).First(&clinic).Error
+	if err != nil {
+		return ""
 	}
-	return nil
+	return clinic.Name
 }
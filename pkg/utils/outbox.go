@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/notifier"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Subject of notifications stored without one
+const defaultNotificationSubject = "New doctor appointment!"
+
+// Number of outbox entries claimed by worker at once
+const outboxBatchSize = 50
+
+// Time claimed outbox entries are hidden from other workers, longer than sending a batch
+// Entries of worker stopped while sending are delivered again after it
+const outboxClaimLease = 15 * time.Minute
+
+type DeliveryPolicy struct {
+	MaxAttempts int
+	RetryBase   time.Duration
+	RetryMax    time.Duration
+}
+
+func (p DeliveryPolicy) RetryDelay(attempts int) time.Duration {
+	//Exponential backoff: RetryBase after first failed attempt, doubled after each next one
+	delay := p.RetryBase
+	for i := 1; i < attempts && delay < p.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > p.RetryMax {
+		delay = p.RetryMax
+	}
+	return delay
+}
+
+func claimOutboxEntries(db *gorm.DB) ([]model.NotificationOutbox, error) {
+	//Locking due entries with SKIP LOCKED and moving their next attempt past the lease in short transaction
+	var entries []model.NotificationOutbox
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.NotificationPending, now).
+			Order("next_attempt_at").Limit(outboxBatchSize).Find(&entries).Error
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+		ids := make([]uint, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		return tx.Model(&model.NotificationOutbox{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(outboxClaimLease)).Error
+	})
+	return entries, err
+}
+
+func DeliverNotifications(db *gorm.DB, channels map[string]notifier.Notifier, policy DeliveryPolicy) (int, error) {
+	//Delivering due outbox entries through their channels, returns number of processed entries
+	//Entries are claimed in short transaction, so several workers never deliver the same notification
+	//and no rows are locked while channels send. Result of every entry is recorded in its own
+	//transaction, failure of one record does not make sent notifications pending again
+	//In-app notifications are streamed to connected users on commit (see ListenNotifications),
+	//worker only records their delivery
+	entries, err := claimOutboxEntries(db)
+	if err != nil {
+		return 0, err
+	}
+	var firstErr error
+	for _, entry := range entries {
+		if err := deliverOutboxEntry(db, channels, policy, entry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return len(entries), firstErr
+}
+
+func deliverOutboxEntry(db *gorm.DB, channels map[string]notifier.Notifier, policy DeliveryPolicy, entry model.NotificationOutbox) error {
+	//Delivering one claimed entry and recording its result
+	var notification model.Notification
+	if err := db.First(&notification, entry.NotificationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			//Notification was deleted, nothing to deliver
+			return db.Delete(&entry).Error
+		}
+		return err
+	}
+	now := time.Now()
+	entry.Attempts++
+	var sendErr error
+	n, configured := channels[entry.Channel]
+	switch {
+	case entry.Channel == model.ChannelInApp:
+		//Stored notification is already visible in-app and streamed on commit
+	case !configured:
+		//Retrying cannot help until channel is configured
+		sendErr = fmt.Errorf("Notification channel %s is not configured", entry.Channel)
+		entry.Attempts = policy.MaxAttempts
+	default:
+		//Notifications created before templates have no subject
+		subject := notification.Subject
+		if subject == "" {
+			subject = defaultNotificationSubject
+		}
+		sendErr = n.Send(notifier.Message{
+			Type:      notification.Type,
+			UserID:    notification.UserID,
+			UserEmail: notification.UserEmail,
+			UserPhone: UserPhone(db, notification.UserID),
+			Subject:   subject,
+			Text:      notification.Text,
+			HTML:      notification.HTML,
+		})
+	}
+	switch {
+	case sendErr == nil:
+		entry.Status = model.NotificationSent
+		entry.LastError = ""
+		entry.SentAt = &now
+	case entry.Attempts >= policy.MaxAttempts:
+		log.Printf("Notification %d is dead on %s channel after %d attempts: %v", notification.ID, entry.Channel, entry.Attempts, sendErr)
+		entry.Status = model.NotificationDead
+		entry.LastError = sendErr.Error()
+	default:
+		entry.LastError = sendErr.Error()
+		entry.NextAttemptAt = now.Add(policy.RetryDelay(entry.Attempts))
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&entry).Error; err != nil {
+			return err
+		}
+		//Channels of notification may be recorded by several workers, status is aggregated under row lock
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&notification, notification.ID).Error; err != nil {
+			return err
+		}
+		return updateDeliveryStatus(tx, &notification)
+	})
+}
+
+func updateDeliveryStatus(tx *gorm.DB, notification *model.Notification) error {
+	//Aggregating delivery state of notification from its channels
+	//Pending while any channel is pending, dead when any channel failed, sent otherwise
+	var deliveries []model.NotificationOutbox
+	if err := tx.Where("notification_id = ?", notification.ID).Order("id").Find(&deliveries).Error; err != nil {
+		return err
+	}
+	status := model.NotificationSent
+	notification.DeliveryAttempts = 0
+	notification.DeliveryError = ""
+	for _, delivery := range deliveries {
+		switch {
+		case delivery.Status == model.NotificationPending:
+			status = model.NotificationPending
+		case delivery.Status == model.NotificationDead && status != model.NotificationPending:
+			status = model.NotificationDead
+		}
+		if delivery.Attempts > notification.DeliveryAttempts {
+			notification.DeliveryAttempts = delivery.Attempts
+		}
+		if delivery.LastError != "" {
+			notification.DeliveryError = delivery.LastError
+		}
+		if delivery.SentAt != nil && (notification.DeliveredAt == nil || delivery.SentAt.After(*notification.DeliveredAt)) {
+			notification.DeliveredAt = delivery.SentAt
+		}
+	}
+	notification.DeliveryStatus = status
+	return tx.Save(notification).Error
+}
+
+func RunNotificationWorker(db *gorm.DB, channels map[string]notifier.Notifier, policy DeliveryPolicy, interval time.Duration) {
+	//Background loop delivering queued notifications, run it in separate goroutine
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for {
+			processed, err := DeliverNotifications(db, channels, policy)
+			if err != nil {
+				log.Print("Failed to deliver notifications: ", err)
+			}
+			//Continuing while batches are full
+			if err != nil || processed < outboxBatchSize {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDelayBackoff(t *testing.T) {
+	policy := DeliveryPolicy{MaxAttempts: 5, RetryBase: 30 * time.Second, RetryMax: 5 * time.Minute}
+	cases := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.delay, policy.RetryDelay(tc.attempts), "attempts %d", tc.attempts)
+	}
+}
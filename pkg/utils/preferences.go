@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/notifier"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Notification channels in order of delivery
+var Channels = []string{model.ChannelEmail, model.ChannelInApp, model.ChannelSMS, model.ChannelWebhook}
+
+// Channels used when user has no preferences
+var defaultChannels = map[string]bool{
+	model.ChannelEmail:   true,
+	model.ChannelInApp:   true,
+	model.ChannelSMS:     false,
+	model.ChannelWebhook: false,
+}
+
+// Channels postponed during quiet hours
+var intrusiveChannels = map[string]bool{model.ChannelEmail: true, model.ChannelSMS: true}
+
+func NotificationTypes() []string {
+	types := make([]string, 0, len(notificationTemplates))
+	for notificationType := range notificationTemplates {
+		types = append(types, notificationType)
+	}
+	return types
+}
+
+func IsNotificationType(notificationType string) bool {
+	_, ok := notificationTemplates[notificationType]
+	return ok
+}
+
+func IsChannel(channel string) bool {
+	_, ok := defaultChannels[channel]
+	return ok
+}
+
+func ChannelConfigured(channels map[string]notifier.Notifier, channel string) bool {
+	//In-app notifications are stored and streamed by API itself, other channels need notifier
+	_, ok := channels[channel]
+	return ok || channel == model.ChannelInApp
+}
+
+func ResolveChannels(preferences []model.NotificationPreference, notificationType string) map[string]bool {
+	//Channels enabled for notification type
+	//Preference of the type overrides preference of any type, which overrides defaults
+	channels := map[string]bool{}
+	for channel, enabled := range defaultChannels {
+		channels[channel] = enabled
+	}
+	for _, preference := range preferences {
+		if preference.Type == model.AnyNotificationType {
+			channels[preference.Channel] = preference.Enabled
+		}
+	}
+	for _, preference := range preferences {
+		if preference.Type == notificationType {
+			channels[preference.Channel] = preference.Enabled
+		}
+	}
+	return channels
+}
+
+func ParseClock(value string) (time.Duration, error) {
+	//Parsing wall-clock time "HH:MM" into offset from midnight
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.New("Invalid time, use HH:MM format")
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func QuietHoursEnd(start, end string, loc *time.Location, now time.Time) (time.Time, bool) {
+	//Returning end of quiet hours when now falls into them
+	//Quiet hours may span midnight, e.g. "22:00" - "07:00"
+	if start == "" || end == "" || start == end {
+		return now, false
+	}
+	startOffset, err := ParseClock(start)
+	if err != nil {
+		return now, false
+	}
+	endOffset, err := ParseClock(end)
+	if err != nil {
+		return now, false
+	}
+	local := now.In(loc)
+	year, month, day := local.Date()
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	endOn := func(dayOffset int) time.Time {
+		return WallClock(year, month, day+dayOffset, int(endOffset/time.Hour), int(endOffset%time.Hour/time.Minute), 0, loc)
+	}
+	if startOffset < endOffset {
+		if clock >= startOffset && clock < endOffset {
+			return endOn(0), true
+		}
+		return now, false
+	}
+	switch {
+	case clock >= startOffset:
+		return endOn(1), true
+	case clock < endOffset:
+		return endOn(0), true
+	}
+	return now, false
+}
+
+func UserPreferences(db *gorm.DB, userID uuid.UUID) ([]model.NotificationPreference, error) {
+	var preferences []model.NotificationPreference
+	err := db.Where("user_id = ?", userID).Find(&preferences).Error
+	return preferences, err
+}
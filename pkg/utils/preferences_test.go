@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/notifier"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveChannels(t *testing.T) {
+	// Defaults without preferences
+	channels := ResolveChannels(nil, "Create")
+	assert.True(t, channels[model.ChannelEmail])
+	assert.True(t, channels[model.ChannelInApp])
+	assert.False(t, channels[model.ChannelSMS])
+
+	// Preference of the type overrides preference of any type
+	preferences := []model.NotificationPreference{
+		{Type: "Reminder", Channel: model.ChannelEmail, Enabled: true},
+		{Type: model.AnyNotificationType, Channel: model.ChannelEmail, Enabled: false},
+		{Type: model.AnyNotificationType, Channel: model.ChannelSMS, Enabled: true},
+	}
+	channels = ResolveChannels(preferences, "Reminder")
+	assert.True(t, channels[model.ChannelEmail])
+	assert.True(t, channels[model.ChannelSMS])
+	channels = ResolveChannels(preferences, "Cancel")
+	assert.False(t, channels[model.ChannelEmail])
+	assert.True(t, channels[model.ChannelSMS])
+	assert.True(t, channels[model.ChannelInApp])
+}
+
+func TestChannelConfigured(t *testing.T) {
+	channels := map[string]notifier.Notifier{model.ChannelEmail: notifier.NewMemoryNotifier()}
+	assert.True(t, ChannelConfigured(channels, model.ChannelEmail))
+	assert.True(t, ChannelConfigured(channels, model.ChannelInApp))
+	assert.False(t, ChannelConfigured(channels, model.ChannelSMS))
+	assert.False(t, ChannelConfigured(channels, model.ChannelWebhook))
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	tests := []struct {
+		name       string
+		start, end string
+		now        time.Time
+		quiet      bool
+		want       time.Time
+	}{
+		{"disabled", "", "", time.Date(2026, 3, 10, 23, 0, 0, 0, moscow), false, time.Time{}},
+		{"same day inside", "13:00", "15:00", time.Date(2026, 3, 10, 14, 0, 0, 0, moscow), true, time.Date(2026, 3, 10, 15, 0, 0, 0, moscow)},
+		{"same day outside", "13:00", "15:00", time.Date(2026, 3, 10, 15, 0, 0, 0, moscow), false, time.Time{}},
+		{"before midnight", "22:00", "07:00", time.Date(2026, 3, 10, 23, 30, 0, 0, moscow), true, time.Date(2026, 3, 11, 7, 0, 0, 0, moscow)},
+		{"after midnight", "22:00", "07:00", time.Date(2026, 3, 11, 2, 0, 0, 0, moscow), true, time.Date(2026, 3, 11, 7, 0, 0, 0, moscow)},
+		{"daytime", "22:00", "07:00", time.Date(2026, 3, 11, 12, 0, 0, 0, moscow), false, time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Quiet hours are evaluated in time zone of the user, not of the server
+			end, quiet := QuietHoursEnd(test.start, test.end, moscow, test.now.UTC())
+			assert.Equal(t, test.quiet, quiet)
+			if quiet {
+				assert.True(t, test.want.Equal(end), "got %v", end)
+			}
+		})
+	}
+}
+
+func TestQuietHoursEndAcrossDST(t *testing.T) {
+	// Clocks move forward at 02:00 on 29 March 2026 in Berlin, quiet hours still end at 07:00 local time
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2026, 3, 28, 23, 0, 0, 0, berlin)
+	end, quiet := QuietHoursEnd("22:00", "07:00", berlin, now)
+	assert.True(t, quiet)
+	assert.Equal(t, time.Date(2026, 3, 29, 7, 0, 0, 0, berlin), end.In(berlin))
+}
+
+func TestParseClock(t *testing.T) {
+	clock, err := ParseClock("07:30")
+	assert.NoError(t, err)
+	assert.Equal(t, 7*time.Hour+30*time.Minute, clock)
+	_, err = ParseClock("25:00")
+	assert.Error(t, err)
+}
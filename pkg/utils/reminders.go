@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Number of reminders sent in one transaction
+const reminderBatchSize = 50
+
+func ReminderTimes(appointment model.Appointment, offsets []time.Duration, now time.Time) []model.AppointmentReminder {
+	//Reminders appointment should have, reminders which time has passed are skipped
+	//Final appointments have no reminders
+	if IsAppointmentFinal(appointment) {
+		return nil
+	}
+	var reminders []model.AppointmentReminder
+	for _, offset := range offsets {
+		sendAt := appointment.TimeStart.Add(-offset)
+		if sendAt.After(now) {
+			reminders = append(reminders, model.AppointmentReminder{
+				AppointmentID: appointment.ID,
+				Offset:        offset,
+				SendAt:        sendAt,
+				Status:        model.ReminderPending,
+			})
+		}
+	}
+	return reminders
+}
+
+func ScheduleReminders(db *gorm.DB, appointment model.Appointment, offsets []time.Duration) error {
+	//Bringing pending reminders in line with appointment time and status
+	//Call it in transaction changing appointment, outdated reminders are cancelled
+	var pending []model.AppointmentReminder
+	if err := db.Where("appointment_id = ? AND status = ?", appointment.ID, model.ReminderPending).Find(&pending).Error; err != nil {
+		return err
+	}
+	desired := ReminderTimes(appointment, offsets, time.Now())
+	for _, reminder := range pending {
+		if i := indexOfReminder(desired, reminder); i >= 0 {
+			desired = append(desired[:i], desired[i+1:]...)
+			continue
+		}
+		if err := db.Model(&reminder).Update("status", model.ReminderCancelled).Error; err != nil {
+			return err
+		}
+	}
+	if len(desired) == 0 {
+		return nil
+	}
+	return db.Create(&desired).Error
+}
+
+func indexOfReminder(reminders []model.AppointmentReminder, reminder model.AppointmentReminder) int {
+	for i, r := range reminders {
+		if r.Offset == reminder.Offset && sameInstant(r.SendAt, reminder.SendAt) {
+			return i
+		}
+	}
+	return -1
+}
+
+func sameInstant(a, b time.Time) bool {
+	//Database keeps microseconds only
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+func SendDueReminders(db *gorm.DB) (int, error) {
+	//Queueing notifications of due reminders, returns number of processed reminders
+	//Reminders are locked with SKIP LOCKED, so several replicas never send the same reminder
+	processed := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reminders []model.AppointmentReminder
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND send_at <= ?", model.ReminderPending, time.Now()).
+			Order("send_at").Limit(reminderBatchSize).Find(&reminders).Error
+		if err != nil {
+			return err
+		}
+		for _, reminder := range reminders {
+			if err := sendReminder(tx, reminder); err != nil {
+				return err
+			}
+			processed++
+		}
+		return nil
+	})
+	return processed, err
+}
+
+func sendReminder(tx *gorm.DB, reminder model.AppointmentReminder) error {
+	now := time.Now()
+	var appointment model.Appointment
+	err := tx.First(&appointment, reminder.AppointmentID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	//Reminder of deleted, final, moved or already started appointment is not sent
+	if err != nil || IsAppointmentFinal(appointment) || !sameInstant(appointment.TimeStart.Add(-reminder.Offset), reminder.SendAt) ||
+		!appointment.TimeStart.After(now) {
+		return tx.Model(&reminder).Update("status", model.ReminderCancelled).Error
+	}
+	data := AppointmentNotificationData(tx, appointment)
+	if err := CreateNotification(tx, "Reminder", appointment.PatientEmail, appointment.PatientID, data); err != nil {
+		return err
+	}
+	return tx.Model(&reminder).Updates(map[string]interface{}{"status": model.ReminderSent, "sent_at": now}).Error
+}
+
+func RunReminderScheduler(db *gorm.DB, interval time.Duration) {
+	//Background loop sending due reminders, run it in separate goroutine
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for {
+			processed, err := SendDueReminders(db)
+			if err != nil {
+				log.Print("Failed to send reminders: ", err)
+			}
+			//Continuing while batches are full
+			if err != nil || processed < reminderBatchSize {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReminderTimes(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{24 * time.Hour, 2 * time.Hour}
+	appointment := model.Appointment{TimeStart: now.Add(48 * time.Hour), Status: model.AppointmentConfirmed}
+	appointment.ID = 7
+
+	reminders := ReminderTimes(appointment, offsets, now)
+	if assert.Len(t, reminders, 2) {
+		assert.Equal(t, now.Add(24*time.Hour), reminders[0].SendAt)
+		assert.Equal(t, now.Add(46*time.Hour), reminders[1].SendAt)
+		assert.Equal(t, uint(7), reminders[0].AppointmentID)
+		assert.Equal(t, model.ReminderPending, reminders[1].Status)
+	}
+
+	// Reminder time which has passed is skipped
+	appointment.TimeStart = now.Add(5 * time.Hour)
+	reminders = ReminderTimes(appointment, offsets, now)
+	if assert.Len(t, reminders, 1) {
+		assert.Equal(t, 2*time.Hour, reminders[0].Offset)
+	}
+
+	// Final appointments have no reminders
+	appointment.Status = model.AppointmentCancelled
+	assert.Empty(t, ReminderTimes(appointment, offsets, now))
+}
+
+func TestIndexOfReminderIgnoresNanoseconds(t *testing.T) {
+	sendAt := time.Date(2026, 3, 10, 9, 0, 0, 1500, time.UTC)
+	reminders := []model.AppointmentReminder{{Offset: time.Hour, SendAt: sendAt}}
+	assert.Equal(t, 0, indexOfReminder(reminders, model.AppointmentReminder{Offset: time.Hour, SendAt: sendAt.Truncate(time.Microsecond)}))
+	assert.Equal(t, -1, indexOfReminder(reminders, model.AppointmentReminder{Offset: 2 * time.Hour, SendAt: sendAt}))
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrNoticeTooShort = errors.New("Appointment cannot be rescheduled that close to its start")
+
+func SlotID(w Window) string {
+	//Opaque identifier of generated slot
+	return fmt.Sprintf("%d-%d", w.TimeStart.Unix(), w.TimeEnd.Unix())
+}
+
+func ParseSlotID(id string) (Window, error) {
+	start, end, found := strings.Cut(id, "-")
+	if !found {
+		return Window{}, errors.New("Invalid slot id")
+	}
+	startUnix, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return Window{}, errors.New("Invalid slot id")
+	}
+	endUnix, err := strconv.ParseInt(end, 10, 64)
+	if err != nil || endUnix <= startUnix {
+		return Window{}, errors.New("Invalid slot id")
+	}
+	return Window{TimeStart: time.Unix(startUnix, 0).UTC(), TimeEnd: time.Unix(endUnix, 0).UTC()}, nil
+}
+
+func RescheduleAppointment(db *gorm.DB, appointment *model.Appointment, timeStart, timeEnd time.Time, actorID uuid.UUID, minNotice time.Duration) error {
+	//Moving appointment to new time range keeping its identity and history of previous times
+	now := time.Now()
+	if appointment.TimeStart.Sub(now) < minNotice || timeStart.Sub(now) < minNotice {
+		return ErrNoticeTooShort
+	}
+	history := model.AppointmentReschedule{
+		AppointmentID: appointment.ID,
+		PreviousStart: appointment.TimeStart,
+		PreviousEnd:   appointment.TimeEnd,
+		NewStart:      timeStart,
+		NewEnd:        timeEnd,
+		RescheduledBy: actorID,
+	}
+	previous := *appointment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		appointment.TimeStart = timeStart
+		appointment.TimeEnd = timeEnd
+		if err := BookAppointment(tx, appointment); err != nil {
+			return err
+		}
+		return tx.Create(&history).Error
+	})
+	if err != nil {
+		*appointment = previous
+		return err
+	}
+	appointment.Reschedules = append(appointment.Reschedules, history)
+	return nil
+}
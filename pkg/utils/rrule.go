@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subset of iCalendar (RFC 5545) recurrence rules used by recurring schedules.
+// Supported parts: FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY.
+// maxRecurrencePeriods protects from endless expansion of broken rules.
+const maxRecurrencePeriods = 10000
+
+type WeekdayNum struct {
+	Weekday time.Weekday
+	//Ordinal of weekday inside of month (1MO, -1FR), 0 means every such weekday
+	N int
+}
+
+type RRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	//UNTIL without "Z" is wall-clock time in time zone of the series, Until keeps it in UTC fields
+	UntilFloating bool
+	ByDay         []WeekdayNum
+	ByMonthDay    []int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+func ParseRRule(rule string) (*RRule, error) {
+	//Parsing rule string like "FREQ=WEEKLY;BYDAY=MO,TU;UNTIL=20270331T235959Z"
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, errors.New("Empty recurrence rule")
+	}
+	r := &RRule{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("Invalid recurrence rule part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			value = strings.ToUpper(value)
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" {
+				return nil, fmt.Errorf("Unsupported recurrence frequency %q", value)
+			}
+			r.Freq = value
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("Invalid recurrence interval %q", value)
+			}
+			r.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("Invalid recurrence count %q", value)
+			}
+			r.Count = count
+		case "UNTIL":
+			until, err := parseICalTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid recurrence until %q", value)
+			}
+			r.Until = until
+			r.UntilFloating = !strings.HasSuffix(value, "Z")
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				day = strings.ToUpper(strings.TrimSpace(day))
+				if len(day) < 2 {
+					return nil, fmt.Errorf("Invalid recurrence weekday %q", day)
+				}
+				weekday, ok := weekdayCodes[day[len(day)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("Invalid recurrence weekday %q", day)
+				}
+				n := 0
+				if ordinal := day[:len(day)-2]; ordinal != "" {
+					var err error
+					n, err = strconv.Atoi(ordinal)
+					if err != nil || n == 0 || n < -5 || n > 5 {
+						return nil, fmt.Errorf("Invalid recurrence weekday %q", day)
+					}
+				}
+				r.ByDay = append(r.ByDay, WeekdayNum{Weekday: weekday, N: n})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(day))
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("Invalid recurrence month day %q", day)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "WKST":
+			//Weeks always start on Monday
+		default:
+			return nil, fmt.Errorf("Unsupported recurrence rule part %q", key)
+		}
+	}
+	if r.Freq == "" {
+		return nil, errors.New("Recurrence rule must contain FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("Recurrence rule cannot contain both COUNT and UNTIL")
+	}
+	for _, day := range r.ByDay {
+		if day.N != 0 && r.Freq != "MONTHLY" {
+			return nil, errors.New("Weekday ordinals are allowed only for MONTHLY rules")
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != "MONTHLY" {
+		return nil, errors.New("BYMONTHDAY is allowed only for MONTHLY rules")
+	}
+	return r, nil
+}
+
+func parseICalTime(value string) (time.Time, error) {
+	//Accepting UTC date-time, floating date-time and date values
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				//Date value includes the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("Invalid date-time")
+}
+
+func (r *RRule) Between(dtstart, after, before time.Time) []time.Time {
+	//Returning occurrence starts of the rule in range [after, before)
+	//Series starts at dtstart, which is an occurrence only when it matches the rule
+	//(e.g. not for WEEKLY rule whose BYDAY excludes weekday of dtstart)
+	//Floating UNTIL is resolved in time zone of dtstart
+	until := r.Until
+	if r.UntilFloating {
+		year, month, day := until.Date()
+		hour, min, sec := until.Clock()
+		until = WallClock(year, month, day, hour, min, sec, dtstart.Location())
+	}
+	var occurrences []time.Time
+	emitted := 0
+	for period := 0; period < maxRecurrencePeriods; period++ {
+		for _, candidate := range r.periodCandidates(dtstart, period) {
+			if candidate.Before(dtstart) {
+				continue
+			}
+			if !until.IsZero() && candidate.After(until) {
+				return occurrences
+			}
+			if r.Count > 0 && emitted >= r.Count {
+				return occurrences
+			}
+			if !candidate.Before(before) {
+				return occurrences
+			}
+			emitted++
+			if !candidate.Before(after) {
+				occurrences = append(occurrences, candidate)
+			}
+		}
+	}
+	return occurrences
+}
+
+func (r *RRule) periodCandidates(dtstart time.Time, period int) []time.Time {
+	//Returning sorted occurrence candidates of the n-th period of the rule
+	//Dates are calculated on civil calendar, wall-clock time of dtstart is kept in its location
+	loc := dtstart.Location()
+	hour, min, sec := dtstart.Clock()
+	year, month, day := dtstart.Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	at := func(date time.Time) time.Time {
+		return WallClock(date.Year(), date.Month(), date.Day(), hour, min, sec, loc)
+	}
+	var candidates []time.Time
+	switch r.Freq {
+	case "DAILY":
+		date := start.AddDate(0, 0, period*r.Interval)
+		if len(r.ByDay) == 0 || r.matchesWeekday(date.Weekday()) {
+			candidates = append(candidates, at(date))
+		}
+	case "WEEKLY":
+		//Weeks start on Monday
+		offset := (int(start.Weekday()) + 6) % 7
+		monday := start.AddDate(0, 0, -offset+period*r.Interval*7)
+		if len(r.ByDay) == 0 {
+			candidates = append(candidates, at(monday.AddDate(0, 0, offset)))
+		}
+		for _, wd := range r.ByDay {
+			candidates = append(candidates, at(monday.AddDate(0, 0, (int(wd.Weekday)+6)%7)))
+		}
+	case "MONTHLY":
+		first := time.Date(year, month+time.Month(period*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		daysInMonth := first.AddDate(0, 1, -1).Day()
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+			if day <= daysInMonth {
+				candidates = append(candidates, at(first.AddDate(0, 0, day-1)))
+			}
+			break
+		}
+		//Day must match both BYMONTHDAY and BYDAY when rule has both, e.g. every Friday 13th
+		for d := 1; d <= daysInMonth; d++ {
+			date := first.AddDate(0, 0, d-1)
+			if (len(r.ByMonthDay) == 0 || matchesMonthDay(r.ByMonthDay, d, daysInMonth)) &&
+				(len(r.ByDay) == 0 || matchesMonthWeekday(r.ByDay, date.Weekday(), d, daysInMonth)) {
+				candidates = append(candidates, at(date))
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return dedupTimes(candidates)
+}
+
+func (r *RRule) matchesWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMonthDay(monthDays []int, d, daysInMonth int) bool {
+	//Negative month days count from the end of month
+	for _, n := range monthDays {
+		if n == d || daysInMonth+n+1 == d {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMonthWeekday(days []WeekdayNum, weekday time.Weekday, d, daysInMonth int) bool {
+	//Ordinal counts weekdays from the start of month, negative ordinal from the end
+	ordinal := (d-1)/7 + 1
+	reverse := -((daysInMonth-d)/7 + 1)
+	for _, wd := range days {
+		if wd.Weekday == weekday && (wd.N == 0 || wd.N == ordinal || wd.N == reverse) {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupTimes(times []time.Time) []time.Time {
+	result := times[:0]
+	for i, t := range times {
+		if i > 0 && t.Equal(times[i-1]) {
+			continue
+		}
+		result = append(result, t)
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"ScheduleAPI/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRRuleWeekdays(t *testing.T) {
+	rule, err := ParseRRule("FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20261113T235959Z")
+	assert.NoError(t, err)
+	dtstart := time.Date(2026, 11, 4, 9, 0, 0, 0, time.UTC)
+	occurrences := rule.Between(dtstart, dtstart, dtstart.AddDate(1, 0, 0))
+	// Wednesday 4th till Friday 13th, weekends skipped
+	assert.Len(t, occurrences, 8)
+	assert.Equal(t, dtstart, occurrences[0])
+	assert.Equal(t, time.Date(2026, 11, 9, 9, 0, 0, 0, time.UTC), occurrences[3])
+	assert.Equal(t, time.Date(2026, 11, 13, 9, 0, 0, 0, time.UTC), occurrences[7])
+}
+
+func TestRRuleCountIncludesEarlierOccurrences(t *testing.T) {
+	rule, err := ParseRRule("RRULE:FREQ=DAILY;INTERVAL=2;COUNT=3")
+	assert.NoError(t, err)
+	dtstart := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+	occurrences := rule.Between(dtstart, dtstart.AddDate(0, 0, 1), dtstart.AddDate(0, 1, 0))
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 11, 3, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 11, 5, 9, 0, 0, 0, time.UTC),
+	}, occurrences)
+}
+
+func TestRRuleMonthlyOrdinalWeekday(t *testing.T) {
+	rule, err := ParseRRule("FREQ=MONTHLY;BYDAY=-1FR;COUNT=2")
+	assert.NoError(t, err)
+	dtstart := time.Date(2026, 10, 30, 14, 0, 0, 0, time.UTC)
+	occurrences := rule.Between(dtstart, dtstart, dtstart.AddDate(1, 0, 0))
+	assert.Equal(t, []time.Time{dtstart, time.Date(2026, 11, 27, 14, 0, 0, 0, time.UTC)}, occurrences)
+}
+
+func TestRRuleMonthlyWeekdayAndMonthDay(t *testing.T) {
+	// Every Friday 13th, both parts must match
+	rule, err := ParseRRule("FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=3")
+	assert.NoError(t, err)
+	dtstart := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	occurrences := rule.Between(dtstart, dtstart, dtstart.AddDate(2, 0, 0))
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 2, 13, 10, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 13, 10, 0, 0, 0, time.UTC),
+		time.Date(2026, 11, 13, 10, 0, 0, 0, time.UTC),
+	}, occurrences)
+}
+
+func TestRRuleWeeklySkipsUnmatchedStart(t *testing.T) {
+	// Rule starting on Sunday occurs only on listed weekdays
+	rule, err := ParseRRule("FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3")
+	assert.NoError(t, err)
+	dtstart := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+	occurrences := rule.Between(dtstart, dtstart, dtstart.AddDate(0, 1, 0))
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 11, 4, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 11, 9, 9, 0, 0, 0, time.UTC),
+	}, occurrences)
+}
+
+func TestRRuleFloatingUntilInSeriesTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Series starts at 18:00 in Moscow (15:00 UTC), floating UNTIL 18:00 includes the last day,
+	// the same UNTIL in UTC would end the series three hours later
+	dtstart := time.Date(2026, 11, 2, 18, 0, 0, 0, loc)
+	floating, err := ParseRRule("FREQ=DAILY;UNTIL=20261104T180000")
+	assert.NoError(t, err)
+	assert.Len(t, floating.Between(dtstart, dtstart, dtstart.AddDate(0, 1, 0)), 3)
+	floating, err = ParseRRule("FREQ=DAILY;UNTIL=20261104T170000")
+	assert.NoError(t, err)
+	assert.Len(t, floating.Between(dtstart, dtstart, dtstart.AddDate(0, 1, 0)), 2)
+	// UTC UNTIL keeps its instant
+	utc, err := ParseRRule("FREQ=DAILY;UNTIL=20261104T150000Z")
+	assert.NoError(t, err)
+	assert.Len(t, utc.Between(dtstart, dtstart, dtstart.AddDate(0, 1, 0)), 3)
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, rule := range []string{"", "BYDAY=MO", "FREQ=YEARLY", "FREQ=WEEKLY;BYDAY=1MO", "FREQ=DAILY;COUNT=2;UNTIL=20270101",
+		"FREQ=DAILY;BYMONTHDAY=13", "FREQ=WEEKLY;BYDAY=FR;BYMONTHDAY=13"} {
+		_, err := ParseRRule(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestExpandRecurringScheduleSkipsExDates(t *testing.T) {
+	rs := model.RecurringSchedule{
+		TimeStart: time.Date(2026, 12, 24, 9, 0, 0, 0, time.UTC),
+		TimeEnd:   time.Date(2026, 12, 24, 13, 0, 0, 0, time.UTC),
+		RRule:     "FREQ=DAILY;COUNT=3",
+		ExDates:   "2026-12-25T09:00:00Z",
+	}
+	windows, err := ExpandRecurringSchedule(rs, time.Date(2026, 12, 24, 12, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, []Window{
+		{TimeStart: rs.TimeStart, TimeEnd: rs.TimeEnd},
+		{TimeStart: time.Date(2026, 12, 26, 9, 0, 0, 0, time.UTC), TimeEnd: time.Date(2026, 12, 26, 13, 0, 0, 0, time.UTC)},
+	}, windows)
+}
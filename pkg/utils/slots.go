@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+func SubtractWindows(windows, busy []Window) []Window {
+	//Removing busy ranges from sorted disjoint windows
+	var free []Window
+	for _, w := range windows {
+		parts := []Window{w}
+		for _, b := range busy {
+			var next []Window
+			for _, p := range parts {
+				if !b.TimeStart.Before(p.TimeEnd) || !b.TimeEnd.After(p.TimeStart) {
+					next = append(next, p)
+					continue
+				}
+				if b.TimeStart.After(p.TimeStart) {
+					next = append(next, Window{TimeStart: p.TimeStart, TimeEnd: b.TimeStart})
+				}
+				if b.TimeEnd.Before(p.TimeEnd) {
+					next = append(next, Window{TimeStart: b.TimeEnd, TimeEnd: p.TimeEnd})
+				}
+			}
+			parts = next
+		}
+		free = append(free, parts...)
+	}
+	return free
+}
+
+func ClipWindows(windows []Window, from, to time.Time) []Window {
+	//Cutting windows to [from, to) range
+	var clipped []Window
+	for _, w := range windows {
+		if w.TimeStart.Before(from) {
+			w.TimeStart = from
+		}
+		if w.TimeEnd.After(to) {
+			w.TimeEnd = to
+		}
+		if w.TimeEnd.After(w.TimeStart) {
+			clipped = append(clipped, w)
+		}
+	}
+	return clipped
+}
+
+func GenerateSlots(free []Window, duration, step time.Duration) []Window {
+	//Cutting free windows into fixed-length slots starting every step
+	var slots []Window
+	for _, w := range free {
+		for start := w.TimeStart; !start.Add(duration).After(w.TimeEnd); start = start.Add(step) {
+			slots = append(slots, Window{TimeStart: start, TimeEnd: start.Add(duration)})
+		}
+	}
+	return slots
+}
+
+func GetFreeSlots(db *gorm.DB, doctorID uuid.UUID, from, to time.Time, duration, step, bufferBefore, bufferAfter time.Duration) ([]Window, error) {
+	//Subtracting appointments and holds of doctor from his schedules and generating bookable slots
+	//Buffers of requested slot and of existing appointments are kept free
+	windows, err := GetAvailabilityWindows(db, doctorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var appointments []model.Appointment
+	err = OccupyingAppointments(db).
+		Where("doctor_id = ? AND block_start < ? AND block_end > ?", doctorID, to.Add(bufferBefore), from.Add(-bufferAfter)).
+		Find(&appointments).Error
+	if err != nil {
+		return nil, err
+	}
+	var holds []model.SlotHold
+	err = ActiveHolds(db).
+		Where("doctor_id = ? AND block_start < ? AND block_end > ?", doctorID, to.Add(bufferBefore), from.Add(-bufferAfter)).
+		Find(&holds).Error
+	if err != nil {
+		return nil, err
+	}
+	busy := make([]Window, 0, len(appointments)+len(holds))
+	for _, a := range appointments {
+		busy = append(busy, Window{TimeStart: a.BlockStart, TimeEnd: a.BlockEnd})
+	}
+	for _, h := range holds {
+		busy = append(busy, Window{TimeStart: h.BlockStart, TimeEnd: h.BlockEnd})
+	}
+	return FreeSlots(ClipWindows(windows, from, to), busy, duration, step, bufferBefore, bufferAfter), nil
+}
+
+func FreeSlots(windows, busy []Window, duration, step, bufferBefore, bufferAfter time.Duration) []Window {
+	//Slot [s, e) is free when [s - bufferBefore, e + bufferAfter) does not intersect busy range,
+	//which is the same as [s, e) not intersecting busy range widened by buffers
+	widened := make([]Window, 0, len(busy))
+	for _, b := range busy {
+		widened = append(widened, Window{TimeStart: b.TimeStart.Add(-bufferAfter), TimeEnd: b.TimeEnd.Add(bufferBefore)})
+	}
+	return GenerateSlots(SubtractWindows(windows, MergeWindows(widened)), duration, step)
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func at(hour, min int) time.Time {
+	return time.Date(2026, 11, 2, hour, min, 0, 0, time.UTC)
+}
+
+func TestGenerateSlotsAroundAppointments(t *testing.T) {
+	schedules := MergeWindows([]Window{
+		{TimeStart: at(9, 0), TimeEnd: at(11, 0)},
+		{TimeStart: at(10, 0), TimeEnd: at(12, 0)},
+	})
+	busy := []Window{{TimeStart: at(9, 30), TimeEnd: at(10, 15)}}
+	free := SubtractWindows(schedules, busy)
+	assert.Equal(t, []Window{
+		{TimeStart: at(9, 0), TimeEnd: at(9, 30)},
+		{TimeStart: at(10, 15), TimeEnd: at(12, 0)},
+	}, free)
+	slots := GenerateSlots(free, 30*time.Minute, 30*time.Minute)
+	assert.Equal(t, []Window{
+		{TimeStart: at(9, 0), TimeEnd: at(9, 30)},
+		{TimeStart: at(10, 15), TimeEnd: at(10, 45)},
+		{TimeStart: at(10, 45), TimeEnd: at(11, 15)},
+		{TimeStart: at(11, 15), TimeEnd: at(11, 45)},
+	}, slots)
+}
+
+func TestGenerateSlotsWithStep(t *testing.T) {
+	free := ClipWindows([]Window{{TimeStart: at(8, 0), TimeEnd: at(10, 0)}}, at(9, 0), at(12, 0))
+	slots := GenerateSlots(free, 45*time.Minute, 15*time.Minute)
+	assert.Len(t, slots, 2)
+	assert.Equal(t, at(9, 15), slots[1].TimeStart)
+	assert.Equal(t, at(10, 0), slots[1].TimeEnd)
+}
+
+func TestFreeSlotsKeepBuffers(t *testing.T) {
+	schedules := []Window{{TimeStart: at(9, 0), TimeEnd: at(12, 0)}}
+	// Existing 10:00-10:15 visit with 5 minutes before and 10 minutes after
+	busy := []Window{{TimeStart: at(9, 55), TimeEnd: at(10, 25)}}
+	slots := FreeSlots(schedules, busy, 45*time.Minute, 15*time.Minute, 5*time.Minute, 10*time.Minute)
+	assert.Equal(t, []Window{
+		{TimeStart: at(9, 0), TimeEnd: at(9, 45)},
+		{TimeStart: at(10, 30), TimeEnd: at(11, 15)},
+		{TimeStart: at(10, 45), TimeEnd: at(11, 30)},
+		{TimeStart: at(11, 0), TimeEnd: at(11, 45)},
+		{TimeStart: at(11, 15), TimeEnd: at(12, 0)},
+	}, slots)
+}
+
+func TestSlotIDRoundTrip(t *testing.T) {
+	slot := Window{TimeStart: at(9, 0), TimeEnd: at(9, 30)}
+	parsed, err := ParseSlotID(SlotID(slot))
+	assert.NoError(t, err)
+	assert.Equal(t, slot, parsed)
+	_, err = ParseSlotID("1700000000-1600000000")
+	assert.Error(t, err)
+}
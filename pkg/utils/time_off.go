@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrTimeOff = errors.New("Doctor is not available at this time")
+
+func DoctorTimeOff(db *gorm.DB, doctorID uuid.UUID) *gorm.DB {
+	//Query of time-off of doctor and clinic-wide holidays of clinic of the doctor
+	clinic := db.Session(&gorm.Session{NewDB: true}).Model(&model.UserProfile{}).Select("clinic_id").Where("user_id = ?", doctorID)
+	return db.Where("(doctor_id = ? OR (clinic_wide AND clinic_id IN (?)))", doctorID, clinic)
+}
+
+func FindTimeOff(db *gorm.DB, doctorID uuid.UUID, timeStart, timeEnd time.Time) ([]model.TimeOff, error) {
+	//Fetching doctor time-off and clinic-wide holidays intersecting [timeStart, timeEnd)
+	var timeOffs []model.TimeOff
+	err := DoctorTimeOff(db, doctorID).Where("time_start < ? AND time_end > ?", timeEnd, timeStart).Find(&timeOffs).Error
+	return timeOffs, err
+}
+
+func FindTimeOffConflicts(db *gorm.DB, timeOff model.TimeOff) ([]model.Appointment, error) {
+	//Fetching appointments which have to be rescheduled because of time-off
+	var appointments []model.Appointment
+	query := OccupyingAppointments(db).Where("time_start < ? AND time_end > ?", timeOff.TimeEnd, timeOff.TimeStart)
+	if timeOff.ClinicWide {
+		//Holiday without clinic blocks nobody
+		doctors := db.Session(&gorm.Session{NewDB: true}).Model(&model.UserProfile{}).Select("user_id").Where("clinic_id = ?", timeOff.ClinicID)
+		query = query.Where("doctor_id IN (?)", doctors)
+	} else {
+		query = query.Where("doctor_id = ?", timeOff.DoctorID)
+	}
+	err := query.Order("time_start").Find(&appointments).Error
+	return appointments, err
+}
+
+func timeOffWindows(timeOffs []model.TimeOff) []Window {
+	windows := make([]Window, 0, len(timeOffs))
+	for _, t := range timeOffs {
+		windows = append(windows, Window{TimeStart: t.TimeStart, TimeEnd: t.TimeEnd})
+	}
+	return MergeWindows(windows)
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"reflect"
+	"time"
+	//Embedded IANA database, server does not depend on system zoneinfo
+	_ "time/tzdata"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+func LoadLocation(name string) (*time.Location, error) {
+	//Loading IANA time zone, empty name means UTC
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+func WallClock(year int, month time.Month, day, hour, min, sec int, loc *time.Location) time.Time {
+	//Converting local wall-clock time into instant with defined DST handling (RFC 5545):
+	//time skipped by DST gap is shifted forward by the length of the gap,
+	//time repeated by DST overlap resolves to its first occurrence
+	naive := time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	//Offsets a day before and a day after cover a single transition
+	_, offsetBefore := naive.Add(-24 * time.Hour).In(loc).Zone()
+	_, offsetAfter := naive.Add(24 * time.Hour).In(loc).Zone()
+	before := naive.Add(-time.Duration(offsetBefore) * time.Second)
+	after := naive.Add(-time.Duration(offsetAfter) * time.Second)
+	beforeValid := sameWallClock(before.In(loc), naive)
+	afterValid := sameWallClock(after.In(loc), naive)
+	switch {
+	case beforeValid && afterValid:
+		if after.Before(before) {
+			return after.In(loc)
+		}
+		return before.In(loc)
+	case afterValid:
+		return after.In(loc)
+	default:
+		//Valid with offset before transition or inside of DST gap
+		return before.In(loc)
+	}
+}
+
+func sameWallClock(t, naive time.Time) bool {
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := naive.Date()
+	h1, min1, s1 := t.Clock()
+	h2, min2, s2 := naive.Clock()
+	return y1 == y2 && m1 == m2 && d1 == d2 && h1 == h2 && min1 == min2 && s1 == s2
+}
+
+func UserLocation(db *gorm.DB, userID uuid.UUID) *time.Location {
+	//Time zone of user profile, then of his clinic, UTC by default
+	var profile model.UserProfile
+	if err := db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		return time.UTC
+	}
+	if loc, err := LoadLocation(profile.TimeZone); err == nil && profile.TimeZone != "" {
+		return loc
+	}
+	if profile.ClinicID != nil {
+		var clinic model.Clinic
+		if err := db.First(&clinic, *profile.ClinicID).Error; err == nil {
+			if loc, err := LoadLocation(clinic.TimeZone); err == nil {
+				return loc
+			}
+		}
+	}
+	return time.UTC
+}
+
+func InLocation(value interface{}, loc *time.Location) {
+	//Converting all time fields of pointed struct or slice into location for rendering
+	convertTimes(reflect.ValueOf(value), loc)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func convertTimes(v reflect.Value, loc *time.Location) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			convertTimes(v.Elem(), loc)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			convertTimes(v.Index(i), loc)
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(v.Interface().(time.Time).In(loc)))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				convertTimes(v.Field(i), loc)
+			}
+		}
+	}
+}
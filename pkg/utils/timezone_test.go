@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"ScheduleAPI/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newYork(t *testing.T) *time.Location {
+	loc, err := LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	return loc
+}
+
+func TestWallClockDSTGapShiftsForward(t *testing.T) {
+	// 2026-03-08 02:30 does not exist in New York, clocks jump from 02:00 EST to 03:00 EDT
+	got := WallClock(2026, 3, 8, 2, 30, 0, newYork(t))
+	assert.Equal(t, time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC), got.UTC())
+	assert.Equal(t, 3, got.Hour())
+}
+
+func TestWallClockDSTOverlapTakesFirst(t *testing.T) {
+	// 2026-11-01 01:30 happens twice in New York, first time in EDT
+	got := WallClock(2026, 11, 1, 1, 30, 0, newYork(t))
+	assert.Equal(t, time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), got.UTC())
+}
+
+func TestRecurringScheduleKeepsLocalHoursAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	rs := model.RecurringSchedule{
+		TimeStart: time.Date(2026, 3, 6, 9, 0, 0, 0, loc),
+		TimeEnd:   time.Date(2026, 3, 6, 13, 0, 0, 0, loc),
+		RRule:     "FREQ=WEEKLY;BYDAY=FR,MO",
+		TimeZone:  "America/New_York",
+	}
+	windows, err := ExpandRecurringSchedule(rs, rs.TimeStart, rs.TimeStart.AddDate(0, 0, 4))
+	assert.NoError(t, err)
+	assert.Equal(t, []Window{
+		// Friday before DST change, EST
+		{TimeStart: time.Date(2026, 3, 6, 14, 0, 0, 0, time.UTC), TimeEnd: time.Date(2026, 3, 6, 18, 0, 0, 0, time.UTC)},
+		// Monday after DST change, EDT
+		{TimeStart: time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC), TimeEnd: time.Date(2026, 3, 9, 17, 0, 0, 0, time.UTC)},
+	}, windows)
+}
+
+func TestRecurringScheduleInsideDSTGap(t *testing.T) {
+	loc := newYork(t)
+	// Night shift 01:00-05:00 is one hour shorter on the night of DST change
+	rs := model.RecurringSchedule{
+		TimeStart: time.Date(2026, 3, 7, 1, 0, 0, 0, loc),
+		TimeEnd:   time.Date(2026, 3, 7, 5, 0, 0, 0, loc),
+		RRule:     "FREQ=DAILY;COUNT=2",
+		TimeZone:  "America/New_York",
+	}
+	windows, err := ExpandRecurringSchedule(rs, rs.TimeStart, rs.TimeStart.AddDate(0, 0, 3))
+	assert.NoError(t, err)
+	assert.Len(t, windows, 2)
+	assert.Equal(t, 4*time.Hour, windows[0].TimeEnd.Sub(windows[0].TimeStart))
+	assert.Equal(t, 3*time.Hour, windows[1].TimeEnd.Sub(windows[1].TimeStart))
+}
+
+func TestInLocationConvertsNestedTimes(t *testing.T) {
+	loc := newYork(t)
+	appointments := []model.Appointment{{TimeStart: time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC)}}
+	InLocation(&appointments, loc)
+	assert.Equal(t, loc, appointments[0].TimeStart.Location())
+	assert.Equal(t, 9, appointments[0].TimeStart.Hour())
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/templates"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrOfferNotActive = errors.New("Waitlist entry has no active offer")
+
+// Entry got offer from concurrent processing of waitlist or left waiting state
+var errEntryNotWaiting = errors.New("Waitlist entry is not waiting")
+
+func ProcessWaitlist(db *gorm.DB, doctorID uuid.UUID, offerTTL time.Duration) error {
+	//Offering free slots of doctor to waitlisted patients in order of registration
+	//Offered slot is held for the patient until offer expires
+	//Entry is locked and checked to be waiting again in transaction of the offer, concurrent runs offer it once
+	now := time.Now()
+	var entries []model.WaitlistEntry
+	err := db.Where("doctor_id = ? AND status = ? AND time_to > ?", doctorID, model.WaitlistWaiting, now).
+		Order("created_at").Find(&entries).Error
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		var bufferBefore, bufferAfter time.Duration
+		if entry.AppointmentTypeID != nil {
+			var appointmentType model.AppointmentType
+			if err := db.First(&appointmentType, *entry.AppointmentTypeID).Error; err == nil {
+				bufferBefore, bufferAfter = appointmentType.BufferBefore, appointmentType.BufferAfter
+			}
+		}
+		from := entry.TimeFrom
+		if from.Before(now) {
+			from = now
+		}
+		slots, err := GetFreeSlots(db, doctorID, from, entry.TimeTo, entry.Duration, entry.Duration, bufferBefore, bufferAfter)
+		if err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			hold := model.SlotHold{
+				DoctorID:     entry.DoctorID,
+				DoctorEmail:  entry.DoctorEmail,
+				PatientID:    entry.PatientID,
+				PatientEmail: entry.PatientEmail,
+				TimeStart:    slot.TimeStart,
+				TimeEnd:      slot.TimeEnd,
+				ExpiresAt:    now.Add(offerTTL),
+				//Offered slot keeps buffers of requested type free
+				AppointmentTypeID: entry.AppointmentTypeID,
+				BufferBefore:      bufferBefore,
+				BufferAfter:       bufferAfter,
+			}
+			//Hold, offer and its notification are stored together
+			err := db.Transaction(func(tx *gorm.DB) error {
+				result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+					Where("id = ? AND status = ?", entry.ID, model.WaitlistWaiting).Limit(1).Find(&entry)
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return errEntryNotWaiting
+				}
+				if err := PlaceOfferHold(tx, &hold); err != nil {
+					return err
+				}
+				entry.Status = model.WaitlistOffered
+				entry.HoldID = &hold.ID
+				entry.OfferExpiresAt = &hold.ExpiresAt
+				if err := tx.Save(&entry).Error; err != nil {
+					return err
+				}
+				data := templates.Data{Hold: &hold, Location: UserClinicName(tx, hold.DoctorID)}
+				return CreateNotification(tx, "WaitlistOffer", entry.PatientEmail, entry.PatientID, data)
+			})
+			if errors.Is(err, errEntryNotWaiting) {
+				break
+			}
+			if err != nil {
+				if errors.Is(err, ErrSlotTaken) || errors.Is(err, ErrNoSchedule) {
+					continue
+				}
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func ProcessWaitlistLogged(db *gorm.DB, doctorID uuid.UUID, offerTTL time.Duration) {
+	//Processing waitlist after freeing time, failures do not affect the caller
+	if err := ProcessWaitlist(db, doctorID, offerTTL); err != nil {
+		log.Print("Failed to process waitlist: ", err)
+	}
+}
+
+func AcceptWaitlistOffer(db *gorm.DB, entry *model.WaitlistEntry) (model.Appointment, error) {
+	//Booking slot offered to waitlisted patient
+	if entry.Status != model.WaitlistOffered || entry.HoldID == nil {
+		return model.Appointment{}, ErrOfferNotActive
+	}
+	var hold model.SlotHold
+	if err := db.First(&hold, *entry.HoldID).Error; err != nil {
+		return model.Appointment{}, ErrHoldExpired
+	}
+	//Appointment gets buffers of requested type
+	var appointmentType *model.AppointmentType
+	if entry.AppointmentTypeID != nil {
+		appointmentType = &model.AppointmentType{}
+		if err := db.First(appointmentType, *entry.AppointmentTypeID).Error; err != nil {
+			return model.Appointment{}, err
+		}
+	}
+	var appointment model.Appointment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		appointment, err = ConfirmHold(tx, &hold, appointmentType)
+		if err != nil {
+			return err
+		}
+		entry.Status = model.WaitlistBooked
+		entry.AppointmentID = &appointment.ID
+		return tx.Save(entry).Error
+	})
+	return appointment, err
+}
+
+func ExpireWaitlist(db *gorm.DB) ([]uuid.UUID, error) {
+	//Expiring entries with outdated offers or desired range, returning doctors whose time was released
+	now := time.Now()
+	var entries []model.WaitlistEntry
+	err := db.Where("(status = ? AND offer_expires_at <= ?) OR (status = ? AND time_to <= ?)",
+		model.WaitlistOffered, now, model.WaitlistWaiting, now).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	var doctors []uuid.UUID
+	for _, entry := range entries {
+		if entry.Status == model.WaitlistOffered {
+			doctors = append(doctors, entry.DoctorID)
+			if entry.HoldID != nil {
+				db.Where("appointment_id IS NULL").Delete(&model.SlotHold{}, *entry.HoldID)
+			}
+		}
+		entry.Status = model.WaitlistExpired
+		if err := db.Save(&entry).Error; err != nil {
+			return doctors, err
+		}
+	}
+	return doctors, nil
+}
+
+func WaitlistedDoctors(db *gorm.DB) ([]uuid.UUID, error) {
+	//Fetching doctors having patients waiting for free time
+	var doctors []uuid.UUID
+	err := db.Model(&model.WaitlistEntry{}).Where("status = ?", model.WaitlistWaiting).
+		Distinct().Pluck("doctor_id", &doctors).Error
+	return doctors, err
+}
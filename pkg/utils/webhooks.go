@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/notifier"
+	"ScheduleAPI/pkg/webhook"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook event types
+const (
+	EventAppointmentCreated     = "appointment.created"
+	EventAppointmentChanged     = "appointment.changed"
+	EventAppointmentCancelled   = "appointment.cancelled"
+	EventAppointmentStatus      = "appointment.status"
+	EventAppointmentRescheduled = "appointment.rescheduled"
+	EventPrescriptionCreated    = "prescription.created"
+	EventPrescriptionUpdated    = "prescription.updated"
+	EventPrescriptionDeleted    = "prescription.deleted"
+	EventMedicalRecordCreated   = "medical_record.created"
+	EventMedicalRecordUpdated   = "medical_record.updated"
+	EventMedicalRecordDeleted   = "medical_record.deleted"
+	//Notification of subscription owner delivered through webhook channel
+	EventNotificationCreated = "notification.created"
+	//Sent only by test-fire of subscription
+	EventPing = "ping"
+)
+
+var WebhookEvents = []string{
+	EventAppointmentCreated,
+	EventAppointmentChanged,
+	EventAppointmentCancelled,
+	EventAppointmentStatus,
+	EventAppointmentRescheduled,
+	EventPrescriptionCreated,
+	EventPrescriptionUpdated,
+	EventPrescriptionDeleted,
+	EventMedicalRecordCreated,
+	EventMedicalRecordUpdated,
+	EventMedicalRecordDeleted,
+	EventNotificationCreated,
+}
+
+// Webhook events of appointment notification types
+var appointmentEvents = map[string]string{
+	"Create":     EventAppointmentCreated,
+	"Change":     EventAppointmentChanged,
+	"Cancel":     EventAppointmentCancelled,
+	"Status":     EventAppointmentStatus,
+	"Reschedule": EventAppointmentRescheduled,
+}
+
+// Number of webhook deliveries claimed by worker at once
+const webhookBatchSize = 20
+
+// Body of webhook request, id is kept when delivery is replayed
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func IsWebhookEvent(event string) bool {
+	for _, value := range WebhookEvents {
+		if value == event {
+			return true
+		}
+	}
+	return false
+}
+
+func subscribedTo(subscription model.WebhookSubscription, event string) bool {
+	if subscription.Events == "" {
+		return true
+	}
+	for _, value := range strings.Split(subscription.Events, ",") {
+		if value == event {
+			return true
+		}
+	}
+	return false
+}
+
+func EmitEvent(db *gorm.DB, event string, data interface{}, doctorID uuid.UUID, participantIDs ...uuid.UUID) error {
+	//Queueing event for webhook subscriptions of its doctor and other participants,
+	//of clinic of the doctor and system-wide subscriptions
+	//Pass transaction of the change, so event is delivered only when change is committed
+	owners := append([]uuid.UUID{doctorID}, participantIDs...)
+	clinic := db.Session(&gorm.Session{NewDB: true}).Model(&model.UserProfile{}).Select("clinic_id").Where("user_id = ?", doctorID)
+	var subscriptions []model.WebhookSubscription
+	err := db.Where("(scope = ? AND created_by IN ?) OR scope = ? OR (scope = ? AND clinic_id IN (?))",
+		model.WebhookScopeUser, owners, model.WebhookScopeSystem, model.WebhookScopeClinic, clinic).Order("id").Find(&subscriptions).Error
+	if err != nil {
+		return err
+	}
+	eventID := uuid.Must(uuid.NewV4()).String()
+	var deliveries []model.WebhookDelivery
+	var payload []byte
+	for _, subscription := range subscriptions {
+		if !subscribedTo(subscription, event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(WebhookPayload{ID: eventID, Event: event, CreatedAt: time.Now().UTC(), Data: data})
+			if err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, newWebhookDelivery(subscription.ID, eventID, event, payload))
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return db.Create(&deliveries).Error
+}
+
+var ErrNoWebhookSubscription = errors.New("User has no webhook subscription for notifications")
+
+// Data of notification.created event
+type NotificationEventData struct {
+	Type      string    `json:"type"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserEmail string    `json:"user_email"`
+	Subject   string    `json:"subject"`
+	Text      string    `json:"text"`
+}
+
+// WebhookNotifier is notifier of webhook channel, it queues notifications for webhook subscriptions
+// of their recipient, webhook worker signs, sends and retries them
+type WebhookNotifier struct {
+	db *gorm.DB
+}
+
+func NewWebhookNotifier(db *gorm.DB) *WebhookNotifier {
+	return &WebhookNotifier{db: db}
+}
+
+func (n *WebhookNotifier) Send(message notifier.Message) error {
+	//Only user-scoped subscriptions created by recipient receive his notifications
+	var subscriptions []model.WebhookSubscription
+	err := n.db.Where("scope = ? AND created_by = ?", model.WebhookScopeUser, message.UserID).Order("id").Find(&subscriptions).Error
+	if err != nil {
+		return err
+	}
+	eventID := uuid.Must(uuid.NewV4()).String()
+	payload, err := json.Marshal(WebhookPayload{ID: eventID, Event: EventNotificationCreated, CreatedAt: time.Now().UTC(), Data: NotificationEventData{
+		Type:      message.Type,
+		UserID:    message.UserID,
+		UserEmail: message.UserEmail,
+		Subject:   message.Subject,
+		Text:      message.Text,
+	}})
+	if err != nil {
+		return err
+	}
+	var deliveries []model.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if subscribedTo(subscription, EventNotificationCreated) {
+			deliveries = append(deliveries, newWebhookDelivery(subscription.ID, eventID, EventNotificationCreated, payload))
+		}
+	}
+	if len(deliveries) == 0 {
+		return ErrNoWebhookSubscription
+	}
+	return n.db.Create(&deliveries).Error
+}
+
+func newWebhookDelivery(subscriptionID uint, eventID, event string, payload []byte) model.WebhookDelivery {
+	return model.WebhookDelivery{
+		SubscriptionID: subscriptionID,
+		EventID:        eventID,
+		Event:          event,
+		Payload:        string(payload),
+		Status:         model.WebhookPending,
+		NextAttemptAt:  time.Now(),
+	}
+}
+
+func ReplayWebhookDelivery(db *gorm.DB, delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	//Queueing payload of delivery again as new delivery, event id is kept for deduplication by receiver
+	replay := newWebhookDelivery(delivery.SubscriptionID, delivery.EventID, delivery.Event, []byte(delivery.Payload))
+	err := db.Create(&replay).Error
+	return replay, err
+}
+
+func FireTestWebhook(db *gorm.DB, sender *webhook.Sender, subscription model.WebhookSubscription) (model.WebhookDelivery, error) {
+	//Sending ping event to subscription right away, failed ping is not retried
+	//Request is sent outside of transaction, delivery is claimed by the request until the result is recorded
+	eventID := uuid.Must(uuid.NewV4()).String()
+	payload, err := json.Marshal(WebhookPayload{ID: eventID, Event: EventPing, CreatedAt: time.Now().UTC(),
+		Data: map[string]interface{}{"subscription_id": subscription.ID}})
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	delivery := newWebhookDelivery(subscription.ID, eventID, EventPing, payload)
+	delivery.NextAttemptAt = time.Now().Add(webhookClaimLease(sender, 1))
+	if err := db.Create(&delivery).Error; err != nil {
+		return delivery, err
+	}
+	err = deliverWebhook(db, sender, DeliveryPolicy{MaxAttempts: 1}, &delivery)
+	return delivery, err
+}
+
+func webhookClaimLease(sender *webhook.Sender, deliveries int) time.Duration {
+	//Time claimed deliveries are hidden from other workers, longer than sending all of them
+	//Deliveries of crashed worker are sent again after it
+	return time.Duration(deliveries)*sender.Timeout() + time.Minute
+}
+
+func claimWebhookDeliveries(db *gorm.DB, lease time.Duration) ([]model.WebhookDelivery, error) {
+	//Locking due deliveries with SKIP LOCKED and moving their next attempt past the lease in short transaction
+	var deliveries []model.WebhookDelivery
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.WebhookPending, now).
+			Order("next_attempt_at").Limit(webhookBatchSize).Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uint, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
+
+func DeliverWebhooks(db *gorm.DB, sender *webhook.Sender, policy DeliveryPolicy) (int, error) {
+	//Sending due webhook deliveries, returns number of processed deliveries
+	//Deliveries are claimed in short transaction, so several workers never send the same delivery
+	//and no rows are locked while receivers respond. Result of every request is recorded
+	//in its own transaction, failure of one record does not make sent deliveries pending again
+	deliveries, err := claimWebhookDeliveries(db, webhookClaimLease(sender, webhookBatchSize))
+	if err != nil {
+		return 0, err
+	}
+	var firstErr error
+	for i := range deliveries {
+		if err := deliverWebhook(db, sender, policy, &deliveries[i]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return len(deliveries), firstErr
+}
+
+func deliverWebhook(db *gorm.DB, sender *webhook.Sender, policy DeliveryPolicy, delivery *model.WebhookDelivery) error {
+	//Sending claimed delivery once and recording the attempt
+	var subscription model.WebhookSubscription
+	if err := db.First(&subscription, delivery.SubscriptionID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		delivery.Status = model.WebhookDead
+		delivery.LastError = "Subscription was deleted"
+		return db.Omit("History").Save(delivery).Error
+	}
+	now := time.Now()
+	delivery.Attempts++
+	result, sendErr := sender.Send(webhook.Request{
+		URL:        subscription.URL,
+		Secret:     subscription.Secret,
+		Event:      delivery.Event,
+		DeliveryID: delivery.ID,
+		Body:       []byte(delivery.Payload),
+	})
+	attempt := model.WebhookAttempt{DeliveryID: delivery.ID, StatusCode: result.StatusCode, Duration: result.Duration}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+	}
+	if errors.Is(sendErr, webhook.ErrAddressNotAllowed) {
+		//Receiver moved to internal address, retrying cannot help
+		delivery.Attempts = policy.MaxAttempts
+	}
+	delivery.LastStatusCode = result.StatusCode
+	switch {
+	case sendErr == nil:
+		delivery.Status = model.WebhookSent
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= policy.MaxAttempts:
+		log.Printf("Webhook delivery %d is dead after %d attempts: %v", delivery.ID, delivery.Attempts, sendErr)
+		delivery.Status = model.WebhookDead
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.LastError = sendErr.Error()
+		delivery.NextAttemptAt = now.Add(policy.RetryDelay(delivery.Attempts))
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		delivery.History = append(delivery.History, attempt)
+		return tx.Omit("History").Save(delivery).Error
+	})
+}
+
+func RunWebhookWorker(db *gorm.DB, sender *webhook.Sender, policy DeliveryPolicy, interval time.Duration) {
+	//Background loop sending queued webhooks, run it in separate goroutine
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for {
+			processed, err := DeliverWebhooks(db, sender, policy)
+			if err != nil {
+				log.Print("Failed to deliver webhooks: ", err)
+			}
+			//Continuing while batches are full
+			if err != nil || processed < webhookBatchSize {
+				break
+			}
+		}
+	}
+}
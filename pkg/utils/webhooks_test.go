@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"ScheduleAPI/pkg/model"
+	"ScheduleAPI/pkg/webhook"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribedTo(t *testing.T) {
+	all := model.WebhookSubscription{}
+	assert.True(t, subscribedTo(all, EventAppointmentCreated))
+	filtered := model.WebhookSubscription{Events: EventAppointmentCreated + "," + EventMedicalRecordUpdated}
+	assert.True(t, subscribedTo(filtered, EventMedicalRecordUpdated))
+	assert.False(t, subscribedTo(filtered, EventAppointmentCancelled))
+}
+
+func TestAppointmentEventsAreKnown(t *testing.T) {
+	for notificationType, event := range appointmentEvents {
+		assert.True(t, IsWebhookEvent(event), notificationType)
+	}
+	assert.False(t, IsWebhookEvent(EventPing))
+}
+
+func TestWebhookClaimLease(t *testing.T) {
+	// Claimed deliveries stay hidden longer than worker needs to send all of them
+	sender := webhook.NewSender(10*time.Second, false)
+	assert.Greater(t, webhookClaimLease(sender, webhookBatchSize), webhookBatchSize*10*time.Second)
+	assert.Greater(t, webhookClaimLease(sender, 1), 10*time.Second)
+}
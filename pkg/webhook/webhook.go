@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers of webhook request, signature is "sha256=" followed by hex HMAC-SHA256
+// of "<timestamp>.<body>" with subscription secret
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	ErrInvalidURL         = errors.New("Invalid url, absolute http or https url is required")
+	ErrAddressNotAllowed  = errors.New("Webhook receiver address is not allowed, use public address")
+	ErrUnresolvedReceiver = errors.New("Webhook receiver host cannot be resolved")
+)
+
+// Special purpose networks not covered by net.IP checks: "this network", shared address space (CGNAT),
+// IETF protocol assignments and benchmarking
+var blockedNetworks = mustParseNetworks("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15")
+
+func mustParseNetworks(values ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func AllowedIP(ip net.IP) bool {
+	//Only public unicast addresses receive webhooks, internal hosts and cloud metadata endpoints
+	//(loopback, link-local, private networks) are never requested
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Request to a single subscription
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID uint
+	Body       []byte
+}
+
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+// Sender posts signed event payloads to receivers
+// Unless private networks are allowed, every connection (including redirects) is checked
+// against AllowedIP right before dialing, so DNS changes after subscription do not bypass it
+type Sender struct {
+	client       *http.Client
+	timeout      time.Duration
+	allowPrivate bool
+}
+
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !AllowedIP(ip) {
+				return ErrAddressNotAllowed
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &Sender{client: &http.Client{Timeout: timeout, Transport: transport}, timeout: timeout, allowPrivate: allowPrivate}
+}
+
+func (s *Sender) Timeout() time.Duration {
+	//Longest time of one request
+	return s.timeout
+}
+
+func (s *Sender) CheckURL(raw string) error {
+	//Checking URL of subscription is absolute http(s) URL of host with only public addresses
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if s.allowPrivate {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return ErrUnresolvedReceiver
+	}
+	for _, address := range addresses {
+		if !AllowedIP(address.IP) {
+			return ErrAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	//Checking signature of received request, receivers should also reject old timestamps
+	value, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, value, body)), []byte(signature))
+}
+
+func (s *Sender) Send(request Request) (Result, error) {
+	//Posting JSON payload, any response other than 2xx is a failed attempt
+	timestamp := time.Now().Unix()
+	httpRequest, err := http.NewRequest(http.MethodPost, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		return Result{}, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("User-Agent", "ScheduleAPI-Webhook")
+	httpRequest.Header.Set(EventHeader, request.Event)
+	httpRequest.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(request.DeliveryID), 10))
+	httpRequest.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	httpRequest.Header.Set(SignatureHeader, Sign(request.Secret, timestamp, request.Body))
+	start := time.Now()
+	response, err := s.client.Do(httpRequest)
+	result := Result{Duration: time.Since(start)}
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+	//Reading limited response, so connection can be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	result.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return result, fmt.Errorf("Receiver responded with status %d", response.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendSignedRequest(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	sender := NewSender(time.Second, true)
+	payload := []byte(`{"event":"ping"}`)
+	result, err := sender.Send(Request{URL: receiver.URL, Secret: "secret", Event: "ping", DeliveryID: 7, Body: payload})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, "ping", received.Header.Get(EventHeader))
+	assert.Equal(t, "7", received.Header.Get(DeliveryHeader))
+	assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+	// Receiver verifies signature with shared secret
+	signature := received.Header.Get(SignatureHeader)
+	timestamp := received.Header.Get(TimestampHeader)
+	assert.True(t, Verify("secret", timestamp, body, signature))
+	assert.False(t, Verify("other", timestamp, body, signature))
+	assert.False(t, Verify("secret", timestamp, []byte(`{"event":"tampered"}`), signature))
+}
+
+func TestSendFailedStatus(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	result, err := NewSender(time.Second, true).Send(Request{URL: receiver.URL, Secret: "secret", Body: []byte(`{}`)})
+	assert.EqualError(t, err, "Receiver responded with status 503")
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+}
+
+func TestSignKnownValue(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" with key "secret"
+	assert.Equal(t, "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163", Sign("secret", 1700000000, []byte("{}")))
+}
+
+func TestAllowedIP(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"fd00::1":         false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::":              false,
+		"224.0.0.1":       false,
+		"::ffff:10.0.0.1": false,
+	}
+	for value, allowed := range cases {
+		assert.Equal(t, allowed, AllowedIP(net.ParseIP(value)), value)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	sender := NewSender(time.Second, false)
+	assert.NoError(t, sender.CheckURL("https://8.8.8.8/hooks"))
+	assert.ErrorIs(t, sender.CheckURL("ftp://8.8.8.8/hooks"), ErrInvalidURL)
+	assert.ErrorIs(t, sender.CheckURL("/hooks"), ErrInvalidURL)
+	for _, value := range []string{"http://127.0.0.1:8080/", "http://[::1]/", "http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/hooks", "http://localhost/hooks"} {
+		assert.ErrorIs(t, sender.CheckURL(value), ErrAddressNotAllowed, value)
+	}
+	// Private receivers are accepted only when allowed explicitly
+	assert.NoError(t, NewSender(time.Second, true).CheckURL("http://10.0.0.5/hooks"))
+}
+
+func TestSendRejectsPrivateReceiver(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	// Address is checked when connecting, so receiver is never requested
+	_, err := NewSender(time.Second, false).Send(Request{URL: receiver.URL, Secret: "secret", Body: []byte(`{}`)})
+	assert.True(t, errors.Is(err, ErrAddressNotAllowed), "%v", err)
+	assert.False(t, called)
+}